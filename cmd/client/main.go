@@ -1,16 +1,22 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/urfave/cli/v2"
@@ -62,12 +68,42 @@ func runApp(args []string) error {
 			},
 			&cli.StringFlag{ // e.g. "mistral", "gpt-4o".
 				Name:  "model",
-				Usage: "model name (ollama or openai)",
+				Usage: "model name for the selected backend",
+			},
+			&cli.StringFlag{
+				Name:  "backend",
+				Value: cfg.Backend,
+				Usage: "AI backend: " + strings.Join(config.Backends, ", ") + " (default: ollama with --local, openai with OPENAI_API_KEY, otherwise server)",
+			},
+			&cli.StringFlag{
+				Name:    "backend-config",
+				EnvVars: []string{"AI_BACKEND_CONFIG"},
+				Usage:   "YAML file with per-backend base_url, api_key_env, model, temperature, and max_tokens",
+			},
+			&cli.StringFlag{
+				Name:  "base-url",
+				Usage: "base URL for the selected backend, e.g. http://localhost:1234/v1 for LM Studio",
+			},
+			&cli.StringFlag{
+				Name:  "api-key-env",
+				Usage: "environment variable holding the selected backend's API key",
+			},
+			&cli.Float64Flag{
+				Name:  "temperature",
+				Usage: "sampling temperature for the selected backend",
+			},
+			&cli.IntFlag{
+				Name:  "max-tokens",
+				Usage: "maximum completion tokens per AI call",
 			},
 			&cli.BoolFlag{ // dry‑run means log only.
 				Name:  "dry-run",
 				Usage: "preview changes only",
 			},
+			&cli.BoolFlag{
+				Name:  "plan",
+				Usage: "dry run that never calls the AI; report how many files fall below --confidence-threshold and the estimated AI tokens and cost",
+			},
 			&cli.BoolFlag{ // rename instead of copy; copy is safer default.
 				Name:  "rename",
 				Usage: "rename files in place instead of copying to output directory",
@@ -104,6 +140,48 @@ func runApp(args []string) error {
 				Value: 2000,
 				Usage: "maximum compact evidence characters sent to AI in auto mode",
 			},
+			&cli.IntFlag{
+				Name:  "ai-candidates",
+				Value: 3,
+				Usage: "number of rated filename alternatives to request from the AI; reviewers can pick one with --review-choose",
+			},
+			&cli.StringFlag{
+				Name:  "vision-model",
+				Value: cfg.VisionModel,
+				Usage: "vision-capable model on the selected backend, e.g. gpt-4o or llava; when set, images and scanned PDFs that need AI are named from a thumbnail",
+			},
+			&cli.StringFlag{
+				Name:  "naming",
+				Value: utils.StyleKebab,
+				Usage: "naming convention: " + strings.Join(utils.Styles, ", ") + "; date-prefix puts the file's date first",
+			},
+			&cli.IntFlag{
+				Name:  "max-name-length",
+				Value: utils.DefaultMaxNameLength,
+				Usage: "longest name in bytes, without the extension",
+			},
+			&cli.StringSliceFlag{
+				Name:  "banned-words",
+				Usage: "words never used in names, e.g. draft,copy,final",
+			},
+			&cli.BoolFlag{
+				Name:  "unicode-names",
+				Usage: "keep letters outside ASCII, such as accents and non-Latin scripts, instead of transliterating them; scripts such as Chinese that cannot be transliterated are otherwise dropped",
+			},
+			&cli.StringFlag{
+				Name:  "name-language",
+				Value: "english",
+				Usage: "language of AI names for content not in English: english translates them, source keeps the content's language",
+			},
+			&cli.StringSliceFlag{
+				Name:  "name-template",
+				Usage: "build names from placeholders, e.g. {date:2006-01-02}_{author}_{slug}; prefix with a type, e.g. image={date|mtime}_{slug}, to apply to that type only; repeatable",
+			},
+			&cli.StringFlag{
+				Name:  "prompt-dir",
+				Value: cfg.PromptDir,
+				Usage: "directory of prompt templates (*.tmpl) and few-shot examples (examples/<type>.txt) that replace the built-in ones",
+			},
 			&cli.Float64Flag{
 				Name:  "min-confidence-to-copy",
 				Value: 0,
@@ -141,6 +219,10 @@ func runApp(args []string) error {
 				Name:  "review-note",
 				Usage: "review note update in source=note form",
 			},
+			&cli.StringSliceFlag{
+				Name:  "review-choose",
+				Usage: "accept AI alternative N for an entry in source=N form; numbers match the review report",
+			},
 			&cli.StringFlag{
 				Name:  "explain",
 				Usage: "explain the metadata filename suggestion for one file",
@@ -162,6 +244,108 @@ func runApp(args []string) error {
 				Name:  "disable-tika",
 				Usage: "disable Apache Tika fallback even when TIKA_URL is set",
 			},
+			&cli.BoolFlag{
+				Name:  "transcribe",
+				Usage: "transcribe the start of audio and video files that have no title tags",
+			},
+			&cli.StringFlag{
+				Name:  "whisper-url",
+				Value: cfg.WhisperURL,
+				Usage: "OpenAI-compatible base URL for /audio/transcriptions; local whisper.cpp is used when empty",
+			},
+			&cli.StringFlag{
+				Name:  "whisper-model",
+				Value: cfg.WhisperModel,
+				Usage: "whisper.cpp ggml model path, or model name for --whisper-url",
+			},
+			&cli.IntFlag{
+				Name:  "transcribe-seconds",
+				Value: 60,
+				Usage: "seconds of audio to transcribe from the start of each media file",
+			},
+			&cli.IntFlag{
+				Name:  "server-batch-size",
+				Value: cfg.ServerBatchSize,
+				Usage: "group up to this many relay-server AI calls into one /v1/batch request; 0 disables batching",
+			},
+			&cli.IntFlag{
+				Name:  "ai-attempts",
+				Value: cmp.Or(cfg.AIAttempts, ai.DefaultRetryPolicy.MaxAttempts),
+				Usage: "attempts per AI call; rate limits, timeouts, and bad responses are retried with backoff",
+			},
+			&cli.DurationFlag{
+				Name:  "ai-timeout",
+				Value: cmp.Or(cfg.AITimeout, ai.DefaultRetryPolicy.AttemptTimeout),
+				Usage: "time limit for each AI call attempt",
+			},
+			&cli.BoolFlag{
+				Name:  "no-cache",
+				Usage: "do not read or write the on-disk AI response cache",
+			},
+			&cli.StringFlag{
+				Name:  "cache-dir",
+				Value: cfg.CacheDir,
+				Usage: "directory for cached AI responses (default: ai-file-renamer/ai-cache under the user cache dir)",
+			},
+			&cli.DurationFlag{
+				Name:  "cache-ttl",
+				Value: cmp.Or(cfg.CacheTTL, ai.DefaultCacheTTL),
+				Usage: "how long cached AI responses are reused",
+			},
+			&cli.StringFlag{
+				Name:    "price-table",
+				EnvVars: []string{"AI_PRICE_TABLE"},
+				Usage:   "YAML file of per-model prices in US dollars per million input and output tokens, added to the built-in table",
+			},
+			&cli.Float64Flag{
+				Name:  "max-ai-budget",
+				Usage: "stop AI calls once their estimated cost reaches this many US dollars and keep metadata names for the remaining files; 0 means no limit",
+			},
+			&cli.StringFlag{
+				Name:  "journal",
+				Usage: "append a JSONL checkpoint entry as each file finishes",
+			},
+			&cli.StringFlag{
+				Name:  "resume",
+				Usage: "skip files already recorded in a journal and keep appending to it",
+			},
+			&cli.BoolFlag{
+				Name:  "journal-hash",
+				Usage: "record a SHA-256 of each source in the journal so resume can match files whose mtime changed",
+			},
+			&cli.StringFlag{
+				Name:  "dedupe",
+				Usage: "group byte-identical files and name one copy; duplicates are skipped, linked, or copied (skip, link, copy)",
+			},
+			&cli.Float64Flag{
+				Name:  "near-duplicates",
+				Usage: "cluster text files at or above this similarity (0-1) as versions of one document and name them -v1, -v2 by modified time; 0 disables",
+			},
+			&cli.BoolFlag{
+				Name:  "directory-context",
+				Usage: "name files together with their siblings so sets such as one vendor's invoices or one book's chapters share a pattern; waits for the whole run to be extracted",
+			},
+			&cli.BoolFlag{
+				Name:  "keyphrases",
+				Usage: "name long text files from keyphrases ranked by TF-IDF against the whole run, so a boilerplate first line does not name them; waits for the whole run to be extracted",
+			},
+			&cli.StringFlag{
+				Name:  "rules",
+				Usage: "YAML file of naming rules checked before heuristics and the AI; the first matching rule names the file",
+			},
+			&cli.BoolFlag{
+				Name:  "organize",
+				Usage: "copy files into category, year and topic folders such as photos/2019 or documents/finance instead of mirroring the input tree",
+			},
+			&cli.StringFlag{
+				Name:  "organize-rules",
+				Usage: "YAML file overriding the --organize layout, categories and topics; implies --organize",
+			},
+			&cli.IntFlag{
+				Name:  "workers",
+				Value: runtime.NumCPU(),
+				Usage: "number of concurrent workers per pipeline stage (extract, AI, copy)",
+			},
 			&cli.StringSliceFlag{ // allowed extensions. Overrides defaultFileTypes.
 				Name:  "types",
 				Value: cli.NewStringSlice(defaultFileTypes...),
@@ -174,7 +358,8 @@ func runApp(args []string) error {
 			output := c.String("output")
 			local := c.Bool("local")
 			model := c.String("model")
-			dry := c.Bool("dry-run")
+			plan := c.Bool("plan")
+			dry := c.Bool("dry-run") || plan
 			renameMode := c.Bool("rename")
 			debug := c.Bool("debug")
 			quiet := c.Bool("quiet")
@@ -183,6 +368,8 @@ func runApp(args []string) error {
 			strategy := c.String("strategy")
 			confidenceThreshold := c.Float64("confidence-threshold")
 			maxAIChars := c.Int("max-ai-chars")
+			aiCandidates := c.Int("ai-candidates")
+			visionModel := c.String("vision-model")
 			minConfidenceToCopy := c.Float64("min-confidence-to-copy")
 			reportPath := c.String("report")
 			applyReportPath := c.String("apply-report")
@@ -192,9 +379,51 @@ func runApp(args []string) error {
 			setReviewStatusPath := c.String("set-review-status")
 			reviewEntries := c.StringSlice("review-entry")
 			reviewNotes := c.StringSlice("review-note")
+			reviewChoices := c.StringSlice("review-choose")
 			explainPath := c.String("explain")
 			includeSkipped := c.Bool("include-skipped")
 			reviewReportPath := c.String("review-report")
+			workers := c.Int("workers")
+			journalPath := c.String("journal")
+			dedupe := c.String("dedupe")
+			nearDuplicates := c.Float64("near-duplicates")
+			resumePath := c.String("resume")
+			cfg.ServerBatchSize = c.Int("server-batch-size")
+			cfg.AIAttempts = c.Int("ai-attempts")
+			cfg.AITimeout = c.Duration("ai-timeout")
+			cfg.NoCache = c.Bool("no-cache")
+			cfg.CacheDir = c.String("cache-dir")
+			cfg.CacheTTL = c.Duration("cache-ttl")
+			cfg.PromptDir = c.String("prompt-dir")
+			naming := utils.NamingProfile{
+				Style:       c.String("naming"),
+				MaxLength:   c.Int("max-name-length"),
+				BannedWords: c.StringSlice("banned-words"),
+				Unicode:     c.Bool("unicode-names"),
+			}
+			if err := naming.Validate(); err != nil {
+				return err
+			}
+			nameLanguage := c.String("name-language")
+			if nameLanguage != "english" && nameLanguage != "source" {
+				return fmt.Errorf("invalid --name-language %q: use english or source", nameLanguage)
+			}
+			nameTemplates, err := analysis.ParseNameTemplates(c.StringSlice("name-template"))
+			if err != nil {
+				return err
+			}
+			rules, err := analysis.LoadNameRules(c.String("rules"))
+			if err != nil {
+				return err
+			}
+			var organize *analysis.OrganizeRules
+			if c.Bool("organize") || c.String("organize-rules") != "" {
+				organize, err = analysis.LoadOrganizeRules(c.String("organize-rules"))
+				if err != nil {
+					return err
+				}
+			}
+			maxAIBudget := c.Float64("max-ai-budget")
 			tikaURL := c.String("tika-url")
 			if c.Bool("disable-tika") {
 				tikaURL = ""
@@ -203,6 +432,17 @@ func runApp(args []string) error {
 			if err := extractors.ConfigureTika(tikaURL); err != nil {
 				return err
 			}
+			if c.Bool("transcribe") {
+				whisperCfg := cfg
+				whisperCfg.WhisperURL = c.String("whisper-url")
+				whisperCfg.WhisperModel = c.String("whisper-model")
+				transcriber, err := ai.NewWhisperClient(whisperCfg)
+				if err != nil {
+					return err
+				}
+				extractors.ConfigureTranscriber(transcriber, c.Int("transcribe-seconds"))
+				defer extractors.ConfigureTranscriber(nil, 0)
+			}
 
 			if quiet {
 				previous := log.Writer()
@@ -210,10 +450,10 @@ func runApp(args []string) error {
 				defer log.SetOutput(previous)
 			}
 			if explainPath != "" {
-				return explainFile(explainPath, os.Stdout)
+				return explainFile(explainPath, naming, nameTemplates, rules, os.Stdout)
 			}
 			if setReviewStatusPath != "" {
-				return updateReviewStatus(setReviewStatusPath, reviewEntries, reviewNotes)
+				return updateReviewStatus(setReviewStatusPath, reviewEntries, reviewNotes, reviewChoices)
 			}
 			if listPendingPath != "" {
 				return listPendingReport(listPendingPath, os.Stdout)
@@ -233,6 +473,33 @@ func runApp(args []string) error {
 			default:
 				return fmt.Errorf("invalid strategy %q: use auto, metadata-only, or ai-only", strategy)
 			}
+			if aiCandidates < 1 || aiCandidates > ai.MaxCandidates {
+				return fmt.Errorf("--ai-candidates must be between 1 and %d", ai.MaxCandidates)
+			}
+			switch dedupe {
+			case "", "skip", "copy":
+			case "link":
+				if renameMode {
+					return fmt.Errorf("--dedupe=link cannot be combined with --rename")
+				}
+			default:
+				return fmt.Errorf("invalid dedupe policy %q: use skip, link, or copy", dedupe)
+			}
+			if organize != nil && (renameMode || flatten) {
+				return fmt.Errorf("--organize cannot be combined with --rename or --flatten")
+			}
+			if nearDuplicates < 0 || nearDuplicates > 1 {
+				return fmt.Errorf("--near-duplicates must be between 0 and 1")
+			}
+			if workers < 1 {
+				return fmt.Errorf("invalid --workers %d: must be at least 1", workers)
+			}
+			if plan && (journalPath != "" || resumePath != "") {
+				return fmt.Errorf("--plan cannot be combined with --journal or --resume")
+			}
+			if maxAIBudget < 0 {
+				return fmt.Errorf("--max-ai-budget must not be negative")
+			}
 
 			// Build a set[string]struct{} for O(1) membership tests during walk.
 			types := make(map[string]struct{})
@@ -240,170 +507,140 @@ func runApp(args []string) error {
 				types[t] = struct{}{}
 			}
 
-			defaultModel := model
-			// set different default models based on local flag.
-			if model == "" && local {
-				defaultModel = "mistral"
-			} else if model == "" && !local {
-				defaultModel = "gpt-3.5-turbo"
+			if err := configureBackend(c, &cfg, local); err != nil {
+				return err
+			}
+			resolvedModel, price, err := modelPrice(cfg, local, model, c.String("price-table"), strategy != "metadata-only")
+			if err != nil {
+				return err
+			}
+			prompts, err := ai.LoadPrompts(cfg.PromptDir)
+			if err != nil {
+				return err
+			}
+			promptVersion := prompts.Version()
+			if ai.SelectBackend(cfg, local) == config.BackendServer {
+				promptVersion = ""
 			}
 
+			// Spin up LLM clients once; reused by all goroutines.
+			getAIClient := lazyClient(func() (ai.Client, error) { return newAIClient(cfg, local, model) })
 			var (
-				client     ai.Client
-				clientErr  error
-				clientOnce sync.Once
+				getVisionClient func() (ai.Client, error)
+				visionPrice     ai.Price
 			)
-			getAIClient := func() (ai.Client, error) {
-				clientOnce.Do(func() {
-					// Spin up LLM client once; reused by all goroutines.
-					client, clientErr = newAIClient(cfg, local, defaultModel)
-				})
-				return client, clientErr
-			}
-
-			// Concurrency helpers.
-			var wg sync.WaitGroup
-			errChan := make(chan error, 100) // buffered to avoid blocking goroutines.
-			var outputMu sync.Mutex
-			reservedPaths := map[string]struct{}{}
-			var reportMu sync.Mutex
-			reportEntries := []report.Entry{}
-
-			// processFile runs in its own goroutine per file.
-			processFile := func(info extractors.ExtractedFileInfo) {
-				defer wg.Done()
-
-				path := info.Path
-				suggestion := analysis.GenerateFilename(info)
-				suggested := suggestion.Filename
-				method := suggestion.Method
-				confidence := suggestion.Confidence
-				evidence := append([]string(nil), suggestion.Evidence...)
-
-				if strategy == "ai-only" || (strategy == "auto" && confidence < confidenceThreshold) {
-					client, err := getAIClient()
-					if err != nil {
-						errChan <- err
-						return
-					}
-
-					content := info.RawContent
-					if strategy == "auto" {
-						content = analysis.CompactEvidence(info, maxAIChars)
-						method = "ai-fallback"
-						log.Printf("[AI] %s local confidence %.2f below threshold %.2f; sending %d compact evidence chars\n", path, confidence, confidenceThreshold, len(content))
-					} else {
-						method = "ai-only"
-					}
-
-					var aiSuggested string
-					if strategy == "auto" {
-						aiSuggested, err = client.SuggestFilenameFromEvidence(content)
-					} else {
-						aiSuggested, err = client.SuggestFilename(content)
-					}
-					if err != nil {
-						errChan <- err
-						return
-					}
-					suggested = aiSuggested
-					confidence = 1
+			if visionModel != "" {
+				_, visionPrice, err = modelPrice(cfg, local, visionModel, c.String("price-table"), strategy != "metadata-only")
+				if err != nil {
+					return err
 				}
+				getVisionClient = lazyClient(func() (ai.Client, error) { return newAIClient(cfg, local, visionModel) })
+			}
+			if plan {
+				noAI := func() (ai.Client, error) {
+					return nil, errors.New("--plan never calls the AI backend")
+				}
+				getAIClient = noAI
+				if getVisionClient != nil {
+					getVisionClient = noAI
+				}
+			}
 
-				// Sanitize to avoid invalid characters.
-				sanitized := utils.Sanitize(suggested)
+			// Cancel in-flight extraction and AI calls on Ctrl-C; finished files
+			// still land in the report.
+			ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+			defer stop()
 
-				ext := filepath.Ext(path)
-				if info.SuggestedExtension != "" {
-					ext = "." + info.SuggestedExtension
+			var (
+				journal *report.Journal
+				resumed map[string]report.Entry
+			)
+			if resumePath != "" {
+				if journalPath == "" {
+					journalPath = resumePath
 				}
-
-				var destPath string
-				if renameMode {
-					planned := filepath.Join(filepath.Dir(path), sanitized+ext)
-					outputMu.Lock()
-					if dry {
-						destPath = utils.UniquePlannedPath(planned, reservedPaths)
-					} else {
-						destPath = utils.UniquePath(planned, reservedPaths)
-					}
-					outputMu.Unlock()
-				} else {
-					planned, err := utils.DestinationPath(input, path, output, sanitized, ext, flatten)
-					if err != nil {
-						errChan <- err
-						return
-					}
-					outputMu.Lock()
-					if dry {
-						destPath = utils.UniquePlannedPath(planned, reservedPaths)
-					} else {
-						destPath = utils.UniquePath(planned, reservedPaths)
-					}
-					outputMu.Unlock()
+				previous, err := report.ReadJournal(resumePath)
+				if err != nil {
+					return err
 				}
-
-				skipped := false
-				skipReason := ""
-				reviewStatus := ""
-				if !renameMode && minConfidenceToCopy > 0 && confidence < minConfidenceToCopy {
-					skipped = true
-					skipReason = fmt.Sprintf("confidence %.2f below copy threshold %.2f", confidence, minConfidenceToCopy)
-					reviewStatus = "pending"
+				resumed = make(map[string]report.Entry, len(previous))
+				for _, entry := range previous {
+					resumed[entry.SourcePath] = entry
 				}
-
-				reportMu.Lock()
-				reportEntries = append(reportEntries, report.Entry{
-					SourcePath:      path,
-					DestinationPath: destPath,
-					SuggestedName:   sanitized + ext,
-					Method:          method,
-					Confidence:      confidence,
-					Evidence:        evidence,
-					Warnings:        append([]string(nil), info.Warnings...),
-					DryRun:          dry,
-					Skipped:         skipped,
-					SkipReason:      skipReason,
-					ReviewStatus:    reviewStatus,
-				})
-				reportMu.Unlock()
-
-				// Depending on flags, perform or log the operation.
-				switch {
-				case dry && !renameMode:
-					log.Printf("[DRY] %s  →  %s method=%s confidence=%.2f\n", path, destPath, method, confidence)
-				case dry && renameMode:
-					log.Printf("[DRY] %s  →  %s method=%s confidence=%.2f\n", path, destPath, method, confidence)
-				case skipped:
-					log.Printf("[SKIP] %s  →  %s method=%s confidence=%.2f reason=%s\n", path, destPath, method, confidence, skipReason)
-				case !renameMode:
-					if err := utils.CopyFileToPath(path, destPath); err != nil {
-						errChan <- err
-					}
-				default: // rename in place
-					if err := utils.RenameFileWithExtension(input, path, sanitized, ext); err != nil {
-						errChan <- err
+				log.Printf("[RESUME] %d entries loaded from %s\n", len(resumed), resumePath)
+			}
+			if journalPath != "" {
+				// Appending to an old journal would merge its entries into
+				// this run's report.
+				if resumePath == "" {
+					if _, err := os.Stat(journalPath); err == nil {
+						return fmt.Errorf("journal %s already exists; pass --resume %s to continue it, or remove it to start over", journalPath, journalPath)
 					}
 				}
+				var err error
+				journal, err = report.OpenJournal(journalPath)
+				if err != nil {
+					return err
+				}
+				defer journal.Close()
 			}
 
-			// Walk the directory tree and dispatch work.
-			if err := extractors.WalkInfo(input, types, func(info extractors.ExtractedFileInfo) error {
-				wg.Add(1)
-				go processFile(info)
-				return nil // continue walking
-			}); err != nil {
-				return err
+			pipeline := newRenamePipeline(runOptions{
+				input:               input,
+				output:              output,
+				types:               types,
+				strategy:            strategy,
+				confidenceThreshold: confidenceThreshold,
+				maxAIChars:          maxAIChars,
+				aiCandidates:        aiCandidates,
+				minConfidenceToCopy: minConfidenceToCopy,
+				dry:                 dry,
+				renameMode:          renameMode,
+				flatten:             flatten,
+				workers:             workers,
+				journal:             journal,
+				journalHash:         c.Bool("journal-hash"),
+				resumed:             resumed,
+				dedupe:              dedupe,
+				nearDuplicates:      nearDuplicates,
+				directoryContext:    c.Bool("directory-context"),
+				keyphrases:          c.Bool("keyphrases"),
+				price:               price,
+				maxAIBudget:         maxAIBudget,
+				plan:                plan,
+				vision:              getVisionClient,
+				visionPrice:         visionPrice,
+				prompts:             prompts,
+				promptVersion:       promptVersion,
+				naming:              naming,
+				nameTemplates:       nameTemplates,
+				organize:            organize,
+				rules:               rules,
+				keepLanguage:        nameLanguage == "source",
+			}, getAIClient)
+			reportEntries, runErrs := pipeline.run(ctx)
+			interrupted := ctx.Err() != nil
+			if journal != nil {
+				// The journal also holds entries from earlier, resumed runs.
+				journaled, err := report.ReadJournal(journalPath)
+				if err != nil {
+					return err
+				}
+				reportEntries = journaled
 			}
 
-			// Wait for all processing to complete, then close channel so range terminates.
-			wg.Wait()
-			close(errChan)
-			sort.Slice(reportEntries, func(i, j int) bool {
-				return reportEntries[i].SourcePath < reportEntries[j].SourcePath
-			})
-
-			if err := report.Write(reportPath, reportEntries); err != nil {
+			summary := report.BuildSummary(reportEntries)
+			summary.Interrupted = interrupted
+			summary.AIBudgetSkippedCount = pipeline.budgetSkippedCount()
+			var runPlan *report.Plan
+			if plan {
+				planned := pipeline.planned()
+				planned.Model = resolvedModel
+				planned.Strategy = strategy
+				planned.ConfidenceThreshold = confidenceThreshold
+				runPlan = &planned
+			}
+			if err := report.WriteReport(reportPath, report.Report{Summary: summary, Plan: runPlan, Entries: reportEntries, Clusters: report.BuildClusters(reportEntries)}); err != nil {
 				return err
 			}
 			if reviewReportPath != "" {
@@ -411,35 +648,170 @@ func runApp(args []string) error {
 					return err
 				}
 			}
-			printRunSummary(report.BuildSummary(reportEntries), quiet, jsonSummary)
-
-			var (
-				firstErr error   // keeps behaviour for non‑debug
-				allErrs  []error // collects when debug
-			)
-
-			for e := range errChan {
-				if firstErr == nil {
-					firstErr = e
-				}
-				if debug {
-					allErrs = append(allErrs, e)
-				}
+			printRunSummary(summary, quiet, jsonSummary)
+			if runPlan != nil {
+				printPlan(*runPlan, quiet, jsonSummary)
 			}
 
+			if interrupted {
+				runErrs = append([]error{fmt.Errorf("run interrupted after %d files; report contains partial results: %w", len(reportEntries), ctx.Err())}, runErrs...)
+			}
 			switch {
-			case debug && len(allErrs) > 0:
-				return errors.Join(allErrs...)
+			case len(runErrs) == 0:
+				return nil
+			case debug:
+				return errors.Join(runErrs...)
 			default:
-				return firstErr
+				return runErrs[0]
 			}
 		},
+		Commands: []*cli.Command{
+			{
+				Name:  "cache",
+				Usage: "inspect or clear the AI response cache",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "stats",
+						Usage: "print the number, size, and age of cached AI responses",
+						Action: func(c *cli.Context) error {
+							return cacheStats(cacheConfig(c, cfg), os.Stdout)
+						},
+					},
+					{
+						Name:  "clear",
+						Usage: "remove cached AI responses",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "expired",
+								Usage: "only remove responses older than --cache-ttl",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return cacheClear(cacheConfig(c, cfg), c.Bool("expired"), os.Stdout)
+						},
+					},
+				},
+			},
+		},
 	}
 
 	// Kick everything off.
 	return app.Run(args)
 }
 
+// configureBackend loads --backend-config and applies the --backend flag and
+// the per-backend override flags to the selected backend's block.
+func configureBackend(c *cli.Context, cfg *config.Config, local bool) error {
+	cfg.Backend = c.String("backend")
+	if cfg.Backend != "" && !config.ValidBackend(cfg.Backend) {
+		return fmt.Errorf("invalid backend %q: use %s", cfg.Backend, strings.Join(config.Backends, ", "))
+	}
+	if local && cfg.Backend != "" && cfg.Backend != config.BackendOllama {
+		return fmt.Errorf("--local cannot be combined with --backend %s", cfg.Backend)
+	}
+	if path := c.String("backend-config"); path != "" {
+		backends, err := config.LoadBackends(path)
+		if err != nil {
+			return err
+		}
+		cfg.BackendConfigs = backends
+	}
+
+	backend := ai.SelectBackend(*cfg, local)
+	block := cfg.BackendConfigs[backend]
+	if c.IsSet("base-url") {
+		block.BaseURL = c.String("base-url")
+	}
+	if c.IsSet("api-key-env") {
+		block.APIKeyEnv = c.String("api-key-env")
+	}
+	if c.IsSet("temperature") {
+		temperature := c.Float64("temperature")
+		block.Temperature = &temperature
+	}
+	if c.IsSet("max-tokens") {
+		block.MaxTokens = c.Int("max-tokens")
+	}
+	if cfg.BackendConfigs == nil {
+		cfg.BackendConfigs = map[string]config.BackendConfig{}
+	}
+	cfg.BackendConfigs[backend] = block
+	return nil
+}
+
+// lazyClient calls newClient the first time the returned func is called and
+// hands every caller the same result.
+func lazyClient(newClient func() (ai.Client, error)) func() (ai.Client, error) {
+	var (
+		client ai.Client
+		err    error
+		once   sync.Once
+	)
+	return func() (ai.Client, error) {
+		once.Do(func() { client, err = newClient() })
+		return client, err
+	}
+}
+
+// modelPrice resolves the model the run will use and looks it up in the
+// price table. Hosted models missing from the table are estimated at $0,
+// which also means --max-ai-budget never triggers, so warn when the run may
+// call the AI.
+func modelPrice(cfg config.Config, local bool, model, priceTablePath string, usesAI bool) (string, ai.Price, error) {
+	prices, err := ai.LoadPriceTable(priceTablePath)
+	if err != nil {
+		return "", ai.Price{}, err
+	}
+	backend := ai.SelectBackend(cfg, local)
+	model = ai.ResolveModel(cfg, backend, model)
+	price, ok := prices.Lookup(model)
+	if !ok && usesAI && backend != config.BackendOllama {
+		log.Printf("[COST] no price for model %q; estimated costs are $0 until it is added with --price-table\n", model)
+	}
+	return model, price, nil
+}
+
+// cacheConfig applies the global cache flags, given before the cache
+// subcommand, to cfg.
+func cacheConfig(c *cli.Context, cfg config.Config) config.Config {
+	cfg.CacheDir = c.String("cache-dir")
+	cfg.CacheTTL = c.Duration("cache-ttl")
+	return cfg
+}
+
+func cacheStats(cfg config.Config, out io.Writer) error {
+	cache, err := ai.OpenConfiguredCache(cfg)
+	if err != nil {
+		return err
+	}
+	stats, err := cache.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "dir: %s\n", stats.Dir)
+	fmt.Fprintf(out, "entries: %d\n", stats.Entries)
+	fmt.Fprintf(out, "expired: %d\n", stats.Expired)
+	fmt.Fprintf(out, "bytes: %d\n", stats.Bytes)
+	if stats.Entries > 0 {
+		fmt.Fprintf(out, "oldest: %s\n", stats.Oldest.Format(time.RFC3339))
+		fmt.Fprintf(out, "newest: %s\n", stats.Newest.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func cacheClear(cfg config.Config, expiredOnly bool, out io.Writer) error {
+	cache, err := ai.OpenConfiguredCache(cfg)
+	if err != nil {
+		return err
+	}
+	removed, err := cache.Clear(expiredOnly)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Removed cached responses: %d\n", removed)
+	return nil
+}
+
 func applyReport(path string, dry bool, includeSkipped bool, reviewReportPath string, quiet bool, jsonSummary bool) error {
 	planned, err := report.Read(path)
 	if err != nil {
@@ -535,8 +907,9 @@ func undoReport(path string, dry bool, quiet bool, jsonSummary bool) error {
 
 func writeReviewFromEntries(path string, entries []report.Entry) error {
 	return report.WriteReviewMarkdown(path, report.Report{
-		Summary: report.BuildSummary(entries),
-		Entries: entries,
+		Summary:  report.BuildSummary(entries),
+		Entries:  entries,
+		Clusters: report.BuildClusters(entries),
 	})
 }
 
@@ -561,6 +934,12 @@ func listPendingReport(path string, out io.Writer) error {
 		if entry.SkipReason != "" {
 			fmt.Fprintf(out, "  reason: %s\n", entry.SkipReason)
 		}
+		if len(entry.Candidates) > 1 {
+			fmt.Fprintln(out, "  alternatives:")
+			for i, c := range entry.Candidates {
+				fmt.Fprintf(out, "    %d. %s (%.2f) %s\n", i+1, c.Name, c.Confidence, c.Rationale)
+			}
+		}
 	}
 	return nil
 }
@@ -569,7 +948,7 @@ func pendingEntries(entries []report.Entry) []report.Entry {
 	pending := []report.Entry{}
 	for _, entry := range entries {
 		status := report.NormalizeReviewStatus(entry.ReviewStatus)
-		if entry.Skipped && status == "pending" {
+		if entry.Skipped && status == "pending" && entry.DuplicateOf == "" {
 			pending = append(pending, entry)
 		}
 	}
@@ -579,9 +958,9 @@ func pendingEntries(entries []report.Entry) []report.Entry {
 	return pending
 }
 
-func updateReviewStatus(path string, updates []string, notes []string) error {
-	if len(updates) == 0 && len(notes) == 0 {
-		return fmt.Errorf("at least one --review-entry or --review-note is required")
+func updateReviewStatus(path string, updates []string, notes []string, choices []string) error {
+	if len(updates) == 0 && len(notes) == 0 && len(choices) == 0 {
+		return fmt.Errorf("at least one --review-entry, --review-note, or --review-choose is required")
 	}
 	planned, err := report.Read(path)
 	if err != nil {
@@ -620,11 +999,40 @@ func updateReviewStatus(path string, updates []string, notes []string) error {
 		planned.Entries = n.entries
 		changed += n.count
 	}
-	if err := report.Write(path, planned.Entries); err != nil {
+	for _, raw := range choices {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("invalid --review-choose %q: use source=N", raw)
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid --review-choose %q: use source=N", raw)
+		}
+		var chooseErr error
+		n, err := updateMatchingEntries(planned.Entries, key, func(entry *report.Entry) {
+			chooseErr = chooseCandidate(planned.Entries, entry, number)
+		})
+		if err != nil {
+			return err
+		}
+		if chooseErr != nil {
+			return chooseErr
+		}
+		planned.Entries = n.entries
+		changed += n.count
+	}
+	// Recount the entries but keep what only the run knew: its plan, whether
+	// it was interrupted, and how many files the AI budget skipped.
+	summary := report.BuildSummary(planned.Entries)
+	summary.Interrupted = planned.Summary.Interrupted
+	summary.AIBudgetSkippedCount = planned.Summary.AIBudgetSkippedCount
+	planned.Summary = summary
+	planned.Clusters = report.BuildClusters(planned.Entries)
+	if err := report.WriteReport(path, planned); err != nil {
 		return err
 	}
 	fmt.Fprintf(os.Stdout, "Updated review entries: %d\n", changed)
-	fmt.Fprintln(os.Stdout, formatSummary(report.BuildSummary(planned.Entries)))
+	fmt.Fprintln(os.Stdout, formatSummary(summary))
 	return nil
 }
 
@@ -651,6 +1059,35 @@ func updateMatchingEntries(entries []report.Entry, selector string, update func(
 	return updateResult{entries: entries, count: 1}, nil
 }
 
+// chooseCandidate renames entry to its numbered AI alternative (1-based) in
+// the same destination directory and accepts it.
+func chooseCandidate(entries []report.Entry, entry *report.Entry, number int) error {
+	// A copied or renamed file already has its name on disk; choosing only
+	// rewrites entries that have not been written yet.
+	if !entry.DryRun && !entry.Skipped {
+		return fmt.Errorf("%s was already written to %s; alternatives can only be chosen for dry-run or skipped entries", entry.SourcePath, entry.DestinationPath)
+	}
+	if number < 1 || number > len(entry.Candidates) {
+		return fmt.Errorf("%s has %d alternatives; cannot choose %d", entry.SourcePath, len(entry.Candidates), number)
+	}
+	candidate := entry.Candidates[number-1]
+	ext := filepath.Ext(entry.SuggestedName)
+	dest := filepath.Join(filepath.Dir(entry.DestinationPath), candidate.Name+ext)
+	for _, other := range entries {
+		if other.SourcePath != entry.SourcePath && other.DestinationPath == dest {
+			return fmt.Errorf("alternative %d for %s collides with %s", number, entry.SourcePath, other.SourcePath)
+		}
+	}
+	entry.SuggestedName = candidate.Name + ext
+	entry.DestinationPath = dest
+	entry.Confidence = candidate.Confidence
+	entry.ReviewStatus = "accepted"
+	if entry.Skipped {
+		entry.SkipReason = ""
+	}
+	return nil
+}
+
 func reportEntryMatches(entry report.Entry, selector string) bool {
 	return entry.SourcePath == selector ||
 		entry.DestinationPath == selector ||
@@ -658,12 +1095,17 @@ func reportEntryMatches(entry report.Entry, selector string) bool {
 		filepath.Base(entry.SourcePath) == selector
 }
 
-func explainFile(path string, out io.Writer) error {
+func explainFile(path string, naming utils.NamingProfile, templates analysis.NameTemplates, rules *analysis.NameRules, out io.Writer) error {
 	info, err := extractors.ExtractInfoForPath(path)
 	if err != nil {
 		return err
 	}
-	suggestion := analysis.GenerateFilename(info)
+	suggestion, ruled := rules.Match(info, naming)
+	name := suggestion.Filename
+	if !ruled {
+		suggestion = analysis.GenerateFilenameWith(info, naming)
+		name = formatName(suggestion.Filename, info, naming, templates)
+	}
 	ext := filepath.Ext(path)
 	if info.SuggestedExtension != "" {
 		ext = "." + info.SuggestedExtension
@@ -673,7 +1115,19 @@ func explainFile(path string, out io.Writer) error {
 	if info.SuggestedExtension != "" {
 		fmt.Fprintf(out, "suggested_extension: %s\n", info.SuggestedExtension)
 	}
-	fmt.Fprintf(out, "suggested_name: %s%s\n", suggestion.Filename, ext)
+	if lang := info.Metadata["language"]; lang != "" {
+		fmt.Fprintf(out, "language: %s\n", lang)
+	}
+	fmt.Fprintf(out, "suggested_name: %s%s\n", name, ext)
+	switch {
+	case ruled:
+		fmt.Fprintf(out, "rule: %s\n", strings.TrimPrefix(suggestion.Method, analysis.RuleMethodPrefix))
+	case rules != nil:
+		fmt.Fprintln(out, "rule: none matched")
+	}
+	if tmpl := templates.For(info); tmpl != nil && !ruled {
+		fmt.Fprintf(out, "name_template: %s\n", tmpl)
+	}
 	fmt.Fprintf(out, "method: %s\n", suggestion.Method)
 	fmt.Fprintf(out, "confidence: %.2f\n", suggestion.Confidence)
 	if len(suggestion.Evidence) > 0 {
@@ -704,7 +1158,7 @@ func trimExplain(s string, max int) string {
 }
 
 func formatSummary(summary report.Summary) string {
-	return fmt.Sprintf(
+	line := fmt.Sprintf(
 		"summary: total=%d planned=%d copied=%d skipped=%d pending_review=%d warnings=%d ai_fallback=%d",
 		summary.TotalFiles,
 		summary.PlannedCount,
@@ -714,6 +1168,13 @@ func formatSummary(summary report.Summary) string {
 		summary.WarningsCount,
 		summary.AIFallbackCount,
 	)
+	if summary.PromptTokens > 0 || summary.CompletionTokens > 0 {
+		line += fmt.Sprintf(" prompt_tokens=%d completion_tokens=%d estimated_cost=$%.4f", summary.PromptTokens, summary.CompletionTokens, summary.EstimatedCost)
+	}
+	if summary.AIBudgetSkippedCount > 0 {
+		line += fmt.Sprintf(" ai_budget_skipped=%d", summary.AIBudgetSkippedCount)
+	}
+	return line
 }
 
 func printRunSummary(summary report.Summary, quiet bool, jsonSummary bool) {
@@ -726,6 +1187,27 @@ func printRunSummary(summary report.Summary, quiet bool, jsonSummary bool) {
 	}
 }
 
+func printPlan(plan report.Plan, quiet bool, jsonSummary bool) {
+	if jsonSummary {
+		fmt.Fprintln(os.Stdout, jsonLine("plan", plan))
+		return
+	}
+	if quiet {
+		return
+	}
+	fmt.Fprintf(
+		os.Stdout,
+		"plan: model=%s below_threshold=%d ai_calls=%d evidence_chars=%d estimated_prompt_tokens=%d estimated_completion_tokens=%d estimated_cost=$%.4f\n",
+		plan.Model,
+		plan.BelowThreshold,
+		plan.AICalls,
+		plan.EvidenceChars,
+		plan.EstimatedPromptTokens,
+		plan.EstimatedCompletionTokens,
+		plan.EstimatedCost,
+	)
+}
+
 type operationSummary struct {
 	Total   int `json:"total"`
 	Applied int `json:"applied,omitempty"`
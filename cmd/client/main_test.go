@@ -3,20 +3,27 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/analysis"
 	"github.com/djblackett/bootdev-hackathon/internal/config"
 	"github.com/djblackett/bootdev-hackathon/internal/report"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
 )
 
 func TestClientDryRunReportRecoveredCorpus(t *testing.T) {
@@ -486,7 +493,7 @@ func TestUpdateReviewStatus(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := updateReviewStatus(reportPath, []string{"pending.txt=accepted"}, []string{"pending.txt=looks good"}); err != nil {
+	if err := updateReviewStatus(reportPath, []string{"pending.txt=accepted"}, []string{"pending.txt=looks good"}, nil); err != nil {
 		t.Fatal(err)
 	}
 
@@ -503,6 +510,115 @@ func TestUpdateReviewStatus(t *testing.T) {
 	}
 }
 
+func TestUpdateReviewStatusKeepsRunSummaryAndPlan(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	entries := []report.Entry{{
+		SourcePath:      "input/pending.txt",
+		DestinationPath: "output/pending.txt",
+		Skipped:         true,
+		DryRun:          true,
+	}}
+	summary := report.BuildSummary(entries)
+	summary.Interrupted = true
+	summary.AIBudgetSkippedCount = 3
+	plan := &report.Plan{Model: "gpt-4o", Strategy: "auto", AICalls: 4}
+	if err := report.WriteReport(reportPath, report.Report{Summary: summary, Plan: plan, Entries: entries}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updateReviewStatus(reportPath, []string{"pending.txt=accepted"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readReport(t, reportPath)
+	if !got.Summary.Interrupted || got.Summary.AIBudgetSkippedCount != 3 {
+		t.Fatalf("summary = %+v, want the run's interrupted flag and budget count", got.Summary)
+	}
+	if got.Summary.AcceptedCount != 1 || got.Summary.PendingReviewCount != 0 {
+		t.Fatalf("summary = %+v, want the review counts recomputed", got.Summary)
+	}
+	if got.Plan == nil || *got.Plan != *plan {
+		t.Fatalf("plan = %+v, want %+v", got.Plan, plan)
+	}
+}
+
+func TestUpdateReviewStatusChoosesAlternative(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := report.Write(reportPath, []report.Entry{
+		{
+			SourcePath:      "input/scan.txt",
+			DestinationPath: "output/budget-notes.txt",
+			SuggestedName:   "budget-notes.txt",
+			Confidence:      0.35,
+			Skipped:         true,
+			SkipReason:      "confidence 0.35 below copy threshold 0.75",
+			ReviewStatus:    "pending",
+			Candidates: []report.Candidate{
+				{Name: "budget-notes", Confidence: 0.35},
+				{Name: "north-region-budget-review", Confidence: 0.3, Rationale: "region named in heading"},
+			},
+		},
+		{
+			SourcePath:      "input/other.txt",
+			DestinationPath: "output/taken-name.txt",
+			Candidates: []report.Candidate{
+				{Name: "taken-name", Confidence: 0.9},
+				{Name: "other-name", Confidence: 0.5},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updateReviewStatus(reportPath, nil, nil, []string{"scan.txt=2"}); err != nil {
+		t.Fatal(err)
+	}
+	entry := readReport(t, reportPath).Entries[0]
+	if entry.DestinationPath != filepath.Join("output", "north-region-budget-review.txt") || entry.SuggestedName != "north-region-budget-review.txt" {
+		t.Fatalf("entry = %+v, want the second alternative", entry)
+	}
+	if entry.ReviewStatus != "accepted" || entry.SkipReason != "" || entry.Confidence != 0.3 {
+		t.Fatalf("entry = %+v, want accepted with the alternative's confidence", entry)
+	}
+
+	if err := updateReviewStatus(reportPath, nil, nil, []string{"scan.txt=3"}); err == nil {
+		t.Fatal("expected an error for a missing alternative")
+	}
+	if err := updateReviewStatus(reportPath, nil, nil, []string{"other.txt=2"}); err == nil {
+		t.Fatal("expected an error choosing an alternative for a copied file")
+	}
+	if got := readReport(t, reportPath).Entries[1]; got.DestinationPath != "output/taken-name.txt" {
+		t.Fatalf("copied entry = %+v, want it unchanged", got)
+	}
+}
+
+func TestCacheSubcommands(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := ai.OpenCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ai.WithCache(&fakeClient{filename: "cached-name"}, cache, "openai", "gpt-4o", nil)
+	if _, err := client.SuggestFilenameFromEvidence(context.Background(), "evidence", ai.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := cacheStats(config.Config{CacheDir: dir}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "entries: 1\n") {
+		t.Fatalf("stats output = %q", out.String())
+	}
+
+	if err := runApp([]string{"ai-file-renamer", "--cache-dir", dir, "cache", "clear"}); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := cache.Stats(); err != nil || stats.Entries != 0 {
+		t.Fatalf("stats after clear = %+v, %v", stats, err)
+	}
+}
+
 func TestJSONSummaryLine(t *testing.T) {
 	line := jsonLine("summary", report.Summary{TotalFiles: 3, PlannedCount: 2, SkippedCount: 1})
 	var got map[string]any
@@ -560,7 +676,7 @@ func TestExplainFile(t *testing.T) {
 	}
 
 	var out bytes.Buffer
-	if err := explainFile(path, &out); err != nil {
+	if err := explainFile(path, utils.DefaultProfile, nil, nil, &out); err != nil {
 		t.Fatal(err)
 	}
 
@@ -572,6 +688,40 @@ func TestExplainFile(t *testing.T) {
 	}
 }
 
+func TestExplainFileShowsMatchingRule(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.csv")
+	if err := os.WriteFile(path, []byte("invoice_id,customer,total\nINV-1042,Acme Corp,99.50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rulesPath, []byte(`rules:
+  - name: invoice-export
+    types: [csv]
+    metadata:
+      headers: '\binvoice_id\b'
+      first_row: '^(?P<invoice>[^,]+)'
+    template: "invoice-{invoice}"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := analysis.LoadNameRules(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := explainFile(path, utils.DefaultProfile, nil, rules, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"suggested_name: invoice-inv-1042.csv", "rule: invoice-export", "method: rule:invoice-export", "evidence: type, metadata:first_row, metadata:headers"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("explain output missing %q:\n%s", want, got)
+		}
+	}
+}
+
 func TestClientDryRunReportInputCorpus(t *testing.T) {
 	root := repoRoot(t)
 	outputDir := t.TempDir()
@@ -693,6 +843,219 @@ func TestAutoFallbackUsesFakeAIForLowConfidenceOnly(t *testing.T) {
 	}
 }
 
+func TestAIReportsModelConfidenceAndAlternatives(t *testing.T) {
+	root := repoRoot(t)
+	outDir := t.TempDir()
+	reportPath := filepath.Join(outDir, "report.json")
+	reviewPath := filepath.Join(outDir, "review.md")
+	withFakeAI(t, &fakeClient{filename: "vague-guess", confidence: 0.4})
+
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "auto",
+		"--dry-run",
+		"--input", filepath.Join(root, "testdata/recovered"),
+		"--output", filepath.Join(outDir, "out"),
+		"--types", "text",
+		"--min-confidence-to-copy", "0.75",
+		"--report", reportPath,
+		"--review-report", reviewPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := entriesByBase(readReport(t, reportPath))["random.txt"]
+	if entry.Confidence != 0.4 || !entry.Skipped {
+		t.Fatalf("entry = %+v, want model confidence 0.4 and a skip below the copy threshold", entry)
+	}
+	if len(entry.Candidates) != 2 || entry.Candidates[1].Name != "vague-guess-alt" {
+		t.Fatalf("candidates = %+v", entry.Candidates)
+	}
+	review, err := os.ReadFile(reviewPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(review), "2. `vague-guess-alt` (0.20)") {
+		t.Fatalf("review report does not list alternatives:\n%s", review)
+	}
+}
+
+func TestBackendFlagsReachAIClientConfig(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "note.txt"), []byte("quarterly budget review for the north region"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		gotCfg   config.Config
+		gotModel string
+	)
+	newAIClient = func(cfg config.Config, _ bool, model string) (ai.Client, error) {
+		gotCfg, gotModel = cfg, model
+		return &fakeClient{filename: "named"}, nil
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--backend", "openai-compatible",
+		"--base-url", "http://localhost:1234/v1",
+		"--temperature", "0",
+		"--model", "qwen2.5-7b-instruct",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	block := gotCfg.BackendConfigs["openai-compatible"]
+	if gotCfg.Backend != "openai-compatible" || block.BaseURL != "http://localhost:1234/v1" || gotModel != "qwen2.5-7b-instruct" {
+		t.Fatalf("cfg = %+v model = %q", gotCfg, gotModel)
+	}
+	if block.Temperature == nil || *block.Temperature != 0 {
+		t.Fatalf("temperature = %v, want an explicit 0", block.Temperature)
+	}
+
+	err := runApp([]string{"ai-file-renamer", "--local", "--backend", "anthropic", "--input", inputDir})
+	if err == nil || !strings.Contains(err.Error(), "--local") {
+		t.Fatalf("err = %v, want --local conflict", err)
+	}
+}
+
+func TestPromptDirOverridesPromptsAndStampsVersion(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "ledger.csv"), []byte("date,account,amount\n2022-01-03,checking,-84.12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	promptDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(promptDir, "candidates.tmpl"), []byte("HOUSE STYLE {{.Count}}\n{{.Content}}\n{{.Examples}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var prompts []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"response": `{"candidates":[{"filename":"checking-ledger-2022","confidence":0.9}]}`})
+	}))
+	defer server.Close()
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--no-cache",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--backend", "ollama",
+		"--base-url", server.URL,
+		"--prompt-dir", promptDir,
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || !strings.HasPrefix(prompts[0], "HOUSE STYLE 3") || !strings.Contains(prompts[0], "2022-checking-account-transactions-by-category") {
+		t.Fatalf("prompts = %q, want the override with the spreadsheet examples", prompts)
+	}
+	got := readReport(t, reportPath)
+	if len(got.Entries) != 1 || !strings.HasPrefix(got.Entries[0].PromptVersion, ai.PromptVersion+"+") {
+		t.Fatalf("entries = %+v, want the overridden prompt version", got.Entries)
+	}
+
+	err := runApp([]string{"ai-file-renamer", "--dry-run", "--input", inputDir, "--prompt-dir", filepath.Join(promptDir, "missing")})
+	if err == nil || !strings.Contains(err.Error(), "prompt dir") {
+		t.Fatalf("err = %v, want a prompt dir error", err)
+	}
+}
+
+func TestNamingProfileShapesAINames(t *testing.T) {
+	inputDir := t.TempDir()
+	path := filepath.Join(inputDir, "scan.txt")
+	if err := os.WriteFile(path, []byte("notes from the quarterly budget review"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2024, 3, 9, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeClient{filename: "Quarterly Budget Review Document"}
+	withFakeAI(t, fake)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--naming", "snake"}, "quarterly_budget_review.txt"},
+		{[]string{"--naming", "title"}, "Quarterly Budget Review.txt"},
+		{[]string{"--naming", "camel", "--banned-words", "quarterly"}, "budgetReview.txt"},
+		{[]string{"--naming", "date-prefix"}, "2024-03-09-quarterly-budget-review.txt"},
+		{[]string{"--max-name-length", "16"}, "quarterly-budget.txt"},
+	} {
+		reportPath := filepath.Join(t.TempDir(), "report.json")
+		args := append([]string{
+			"ai-file-renamer",
+			"--strategy", "ai-only",
+			"--dry-run",
+			"--quiet",
+			"--input", inputDir,
+			"--output", t.TempDir(),
+			"--report", reportPath,
+		}, tc.args...)
+		if err := runApp(args); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		assertSuggestedName(t, entriesByBase(readReport(t, reportPath)), "scan.txt", tc.want)
+	}
+
+	err := runApp([]string{"ai-file-renamer", "--dry-run", "--input", inputDir, "--naming", "screaming"})
+	if err == nil || !strings.Contains(err.Error(), "unknown naming style") {
+		t.Fatalf("err = %v, want an unknown naming style error", err)
+	}
+}
+
+func TestNameTemplatesFillMetadataPlaceholders(t *testing.T) {
+	inputDir := t.TempDir()
+	email := "From: Jane Doe <jane@example.com>\r\nDate: Thu, 14 Sep 2023 09:12:00 -0400\r\nSubject: Vendor contract renewal\r\n\r\nPlease review the attached renewal terms.\r\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "0042.eml"), []byte(email), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "notes.md"), []byte("# Incident Response Runbook\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "metadata-only",
+		"--dry-run",
+		"--quiet",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--report", reportPath,
+		"--name-template", "{slug}",
+		"--name-template", "email={date:2006-01-02}_{author}_{slug}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := entriesByBase(readReport(t, reportPath))
+	assertSuggestedName(t, entries, "0042.eml", "2023-09-14_jane-doe_vendor-contract-renewal.eml")
+	assertSuggestedName(t, entries, "notes.md", "incident-response-runbook.md")
+
+	err = runApp([]string{"ai-file-renamer", "--dry-run", "--input", inputDir, "--name-template", "{date"})
+	if err == nil || !strings.Contains(err.Error(), "unclosed {") {
+		t.Fatalf("err = %v, want an unclosed placeholder error", err)
+	}
+}
+
 func TestMetadataOnlyDoesNotCreateAIClient(t *testing.T) {
 	root := repoRoot(t)
 	reportPath := filepath.Join(t.TempDir(), "report.json")
@@ -899,21 +1262,52 @@ func writeClientZip(t *testing.T, path string, files map[string]string) {
 }
 
 type fakeClient struct {
+	mu            sync.Mutex
 	filename      string
 	rawCalls      int
 	evidenceCalls int
 	lastEvidence  string
+	confidence    float64
+	lastImage     ai.Image
+	imageCalls    int
 }
 
-func (f *fakeClient) SuggestFilename(string) (string, error) {
+func (f *fakeClient) SuggestFilename(context.Context, string, ai.Options) (ai.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.rawCalls++
-	return f.filename, nil
+	return ai.Result{Filename: f.filename}, nil
 }
 
-func (f *fakeClient) SuggestFilenameFromEvidence(evidence string) (string, error) {
+func (f *fakeClient) SuggestFilenameFromEvidence(_ context.Context, evidence string, _ ai.Options) (ai.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.evidenceCalls++
 	f.lastEvidence = evidence
-	return f.filename, nil
+	return ai.Result{Filename: f.filename}, nil
+}
+
+func (f *fakeClient) SuggestFilenameCandidates(ctx context.Context, evidence string, _ int, opts ai.Options) (ai.Result, error) {
+	result, err := f.SuggestFilenameFromEvidence(ctx, evidence, opts)
+	if err != nil {
+		return ai.Result{}, err
+	}
+	confidence := f.confidence
+	if confidence == 0 {
+		confidence = 1
+	}
+	result.Candidates = []ai.Candidate{{Filename: result.Filename, Confidence: confidence}, {Filename: result.Filename + "-alt", Confidence: confidence / 2}}
+	return result, nil
+}
+
+func (f *fakeClient) SuggestFilenameFromImage(_ context.Context, image ai.Image, evidence string, _ int, _ ai.Options) (ai.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.imageCalls++
+	f.lastImage = image
+	f.lastEvidence = evidence
+	name := "pictured-" + f.filename
+	return ai.Result{Filename: name, Candidates: []ai.Candidate{{Filename: name, Confidence: 0.8}}}, nil
 }
 
 func withFakeAI(t *testing.T, fake *fakeClient) {
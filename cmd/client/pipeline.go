@@ -0,0 +1,904 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/analysis"
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/report"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+type runOptions struct {
+	input               string
+	output              string
+	types               map[string]struct{}
+	strategy            string
+	confidenceThreshold float64
+	maxAIChars          int
+	aiCandidates        int
+	minConfidenceToCopy float64
+	dry                 bool
+	renameMode          bool
+	flatten             bool
+	workers             int
+
+	// journal, when set, receives each entry as its file finishes. Files in
+	// resumed that have not changed since they were journaled are skipped;
+	// a real run redoes files an earlier dry run journaled.
+	journal     *report.Journal
+	journalHash bool
+	resumed     map[string]report.Entry
+
+	// dedupe is "", "skip", "link" or "copy". When set, the walk finishes
+	// before extraction so byte-identical files can be grouped.
+	dedupe string
+
+	// nearDuplicates is the MinHash similarity at which extracted files are
+	// treated as versions of one document; 0 disables the pass.
+	nearDuplicates float64
+
+	// directoryContext waits for every file to be extracted and adds
+	// evidence its siblings share, so a folder of one kind of file is named
+	// consistently.
+	directoryContext bool
+
+	// keyphrases waits for every file to be extracted and adds keyphrases
+	// ranked against the whole run to long text files.
+	keyphrases bool
+
+	// price turns each file's token usage into an estimated cost. Once the
+	// costs add up to maxAIBudget dollars, files keep their metadata name;
+	// 0 means no limit.
+	price       ai.Price
+	maxAIBudget float64
+
+	// plan stops before every AI call and adds up what the call would have
+	// sent instead; files keep their metadata names.
+	plan bool
+
+	// vision, when set, returns the client for a vision-capable model. Images
+	// and scanned PDFs that need AI are then named from a thumbnail together
+	// with their evidence, at visionPrice.
+	vision      func() (ai.Client, error)
+	visionPrice ai.Price
+
+	// prompts estimates --plan calls, and promptVersion is stamped on
+	// AI-named entries; it is empty when the relay server renders prompts.
+	prompts       *ai.Prompts
+	promptVersion string
+
+	// naming formats every name, local or AI, and tells the AI which
+	// convention to follow. The zero value is kebab-case.
+	naming utils.NamingProfile
+	// keepLanguage asks the AI to name files in their content's language
+	// instead of translating names to English.
+	keepLanguage bool
+
+	// nameTemplates, when set, build final names from metadata placeholders
+	// around the descriptive name; see analysis.NameTemplate.
+	nameTemplates analysis.NameTemplates
+	// rules, when set, name matching files before heuristics or the AI.
+	rules *analysis.NameRules
+
+	// organize, when set, files copies into category, date and topic
+	// folders under output instead of mirroring the input tree.
+	organize *analysis.OrganizeRules
+}
+
+// namedFile carries one file from the naming stage to the copy stage.
+type namedFile struct {
+	info       extractors.ExtractedFileInfo
+	suggested  string
+	method     string
+	confidence float64
+	evidence   []string
+	candidates []report.Candidate
+	usage      ai.Usage
+	cost       float64
+	// promptVersion is set when the name came from an AI call.
+	promptVersion string
+	// topic is the organize topic, from local rules or the AI.
+	topic string
+
+	versionGroup string
+	version      int
+}
+
+// renamePipeline runs walk -> extract -> name -> copy with a bounded number of
+// workers per stage. Stages are joined by channels sized to the worker count,
+// so a slow AI backend or disk applies backpressure all the way to the walk.
+type renamePipeline struct {
+	opts        runOptions
+	getAIClient func() (ai.Client, error)
+
+	outputMu      sync.Mutex
+	reservedPaths map[string]struct{}
+	// journaledOutputs are files an earlier, resumed run wrote. The walk
+	// skips them so a rename run does not name its own outputs again.
+	journaledOutputs map[string]struct{}
+
+	reportMu      sync.Mutex
+	reportEntries []report.Entry
+
+	errMu sync.Mutex
+	errs  []error
+
+	// duplicates maps each canonical source path to its byte-identical copies.
+	duplicates map[string][]string
+
+	// versions maps the file named for each near-duplicate cluster to all of
+	// the cluster's members, oldest first. groupNames is guarded by outputMu.
+	versions   map[string][]extractors.ExtractedFileInfo
+	groupNames map[string]struct{}
+
+	budgetMu      sync.Mutex
+	spent         float64
+	budgetSkipped int
+
+	planMu sync.Mutex
+	plan   report.Plan
+}
+
+// resumes reports whether the journaled entry counts as done in this run. A
+// dry-run entry copied nothing, so only another dry run may skip its file.
+func (opts runOptions) resumes(entry report.Entry) bool {
+	return !entry.DryRun || opts.dry
+}
+
+func newRenamePipeline(opts runOptions, getAIClient func() (ai.Client, error)) *renamePipeline {
+	if opts.workers <= 0 {
+		opts.workers = 1
+	}
+	reserved := map[string]struct{}{}
+	outputs := map[string]struct{}{}
+	for _, entry := range opts.resumed {
+		if entry.DestinationPath != "" && opts.resumes(entry) {
+			reserved[entry.DestinationPath] = struct{}{}
+		}
+		if entry.DestinationPath != "" && !entry.DryRun && !entry.Skipped {
+			outputs[filepath.Clean(entry.DestinationPath)] = struct{}{}
+		}
+	}
+	return &renamePipeline{
+		opts:             opts,
+		getAIClient:      getAIClient,
+		reservedPaths:    reserved,
+		journaledOutputs: outputs,
+		reportEntries:    []report.Entry{},
+		groupNames:       map[string]struct{}{},
+	}
+}
+
+// run processes every supported file under opts.input. It returns the report
+// entries for all files that finished, sorted by source path, together with
+// per-file errors. When ctx is cancelled the entries are partial.
+func (p *renamePipeline) run(ctx context.Context) ([]report.Entry, []error) {
+	paths := make(chan string, p.opts.workers)
+	infos := make(chan extractors.ExtractedFileInfo, p.opts.workers)
+	named := make(chan namedFile, p.opts.workers)
+
+	go func() {
+		defer close(paths)
+		emit := func(path string) error {
+			select {
+			case paths <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		var collected []string
+		err := extractors.WalkFiles(ctx, p.opts.input, p.opts.types, func(path string) error {
+			if entry, ok := p.opts.resumed[path]; ok && p.opts.resumes(entry) && report.Unchanged(entry, path) {
+				log.Printf("[RESUME] %s already journaled as %s\n", path, entry.DestinationPath)
+				return nil
+			}
+			if _, ok := p.journaledOutputs[filepath.Clean(path)]; ok {
+				log.Printf("[RESUME] %s was written by a journaled run\n", path)
+				return nil
+			}
+			if p.opts.dedupe != "" {
+				collected = append(collected, path)
+				return nil
+			}
+			return emit(path)
+		})
+		if err == nil && p.opts.dedupe != "" {
+			err = p.emitUnique(ctx, collected, emit)
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			p.addError(err)
+		}
+	}()
+
+	p.stage(func() { close(infos) }, func() {
+		for path := range paths {
+			if ctx.Err() != nil {
+				continue
+			}
+			info, err := extractors.ExtractFileInfo(ctx, path)
+			if err != nil {
+				p.addError(fmt.Errorf("%s: %w", path, err))
+				p.skipDuplicates(path, err)
+				continue
+			}
+			select {
+			case infos <- info:
+			case <-ctx.Done():
+			}
+		}
+	})
+
+	keyphrased := infos
+	if p.opts.keyphrases {
+		keyphrased = make(chan extractors.ExtractedFileInfo, p.opts.workers)
+		go p.addKeyphrases(ctx, infos, keyphrased)
+	}
+
+	contextual := keyphrased
+	if p.opts.directoryContext {
+		contextual = make(chan extractors.ExtractedFileInfo, p.opts.workers)
+		go p.addDirectoryContext(ctx, keyphrased, contextual)
+	}
+
+	versioned := contextual
+	if p.opts.nearDuplicates > 0 {
+		versioned = make(chan extractors.ExtractedFileInfo, p.opts.workers)
+		go p.clusterVersions(ctx, contextual, versioned)
+	}
+
+	p.stage(func() { close(named) }, func() {
+		for info := range versioned {
+			if ctx.Err() != nil {
+				continue
+			}
+			file, err := p.name(ctx, info)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.addError(err)
+					if members := p.versions[info.Path]; len(members) > 0 {
+						p.placeUnversioned(members, err)
+					} else {
+						p.skipDuplicates(info.Path, err)
+					}
+				}
+				continue
+			}
+			select {
+			case named <- file:
+			case <-ctx.Done():
+			}
+		}
+	})
+
+	finished := make(chan struct{})
+	p.stage(func() { close(finished) }, func() {
+		for file := range named {
+			p.placeVersions(file)
+		}
+	})
+	<-finished
+
+	sort.Slice(p.reportEntries, func(i, j int) bool {
+		return p.reportEntries[i].SourcePath < p.reportEntries[j].SourcePath
+	})
+	return p.reportEntries, p.errs
+}
+
+// emitUnique groups paths by content and emits one path per group. The rest
+// are placed alongside their canonical copy by placeDuplicates. Files that
+// cannot be read are emitted on their own, so extraction reports them.
+func (p *renamePipeline) emitUnique(ctx context.Context, paths []string, emit func(string) error) error {
+	groups, unreadable, err := utils.FindDuplicates(ctx, paths)
+	if err != nil {
+		return err
+	}
+	for path, err := range unreadable {
+		log.Printf("[DEDUPE] %s: %v; not checked for duplicates\n", path, err)
+	}
+	duplicate := map[string]struct{}{}
+	for _, dups := range groups {
+		for _, dup := range dups {
+			duplicate[dup] = struct{}{}
+		}
+	}
+	p.duplicates = groups
+	log.Printf("[DEDUPE] %d duplicates across %d groups\n", len(duplicate), len(groups))
+
+	for _, path := range paths {
+		if _, ok := duplicate[path]; ok {
+			continue
+		}
+		if err := emit(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addKeyphrases waits for every extracted file and forwards each with the
+// keyphrases that set its body apart from the rest of the run.
+func (p *renamePipeline) addKeyphrases(ctx context.Context, in <-chan extractors.ExtractedFileInfo, out chan<- extractors.ExtractedFileInfo) {
+	defer close(out)
+	var all []extractors.ExtractedFileInfo
+	for info := range in {
+		all = append(all, info)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	all = analysis.AddKeyphrases(all)
+	found := 0
+	for _, info := range all {
+		if slices.ContainsFunc(info.TextSamples, func(s extractors.TextSample) bool { return s.Source == analysis.KeyphrasesSource }) {
+			found++
+		}
+	}
+	log.Printf("[KEYPHRASES] %d of %d files have keyphrases\n", found, len(all))
+
+	for _, info := range all {
+		select {
+		case out <- info:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// addDirectoryContext waits for every extracted file and forwards each with
+// the directory-context evidence its siblings give it.
+func (p *renamePipeline) addDirectoryContext(ctx context.Context, in <-chan extractors.ExtractedFileInfo, out chan<- extractors.ExtractedFileInfo) {
+	defer close(out)
+	var all []extractors.ExtractedFileInfo
+	for info := range in {
+		all = append(all, info)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	all = analysis.AddDirectoryContext(all)
+	named := 0
+	for _, info := range all {
+		if slices.ContainsFunc(info.TextSamples, func(s extractors.TextSample) bool { return s.Source == analysis.DirectoryContextSource }) {
+			named++
+		}
+	}
+	log.Printf("[CONTEXT] %d of %d files share directory context with their siblings\n", named, len(all))
+
+	for _, info := range all {
+		select {
+		case out <- info:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// clusterVersions waits for every extracted file, groups near-duplicates, and
+// forwards one file per cluster to be named: the newest version, which best
+// reflects what the document is now.
+func (p *renamePipeline) clusterVersions(ctx context.Context, in <-chan extractors.ExtractedFileInfo, out chan<- extractors.ExtractedFileInfo) {
+	defer close(out)
+	var all []extractors.ExtractedFileInfo
+	for info := range in {
+		all = append(all, info)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	clusters := analysis.ClusterNearDuplicates(all, p.opts.nearDuplicates)
+	versions := map[string][]extractors.ExtractedFileInfo{}
+	member := map[string]struct{}{}
+	for _, cluster := range clusters {
+		versions[cluster[len(cluster)-1].Path] = cluster
+		for _, info := range cluster {
+			member[info.Path] = struct{}{}
+		}
+	}
+	p.versions = versions
+	log.Printf("[VERSIONS] %d near-duplicate files across %d clusters\n", len(member), len(clusters))
+
+	for _, info := range all {
+		if _, ok := member[info.Path]; ok {
+			if _, named := versions[info.Path]; !named {
+				continue
+			}
+		}
+		select {
+		case out <- info:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stage starts opts.workers copies of work and calls done once all of them
+// have returned.
+func (p *renamePipeline) stage(done func(), work func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		done()
+	}()
+}
+
+func (p *renamePipeline) name(ctx context.Context, info extractors.ExtractedFileInfo) (namedFile, error) {
+	file, ruled := p.localName(info)
+	if ruled {
+		// Rules are deterministic overrides; neither the AI nor
+		// --name-template second-guesses them.
+		return file, nil
+	}
+
+	strategy := p.opts.strategy
+	if p.opts.plan && file.confidence < p.opts.confidenceThreshold {
+		p.planBelowThreshold()
+	}
+	if strategy != "ai-only" && (strategy != "auto" || file.confidence >= p.opts.confidenceThreshold) {
+		return file, nil
+	}
+
+	content := info.RawContent
+	if strategy == "auto" {
+		content = analysis.CompactEvidence(info, p.opts.maxAIChars)
+	}
+	opts := ai.Options{
+		// The file type picks the prompt's few-shot examples.
+		FileTypes:    []string{info.Extension, info.DetectedType, info.Metadata["detected_subtype"]},
+		Naming:       p.opts.naming.Describe(),
+		Language:     info.Metadata["language"],
+		KeepLanguage: p.opts.keepLanguage,
+	}
+	if p.opts.organize != nil && file.topic == "" {
+		// The naming call picks a topic too when the local rules found none.
+		opts.Topics = p.opts.organize.TopicNames()
+	}
+	vision := p.opts.vision != nil && extractors.WantsThumbnail(info)
+	if p.opts.plan {
+		log.Printf("[PLAN] %s local confidence %.2f; would send %d chars to AI\n", info.Path, file.confidence, len(content))
+		p.planCall(content, vision, opts)
+		return file, nil
+	}
+
+	if !p.withinBudget() {
+		log.Printf("[BUDGET] %s: --max-ai-budget $%.2f reached; keeping metadata name\n", info.Path, p.opts.maxAIBudget)
+		file.info.Warnings = append(append([]string(nil), info.Warnings...), "AI budget exhausted; kept metadata name")
+		return file, nil
+	}
+
+	getClient, price := p.getAIClient, p.opts.price
+	var image *ai.Image
+	if vision {
+		thumb, err := extractors.Thumbnail(info, extractors.ThumbnailMaxSide)
+		if err != nil {
+			log.Printf("[VISION] %s: %v; naming from text evidence\n", info.Path, err)
+			file.info.Warnings = append(append([]string(nil), file.info.Warnings...), "thumbnail failed; named from text evidence")
+		} else {
+			image = &ai.Image{MIMEType: "image/jpeg", Data: thumb}
+			getClient, price = p.opts.vision, p.opts.visionPrice
+		}
+	}
+
+	client, err := getClient()
+	if err != nil {
+		return namedFile{}, err
+	}
+
+	if strategy == "auto" {
+		file.method = "ai-fallback"
+		log.Printf("[AI] %s local confidence %.2f below threshold %.2f; sending %d compact evidence chars\n", info.Path, file.confidence, p.opts.confidenceThreshold, len(content))
+	} else {
+		file.method = "ai-only"
+	}
+	var result ai.Result
+	if image != nil {
+		file.method = "ai-vision"
+		log.Printf("[VISION] %s sending %d byte thumbnail with %d evidence chars\n", info.Path, len(image.Data), len(content))
+		result, err = client.SuggestFilenameFromImage(ctx, *image, content, p.opts.aiCandidates, opts)
+	} else {
+		result, err = client.SuggestFilenameCandidates(ctx, content, p.opts.aiCandidates, opts)
+	}
+	file.usage = result.Usage
+	file.cost = price.Cost(file.usage)
+	// Failed calls can still have used tokens.
+	p.spend(file.cost)
+	if err != nil {
+		return namedFile{}, err
+	}
+	candidates := result.Candidates
+	if len(candidates) == 0 {
+		return namedFile{}, fmt.Errorf("%s: AI returned no filename candidates", info.Path)
+	}
+	file.suggested = candidates[0].Filename
+	file.confidence = candidates[0].Confidence
+	file.promptVersion = p.opts.promptVersion
+	if p.opts.organize != nil && file.topic == "" && p.opts.organize.HasTopic(candidates[0].Topic) {
+		file.topic = candidates[0].Topic
+	}
+	for _, c := range candidates {
+		file.candidates = append(file.candidates, report.Candidate{
+			Name:       p.formatName(c.Filename, info),
+			Confidence: c.Confidence,
+			Rationale:  c.Rationale,
+		})
+	}
+	return file, nil
+}
+
+// localName names info from a matching rule, or else from its local
+// evidence, and reports whether a rule matched.
+func (p *renamePipeline) localName(info extractors.ExtractedFileInfo) (namedFile, bool) {
+	suggestion, ruled := p.opts.rules.Match(info, p.opts.naming)
+	if !ruled {
+		suggestion = analysis.GenerateFilenameWith(info, p.opts.naming)
+	}
+	file := namedFile{
+		info:       info,
+		suggested:  suggestion.Filename,
+		method:     suggestion.Method,
+		confidence: suggestion.Confidence,
+		evidence:   append([]string(nil), suggestion.Evidence...),
+	}
+	if p.opts.organize != nil {
+		file.topic = p.opts.organize.Topic(info)
+	}
+	return file, ruled
+}
+
+// placeUnversioned places every member of a near-duplicate cluster under its
+// own local name after naming the cluster failed with err, so the members
+// still reach the report.
+func (p *renamePipeline) placeUnversioned(members []extractors.ExtractedFileInfo, err error) {
+	for _, member := range members {
+		file, _ := p.localName(member)
+		file.info.Warnings = append(append([]string(nil), member.Warnings...), fmt.Sprintf("naming the version cluster failed (%v); kept metadata name", err))
+		if err := p.place(file); err != nil {
+			p.addError(err)
+		}
+	}
+}
+
+// placeVersions places file, or every member of its near-duplicate cluster
+// under the shared name with -v1, -v2, ... suffixes in version order.
+func (p *renamePipeline) placeVersions(file namedFile) {
+	members := p.versions[file.info.Path]
+	if len(members) == 0 {
+		if err := p.place(file); err != nil {
+			p.addError(err)
+		}
+		return
+	}
+
+	// Each version is dated on its own in place.
+	base := p.opts.naming.Format(file.suggested, time.Time{})
+	p.outputMu.Lock()
+	group := base
+	for i := 2; ; i++ {
+		if _, taken := p.groupNames[group]; !taken {
+			break
+		}
+		group = fmt.Sprintf("%s-%d", base, i)
+	}
+	p.groupNames[group] = struct{}{}
+	p.outputMu.Unlock()
+
+	for i, member := range members {
+		suffix := fmt.Sprintf("-v%d", i+1)
+		name := group
+		if limit := p.opts.naming.Limit(); len(name)+len(suffix) > limit {
+			name = strings.TrimRight(utils.TruncateRunes(name, limit-len(suffix)), "-_ ")
+		}
+		version := file
+		version.info = member
+		version.suggested = name + suffix
+		version.versionGroup = group
+		version.version = i + 1
+		// Alternatives would not carry the shared group name and suffix.
+		version.candidates = nil
+		// The cluster was named with one AI call; charge it to the first version.
+		if i > 0 {
+			version.usage, version.cost = ai.Usage{}, 0
+		}
+		if err := p.place(version); err != nil {
+			p.addError(err)
+		}
+	}
+}
+
+// formatName applies the naming profile to name, dating it from info for
+// the date-prefix style, and then the name template for info's type.
+func (p *renamePipeline) formatName(name string, info extractors.ExtractedFileInfo) string {
+	return formatName(name, info, p.opts.naming, p.opts.nameTemplates)
+}
+
+func formatName(name string, info extractors.ExtractedFileInfo, naming utils.NamingProfile, templates analysis.NameTemplates) string {
+	var date time.Time
+	if naming.Style == utils.StyleDatePrefix {
+		date = analysis.FileDate(info)
+	}
+	name = naming.Format(name, date)
+	if tmpl := templates.For(info); tmpl != nil {
+		return tmpl.Render(info, name, naming)
+	}
+	return name
+}
+
+func (p *renamePipeline) place(file namedFile) error {
+	opts := p.opts
+	path := file.info.Path
+	confidence := file.confidence
+
+	// Format to avoid invalid characters.
+	sanitized := p.formatName(file.suggested, file.info)
+	if strings.HasPrefix(file.method, analysis.RuleMethodPrefix) {
+		sanitized = file.suggested
+	}
+
+	ext := filepath.Ext(path)
+	if file.info.SuggestedExtension != "" {
+		ext = "." + file.info.SuggestedExtension
+	}
+
+	folder := ""
+	if opts.organize != nil {
+		folder = opts.organize.Folder(file.info, file.topic, opts.naming)
+	}
+	destPath, err := p.destination(path, folder, sanitized, ext)
+	if err != nil {
+		p.skipDuplicates(path, err)
+		return err
+	}
+
+	skipped := false
+	skipReason := ""
+	reviewStatus := ""
+	if !opts.renameMode && opts.minConfidenceToCopy > 0 && confidence < opts.minConfidenceToCopy {
+		skipped = true
+		skipReason = fmt.Sprintf("confidence %.2f below copy threshold %.2f", confidence, opts.minConfidenceToCopy)
+		reviewStatus = "pending"
+	}
+
+	entry := report.Entry{
+		SourcePath:       path,
+		DestinationPath:  destPath,
+		SuggestedName:    sanitized + ext,
+		Method:           file.method,
+		Confidence:       confidence,
+		Evidence:         file.evidence,
+		Warnings:         append([]string(nil), file.info.Warnings...),
+		DryRun:           opts.dry,
+		Skipped:          skipped,
+		SkipReason:       skipReason,
+		ReviewStatus:     reviewStatus,
+		VersionGroup:     file.versionGroup,
+		Version:          file.version,
+		Candidates:       file.candidates,
+		PromptTokens:     file.usage.PromptTokens,
+		CompletionTokens: file.usage.CompletionTokens,
+		EstimatedCost:    file.cost,
+		PromptVersion:    file.promptVersion,
+		Topic:            file.topic,
+	}
+	err = p.record(entry, func() error {
+		if opts.renameMode {
+			return utils.RenameFileWithExtension(opts.input, path, sanitized, ext)
+		}
+		return utils.CopyFileToPath(path, destPath)
+	})
+	if err != nil {
+		p.skipDuplicates(path, err)
+		return err
+	}
+	return p.placeDuplicates(entry, folder, sanitized, ext)
+}
+
+// skipDuplicates reports the byte-identical copies of canonical as skipped
+// when canonical itself could not be extracted, named, or placed, so they
+// stay in the report. They are not journaled: a resumed run retries
+// canonical and must find them again.
+func (p *renamePipeline) skipDuplicates(canonical string, err error) {
+	for _, dup := range p.duplicates[canonical] {
+		entry := report.Entry{
+			SourcePath:  dup,
+			Method:      "duplicate",
+			DryRun:      p.opts.dry,
+			DuplicateOf: canonical,
+			Skipped:     true,
+			SkipReason:  fmt.Sprintf("duplicate of %s, which failed: %v", canonical, err),
+		}
+		log.Printf("[SKIP] %s reason=%s\n", dup, entry.SkipReason)
+		p.reportMu.Lock()
+		p.reportEntries = append(p.reportEntries, entry)
+		p.reportMu.Unlock()
+	}
+}
+
+// placeDuplicates handles the byte-identical copies of canonical according to
+// the --dedupe policy. Duplicates reuse the canonical name and folder instead
+// of being extracted and named again.
+func (p *renamePipeline) placeDuplicates(canonical report.Entry, folder, sanitized, ext string) error {
+	for _, dup := range p.duplicates[canonical.SourcePath] {
+		entry := report.Entry{
+			SourcePath:    dup,
+			SuggestedName: canonical.SuggestedName,
+			Method:        "duplicate",
+			Confidence:    canonical.Confidence,
+			Evidence:      canonical.Evidence,
+			DryRun:        p.opts.dry,
+			DuplicateOf:   canonical.SourcePath,
+		}
+		var op func() error
+		if p.opts.dedupe == "skip" || canonical.Skipped {
+			entry.DestinationPath = canonical.DestinationPath
+			entry.Skipped = true
+			entry.SkipReason = "duplicate of " + canonical.SourcePath
+		} else {
+			dest, err := p.destination(dup, folder, sanitized, ext)
+			if err != nil {
+				return err
+			}
+			entry.DestinationPath = dest
+			op = func() error {
+				switch {
+				case p.opts.renameMode:
+					return os.Rename(dup, dest)
+				case p.opts.dedupe == "link":
+					return utils.LinkOrCopy(canonical.DestinationPath, dest)
+				default:
+					return utils.CopyFileToPath(dup, dest)
+				}
+			}
+		}
+		if err := p.record(entry, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// destination reserves a collision-free path for path renamed to
+// sanitized+ext. A folder from --organize replaces the mirrored input tree.
+func (p *renamePipeline) destination(path, folder, sanitized, ext string) (string, error) {
+	opts := p.opts
+	planned := filepath.Join(filepath.Dir(path), sanitized+ext)
+	if !opts.renameMode {
+		var err error
+		if folder != "" {
+			planned, err = utils.DestinationPath(opts.input, path, filepath.Join(opts.output, filepath.FromSlash(folder)), sanitized, ext, true)
+		} else {
+			planned, err = utils.DestinationPath(opts.input, path, opts.output, sanitized, ext, opts.flatten)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	p.outputMu.Lock()
+	defer p.outputMu.Unlock()
+	if opts.dry {
+		return utils.UniquePlannedPath(planned, p.reservedPaths), nil
+	}
+	return utils.UniquePath(planned, p.reservedPaths), nil
+}
+
+// record adds entry to the report, performs op unless the entry is a dry run
+// or skipped, and then journals it.
+func (p *renamePipeline) record(entry report.Entry, op func() error) error {
+	if p.opts.journal != nil {
+		// Fingerprint before the operation; rename mode moves the source.
+		if err := report.Fingerprint(&entry, entry.SourcePath, p.opts.journalHash); err != nil {
+			return err
+		}
+	}
+
+	p.reportMu.Lock()
+	p.reportEntries = append(p.reportEntries, entry)
+	p.reportMu.Unlock()
+
+	// Depending on flags, perform or log the operation.
+	switch {
+	case entry.DryRun:
+		log.Printf("[DRY] %s  →  %s method=%s confidence=%.2f\n", entry.SourcePath, entry.DestinationPath, entry.Method, entry.Confidence)
+	case entry.Skipped:
+		log.Printf("[SKIP] %s  →  %s method=%s confidence=%.2f reason=%s\n", entry.SourcePath, entry.DestinationPath, entry.Method, entry.Confidence, entry.SkipReason)
+	default:
+		if err := op(); err != nil {
+			return err
+		}
+	}
+
+	// Journal only once the operation succeeded so a crash mid-copy redoes the file.
+	if p.opts.journal != nil {
+		return p.opts.journal.Append(entry)
+	}
+	return nil
+}
+
+// withinBudget reports whether another AI call may start. Calls already in
+// flight when the budget runs out still finish, so a run can overshoot by up
+// to one call per worker.
+func (p *renamePipeline) withinBudget() bool {
+	p.budgetMu.Lock()
+	defer p.budgetMu.Unlock()
+	if p.opts.maxAIBudget <= 0 || p.spent < p.opts.maxAIBudget {
+		return true
+	}
+	p.budgetSkipped++
+	return false
+}
+
+func (p *renamePipeline) spend(cost float64) {
+	p.budgetMu.Lock()
+	defer p.budgetMu.Unlock()
+	p.spent += cost
+}
+
+// budgetSkippedCount is how many files kept their metadata name because the
+// budget ran out.
+func (p *renamePipeline) budgetSkippedCount() int {
+	p.budgetMu.Lock()
+	defer p.budgetMu.Unlock()
+	return p.budgetSkipped
+}
+
+func (p *renamePipeline) planBelowThreshold() {
+	p.planMu.Lock()
+	defer p.planMu.Unlock()
+	p.plan.BelowThreshold++
+}
+
+// planCall adds the estimated usage and cost of one candidates call for
+// content, with a thumbnail when vision is set, to the plan.
+func (p *renamePipeline) planCall(content string, vision bool, opts ai.Options) {
+	prompts := cmp.Or(p.opts.prompts, ai.DefaultPrompts())
+	usage, price := prompts.EstimateCandidatesUsage(content, p.opts.aiCandidates, opts), p.opts.price
+	if vision {
+		usage, price = prompts.EstimateImageCandidatesUsage(content, p.opts.aiCandidates, opts), p.opts.visionPrice
+	}
+	p.planMu.Lock()
+	defer p.planMu.Unlock()
+	p.plan.AICalls++
+	if vision {
+		p.plan.VisionCalls++
+	}
+	p.plan.EvidenceChars += len(content)
+	p.plan.EstimatedPromptTokens += usage.PromptTokens
+	p.plan.EstimatedCompletionTokens += usage.CompletionTokens
+	p.plan.EstimatedCost += price.Cost(usage)
+}
+
+// planned returns the totals of a --plan run.
+func (p *renamePipeline) planned() report.Plan {
+	p.planMu.Lock()
+	defer p.planMu.Unlock()
+	return p.plan
+}
+
+func (p *renamePipeline) addError(err error) {
+	p.errMu.Lock()
+	p.errs = append(p.errs, err)
+	p.errMu.Unlock()
+}
@@ -0,0 +1,869 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/report"
+)
+
+type scriptedClient struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+	onAI  func(call int)
+}
+
+func (s *scriptedClient) SuggestFilename(ctx context.Context, content string, opts ai.Options) (ai.Result, error) {
+	return s.SuggestFilenameFromEvidence(ctx, content, opts)
+}
+
+func (s *scriptedClient) SuggestFilenameFromEvidence(ctx context.Context, _ string, _ ai.Options) (ai.Result, error) {
+	s.mu.Lock()
+	s.calls++
+	call := s.calls
+	s.mu.Unlock()
+	if s.onAI != nil {
+		s.onAI(call)
+	}
+	if err := ctx.Err(); err != nil {
+		return ai.Result{}, err
+	}
+	if s.err != nil {
+		return ai.Result{}, s.err
+	}
+	return ai.Result{Filename: fmt.Sprintf("ai-name-%d", call)}, nil
+}
+
+func (s *scriptedClient) SuggestFilenameCandidates(ctx context.Context, content string, _ int, opts ai.Options) (ai.Result, error) {
+	result, err := s.SuggestFilenameFromEvidence(ctx, content, opts)
+	if err != nil {
+		return ai.Result{}, err
+	}
+	result.Candidates = []ai.Candidate{{Filename: result.Filename, Confidence: 1}}
+	return result, nil
+}
+
+func (s *scriptedClient) SuggestFilenameFromImage(ctx context.Context, _ ai.Image, evidence string, k int, opts ai.Options) (ai.Result, error) {
+	return s.SuggestFilenameCandidates(ctx, evidence, k, opts)
+}
+
+func TestRunReportsMoreErrorsThanWorkersWithoutBlocking(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 150; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%03d.txt", i)), "quarterly budget review for the north region")
+	}
+	fake := &scriptedClient{err: errors.New("backend unavailable")}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--debug",
+		"--workers", "3",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "txt",
+	})
+	if err == nil {
+		t.Fatal("expected AI errors")
+	}
+	if got := strings.Count(err.Error(), "backend unavailable"); got != 150 {
+		t.Fatalf("joined errors = %d, want 150", got)
+	}
+}
+
+func TestPipelineStopsOnCancelAndKeepsFinishedEntries(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%02d.txt", i)), "quarterly budget review for the north region")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake := &scriptedClient{onAI: func(call int) {
+		if call == 3 {
+			cancel()
+		}
+	}}
+
+	pipeline := newRenamePipeline(runOptions{
+		input:    inputDir,
+		output:   t.TempDir(),
+		types:    map[string]struct{}{"txt": {}},
+		strategy: "ai-only",
+		dry:      true,
+		workers:  1,
+	}, func() (ai.Client, error) { return fake, nil })
+
+	entries, errs := pipeline.run(ctx)
+
+	if len(errs) != 0 {
+		t.Fatalf("cancellation should not be reported as file errors: %v", errs)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want the 2 files named before cancel", len(entries))
+	}
+	if fake.calls != 3 {
+		t.Fatalf("AI calls = %d, want no calls after cancel", fake.calls)
+	}
+}
+
+func TestRunRejectsInvalidWorkers(t *testing.T) {
+	err := runApp([]string{"ai-file-renamer", "--strategy", "metadata-only", "--workers", "0", "--input", t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "--workers") {
+		t.Fatalf("err = %v, want workers validation error", err)
+	}
+}
+
+func TestResumeSkipsJournaledFilesAndRebuildsReport(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 4; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%d.txt", i)), "quarterly budget review for the north region")
+	}
+	journalPath := filepath.Join(t.TempDir(), "run.jsonl")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	args := []string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "txt",
+		"--report", reportPath,
+	}
+	if err := runApp(append(args, "--journal", journalPath)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 4 {
+		t.Fatalf("first run AI calls = %d, want 4", fake.calls)
+	}
+
+	writeTextFile(t, filepath.Join(inputDir, "note-4.txt"), "new file added after the first run")
+	if err := runApp(append(args, "--resume", journalPath)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 5 {
+		t.Fatalf("AI calls after resume = %d, want only the new file", fake.calls)
+	}
+
+	got := readReport(t, reportPath)
+	if len(got.Entries) != 5 || got.Summary.TotalFiles != 5 {
+		t.Fatalf("rebuilt report has %d entries, want 5: %+v", len(got.Entries), got.Summary)
+	}
+	destinations := map[string]bool{}
+	for _, entry := range got.Entries {
+		if entry.SourceSize == 0 || entry.SourceModTime == "" {
+			t.Fatalf("entry missing fingerprint: %+v", entry)
+		}
+		if destinations[entry.DestinationPath] {
+			t.Fatalf("resumed run reused destination %s", entry.DestinationPath)
+		}
+		destinations[entry.DestinationPath] = true
+	}
+}
+
+func TestResumeInRenameModeSkipsJournaledOutputs(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%d.txt", i)), "quarterly budget review for the north region")
+	}
+	journalPath := filepath.Join(t.TempDir(), "run.jsonl")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	args := []string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--rename",
+		"--quiet",
+		"--input", inputDir,
+		"--types", "txt",
+		"--report", reportPath,
+	}
+	if err := runApp(append(args, "--journal", journalPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTextFile(t, filepath.Join(inputDir, "note-3.txt"), "new file added after the first run")
+	if err := runApp(append(args, "--resume", journalPath)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 4 {
+		t.Fatalf("AI calls = %d, want only the new file named on resume", fake.calls)
+	}
+	if got := readReport(t, reportPath); len(got.Entries) != 4 {
+		t.Fatalf("rebuilt report has %d entries, want 4", len(got.Entries))
+	}
+}
+
+func TestJournalRefusesExistingFileWithoutResume(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTextFile(t, filepath.Join(inputDir, "note.txt"), "quarterly budget review for the north region")
+	journalPath := filepath.Join(t.TempDir(), "run.jsonl")
+	writeTextFile(t, journalPath, "")
+
+	err := runApp([]string{"ai-file-renamer", "--strategy", "metadata-only", "--dry-run", "--quiet", "--input", inputDir, "--types", "txt", "--journal", journalPath})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("err = %v, want a refusal to reuse the journal", err)
+	}
+}
+
+func TestResumeAfterDryRunCopiesEveryFile(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%d.txt", i)), "quarterly budget review for the north region")
+	}
+	journalPath := filepath.Join(t.TempDir(), "run.jsonl")
+	outputDir := t.TempDir()
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	args := []string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--quiet",
+		"--input", inputDir,
+		"--output", outputDir,
+		"--types", "txt",
+		"--report", reportPath,
+	}
+	if err := runApp(append(args, "--dry-run", "--journal", journalPath)); err != nil {
+		t.Fatal(err)
+	}
+	if err := runApp(append(args, "--resume", journalPath)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 6 {
+		t.Fatalf("AI calls = %d, want the real run to redo all 3 files", fake.calls)
+	}
+
+	got := readReport(t, reportPath)
+	if len(got.Entries) != 3 {
+		t.Fatalf("rebuilt report has %d entries, want 3", len(got.Entries))
+	}
+	for _, entry := range got.Entries {
+		if entry.DryRun {
+			t.Fatalf("entry still a dry run: %+v", entry)
+		}
+		if _, err := os.Stat(entry.DestinationPath); err != nil {
+			t.Fatalf("resumed run did not copy %s: %v", entry.SourcePath, err)
+		}
+	}
+}
+
+func TestDedupeNamesOneCopyPerContentGroup(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTextFile(t, filepath.Join(inputDir, "a.txt"), "quarterly budget review for the north region")
+	writeTextFile(t, filepath.Join(inputDir, "b.txt"), "quarterly budget review for the north region")
+	writeTextFile(t, filepath.Join(inputDir, "c.txt"), "minutes from the south region planning meeting")
+	outputDir := t.TempDir()
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--quiet",
+		"--dedupe", "link",
+		"--input", inputDir,
+		"--output", outputDir,
+		"--types", "txt",
+		"--report", reportPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 2 {
+		t.Fatalf("AI calls = %d, want one per unique file", fake.calls)
+	}
+
+	got := readReport(t, reportPath)
+	if got.Summary.DuplicateCount != 1 {
+		t.Fatalf("duplicate count = %d, want 1", got.Summary.DuplicateCount)
+	}
+	var canonical, duplicate report.Entry
+	for _, entry := range got.Entries {
+		switch filepath.Base(entry.SourcePath) {
+		case "a.txt":
+			canonical = entry
+		case "b.txt":
+			duplicate = entry
+		}
+	}
+	if duplicate.DuplicateOf != canonical.SourcePath || duplicate.Method != "duplicate" {
+		t.Fatalf("duplicate entry = %+v", duplicate)
+	}
+	if duplicate.DestinationPath == canonical.DestinationPath {
+		t.Fatal("linked duplicate should get its own destination")
+	}
+	if _, err := os.Stat(duplicate.DestinationPath); err != nil {
+		t.Fatalf("duplicate was not linked: %v", err)
+	}
+}
+
+func TestDedupeReportsDuplicatesOfAFailedFile(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTextFile(t, filepath.Join(inputDir, "a.txt"), "quarterly budget review for the north region")
+	writeTextFile(t, filepath.Join(inputDir, "b.txt"), "quarterly budget review for the north region")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{err: errors.New("backend unavailable")}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--quiet",
+		"--dedupe", "copy",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "txt",
+		"--report", reportPath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "backend unavailable") {
+		t.Fatalf("err = %v, want the AI error", err)
+	}
+
+	got := readReport(t, reportPath)
+	if len(got.Entries) != 1 {
+		t.Fatalf("report has %d entries, want the duplicate", len(got.Entries))
+	}
+	entry := got.Entries[0]
+	if filepath.Base(entry.SourcePath) != "b.txt" || filepath.Base(entry.DuplicateOf) != "a.txt" || !entry.Skipped || !strings.Contains(entry.SkipReason, "backend unavailable") {
+		t.Fatalf("entry = %+v, want b.txt skipped as a duplicate of the failed a.txt", entry)
+	}
+}
+
+func TestDedupeRejectsLinkWithRename(t *testing.T) {
+	err := runApp([]string{"ai-file-renamer", "--strategy", "metadata-only", "--rename", "--dedupe", "link", "--input", t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "--dedupe") {
+		t.Fatalf("err = %v, want dedupe validation error", err)
+	}
+}
+
+func TestNearDuplicatesShareOneVersionedName(t *testing.T) {
+	inputDir := t.TempDir()
+	body := "The quarterly budget plan covers staffing and travel and equipment for the north region. " +
+		"Staffing grows by two engineers in the second quarter and travel is limited to customer visits."
+	writeTextFile(t, filepath.Join(inputDir, "draft.txt"), body)
+	writeTextFile(t, filepath.Join(inputDir, "final.txt"), body+" Approved.")
+	writeTextFile(t, filepath.Join(inputDir, "other.txt"), "minutes from the south region planning meeting about the warehouse lease and hiring")
+	older := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(inputDir, "draft.txt"), older, older); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--near-duplicates", "0.7",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "txt",
+		"--report", reportPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 2 {
+		t.Fatalf("AI calls = %d, want one per cluster or standalone file", fake.calls)
+	}
+
+	got := readReport(t, reportPath)
+	if len(got.Clusters) != 1 || len(got.Clusters[0].Members) != 2 {
+		t.Fatalf("clusters = %+v, want one cluster of two", got.Clusters)
+	}
+	if filepath.Base(got.Clusters[0].Members[0]) != "draft.txt" {
+		t.Fatalf("cluster members = %v, want older draft first", got.Clusters[0].Members)
+	}
+	names := map[string]string{}
+	for _, entry := range got.Entries {
+		names[filepath.Base(entry.SourcePath)] = entry.SuggestedName
+	}
+	group := got.Clusters[0].Name
+	if names["draft.txt"] != group+"-v1.txt" || names["final.txt"] != group+"-v2.txt" {
+		t.Fatalf("versioned names = %v, want %s-v1/-v2", names, group)
+	}
+}
+
+func TestFailedClusterNamingKeepsEveryMember(t *testing.T) {
+	inputDir := t.TempDir()
+	body := "The quarterly budget plan covers staffing and travel and equipment for the north region. " +
+		"Staffing grows by two engineers in the second quarter and travel is limited to customer visits."
+	writeTextFile(t, filepath.Join(inputDir, "draft.txt"), body)
+	writeTextFile(t, filepath.Join(inputDir, "final.txt"), body+" Approved.")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	fake := &scriptedClient{err: errors.New("backend unavailable")}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) { return fake, nil }
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--near-duplicates", "0.7",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "txt",
+		"--report", reportPath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "backend unavailable") {
+		t.Fatalf("err = %v, want the AI error", err)
+	}
+
+	got := readReport(t, reportPath)
+	if len(got.Entries) != 2 {
+		t.Fatalf("report has %d entries, want both cluster members", len(got.Entries))
+	}
+	for _, entry := range got.Entries {
+		if entry.Method != "metadata" || entry.VersionGroup != "" || len(entry.Warnings) == 0 {
+			t.Fatalf("entry = %+v, want an unversioned metadata name with a warning", entry)
+		}
+	}
+}
+
+func TestDirectoryContextNamesSiblingsAlike(t *testing.T) {
+	inputDir := t.TempDir()
+	bookDir := filepath.Join(inputDir, "recup_dir.7")
+	if err := os.Mkdir(bookDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTextFile(t, filepath.Join(bookDir, "f1.md"), "# Chapter 1: Harbor Lights Arrival\n")
+	writeTextFile(t, filepath.Join(bookDir, "f2.md"), "# Chapter 2: Harbor Lights Storm\n")
+	writeTextFile(t, filepath.Join(inputDir, "runbook.md"), "# Incident Response Runbook\n")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "metadata-only",
+		"--dry-run",
+		"--quiet",
+		"--directory-context",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--report", reportPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := entriesByBase(readReport(t, reportPath))
+	assertSuggestedName(t, entries, "f1.md", "chapter-harbor-lights-01.md")
+	assertSuggestedName(t, entries, "f2.md", "chapter-harbor-lights-02.md")
+	assertSuggestedName(t, entries, "runbook.md", "incident-response-runbook.md")
+	if evidence := entries["f1.md"].Evidence; len(evidence) != 1 || evidence[0] != "directory-context" {
+		t.Fatalf("evidence = %v, want directory-context", evidence)
+	}
+}
+
+func TestKeyphrasesNameReportsBehindACommonHeader(t *testing.T) {
+	inputDir := t.TempDir()
+	header := "Northwind Holdings staff bulletin prepared by the communications office for all employees\n\n"
+	writeTextFile(t, filepath.Join(inputDir, "f0001.txt"), header+strings.Repeat("The solar panel installation on the warehouse roof is finished, and the facilities team will report savings. ", 8))
+	writeTextFile(t, filepath.Join(inputDir, "f0002.txt"), header+strings.Repeat("The payroll migration to the new accounting platform is complete, so check your next payslip. ", 8))
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "metadata-only",
+		"--dry-run",
+		"--quiet",
+		"--keyphrases",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--report", reportPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := entriesByBase(readReport(t, reportPath))
+	assertSuggestedName(t, entries, "f0001.txt", "solar-panel-installation-warehouse-roof.txt")
+	assertSuggestedName(t, entries, "f0002.txt", "payroll-migration-accounting-platform.txt")
+	if evidence := entries["f0001.txt"].Evidence; len(evidence) != 1 || evidence[0] != "keyphrases" {
+		t.Fatalf("evidence = %v, want keyphrases", evidence)
+	}
+}
+
+func TestOrganizeFilesIntoTopicFolders(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTextFile(t, filepath.Join(inputDir, "a.txt"), "Invoice 1042: payment due for the March budget")
+	writeTextFile(t, filepath.Join(inputDir, "b.txt"), "Notes from the Lisbon weekend")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Content string   `json:"content"`
+			Topics  []string `json:"topics"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		candidate := ai.Candidate{Filename: "march-invoice", Confidence: 0.9}
+		if strings.Contains(req.Content, "Lisbon") {
+			candidate = ai.Candidate{Filename: "lisbon-weekend", Confidence: 0.9}
+		}
+		// Only the file the local rules could not place gets the topics.
+		if len(req.Topics) > 0 {
+			candidate.Topic = "travel"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"filename": candidate.Filename, "candidates": []ai.Candidate{candidate}})
+	}))
+	defer server.Close()
+	newAIClient = func(_ config.Config, _ bool, model string) (ai.Client, error) {
+		return ai.NewHTTPClient(server.URL, model), nil
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	outputDir := t.TempDir()
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--organize",
+		"--input", inputDir,
+		"--output", outputDir,
+		"--types", "txt",
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := entriesByBase(readReport(t, reportPath))
+	for base, want := range map[string]string{
+		"a.txt": filepath.Join(outputDir, "documents", "finance", "march-invoice.txt"),
+		"b.txt": filepath.Join(outputDir, "documents", "travel", "lisbon-weekend.txt"),
+	} {
+		if got := entries[base].DestinationPath; got != want {
+			t.Errorf("%s destination = %s, want %s", base, got, want)
+		}
+	}
+	if entries["b.txt"].Topic != "travel" {
+		t.Fatalf("topic = %q, want the AI's travel", entries["b.txt"].Topic)
+	}
+
+	err := runApp([]string{"ai-file-renamer", "--organize", "--flatten", "--input", inputDir, "--output", outputDir})
+	if err == nil || !strings.Contains(err.Error(), "--organize cannot be combined") {
+		t.Fatalf("--organize --flatten err = %v", err)
+	}
+}
+
+func TestRulesOverrideTheAI(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTextFile(t, filepath.Join(inputDir, "export.csv"), "invoice_id,customer,total\nINV-1042,Acme Corp,99.50\n")
+	writeTextFile(t, filepath.Join(inputDir, "other.csv"), "city,population\nLisbon,545000\n")
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{"filename": "city-populations", "candidates": []ai.Candidate{{Filename: "city-populations", Confidence: 0.9}}})
+	}))
+	defer server.Close()
+	newAIClient = func(_ config.Config, _ bool, model string) (ai.Client, error) {
+		return ai.NewHTTPClient(server.URL, model), nil
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	writeTextFile(t, rulesPath, `rules:
+  - name: invoice-export
+    metadata:
+      headers: '\binvoice_id\b'
+      first_row: '^(?P<invoice>[^,]+)'
+    template: "invoice-{invoice}"
+`)
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--naming", "snake",
+		"--name-template", "{slug}_{type}",
+		"--rules", rulesPath,
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "csv",
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("AI calls = %d, want 1 for the file no rule matched", calls)
+	}
+
+	entries := entriesByBase(readReport(t, reportPath))
+	// The rule's own text is kept as written.
+	assertSuggestedName(t, entries, "export.csv", "invoice-inv_1042.csv")
+	assertSuggestedName(t, entries, "other.csv", "city_populations_csv.csv")
+	if got := entries["export.csv"]; got.Method != "rule:invoice-export" || got.Confidence != 1 {
+		t.Fatalf("rule entry = %+v", got)
+	}
+}
+
+func TestNameLanguageKeepsTheContentLanguage(t *testing.T) {
+	inputDir := t.TempDir()
+	writeTextFile(t, filepath.Join(inputDir, "scan.txt"), "Die Rechnung für den Monat März ist im Anhang und der Betrag wird mit der nächsten Zahlung fällig.\n")
+	var language string
+	var keep bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Language     string `json:"language"`
+			KeepLanguage bool   `json:"keep_language"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		language, keep = req.Language, req.KeepLanguage
+		json.NewEncoder(w).Encode(map[string]any{"filename": "Rechnung März Zahlung", "candidates": []ai.Candidate{{Filename: "Rechnung März Zahlung", Confidence: 0.9}}})
+	}))
+	defer server.Close()
+	newAIClient = func(_ config.Config, _ bool, model string) (ai.Client, error) {
+		return ai.NewHTTPClient(server.URL, model), nil
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--name-language", "source",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if language != "de" || !keep {
+		t.Fatalf("request language = %q keep = %v, want de kept", language, keep)
+	}
+	// ASCII names spell the umlaut out rather than dropping it.
+	assertSuggestedName(t, entriesByBase(readReport(t, reportPath)), "scan.txt", "rechnung-maerz-zahlung.txt")
+}
+
+func TestAIBudgetRecordsCostAndFallsBackToMetadata(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 4; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%d.txt", i)), "quarterly budget review for the north region")
+	}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"filename":   fmt.Sprintf("ai-name-%d", calls),
+			"candidates": []ai.Candidate{{Filename: fmt.Sprintf("ai-name-%d", calls), Confidence: 0.9}},
+			"usage":      ai.Usage{PromptTokens: 1000, CompletionTokens: 100},
+		})
+	}))
+	defer server.Close()
+	newAIClient = func(_ config.Config, _ bool, model string) (ai.Client, error) {
+		return ai.NewHTTPClient(server.URL, model), nil
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	// $5 per million prompt tokens and $10 per million completion tokens make
+	// each call cost $0.006.
+	pricePath := filepath.Join(t.TempDir(), "prices.yaml")
+	writeTextFile(t, pricePath, "models:\n  relay-model: {input: 5, output: 10}\n")
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "ai-only",
+		"--dry-run",
+		"--quiet",
+		"--workers", "1",
+		"--model", "relay-model",
+		"--price-table", pricePath,
+		"--max-ai-budget", "0.01",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--types", "txt",
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("AI calls = %d, want 2 before the budget ran out", calls)
+	}
+
+	got := readReport(t, reportPath)
+	if got.Summary.PromptTokens != 2000 || got.Summary.CompletionTokens != 200 || got.Summary.AIBudgetSkippedCount != 2 {
+		t.Fatalf("summary = %+v", got.Summary)
+	}
+	if cost := got.Summary.EstimatedCost; cost < 0.0119 || cost > 0.0121 {
+		t.Fatalf("estimated cost = %v, want 0.012", cost)
+	}
+	var metadata int
+	for _, entry := range got.Entries {
+		if entry.Method == "ai-only" {
+			if entry.PromptTokens != 1000 || entry.EstimatedCost == 0 {
+				t.Fatalf("AI entry missing usage: %+v", entry)
+			}
+			continue
+		}
+		metadata++
+		if entry.PromptTokens != 0 || !slices.Contains(entry.Warnings, "AI budget exhausted; kept metadata name") {
+			t.Fatalf("metadata entry = %+v", entry)
+		}
+	}
+	if metadata != 2 {
+		t.Fatalf("metadata entries = %d, want 2", metadata)
+	}
+}
+
+func TestPlanEstimatesWithoutCallingAI(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		writeTextFile(t, filepath.Join(inputDir, fmt.Sprintf("note-%d.txt", i)), "quarterly budget review for the north region")
+	}
+	newAIClient = func(config.Config, bool, string) (ai.Client, error) {
+		t.Error("--plan created an AI client")
+		return nil, errors.New("unexpected AI client")
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	outputDir := t.TempDir()
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--plan",
+		"--quiet",
+		"--strategy", "auto",
+		"--confidence-threshold", "1",
+		"--model", "gpt-4o",
+		"--input", inputDir,
+		"--output", outputDir,
+		"--types", "txt",
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readReport(t, reportPath)
+	plan := got.Plan
+	if plan == nil {
+		t.Fatal("report has no plan")
+	}
+	if plan.Model != "gpt-4o" || plan.BelowThreshold != 3 || plan.AICalls != 3 || plan.EvidenceChars == 0 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	want := ai.DefaultPrices["gpt-4o"].Cost(ai.Usage{PromptTokens: plan.EstimatedPromptTokens, CompletionTokens: plan.EstimatedCompletionTokens})
+	if plan.EstimatedCost <= 0 || plan.EstimatedCost-want > 1e-12 || want-plan.EstimatedCost > 1e-12 {
+		t.Fatalf("estimated cost = %v, want %v", plan.EstimatedCost, want)
+	}
+	for _, entry := range got.Entries {
+		if !entry.DryRun || entry.Method == "ai-fallback" {
+			t.Fatalf("plan entry = %+v, want a dry-run metadata name", entry)
+		}
+	}
+	if files, _ := os.ReadDir(outputDir); len(files) != 0 {
+		t.Fatalf("--plan wrote %d output files", len(files))
+	}
+
+	err := runApp([]string{"ai-file-renamer", "--plan", "--journal", filepath.Join(t.TempDir(), "run.jsonl"), "--input", inputDir})
+	if err == nil || !strings.Contains(err.Error(), "--plan") {
+		t.Fatalf("err = %v, want --plan/--journal conflict", err)
+	}
+}
+
+func TestVisionModelNamesImagesFromThumbnail(t *testing.T) {
+	t.Setenv("PATH", "")
+	inputDir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1600, 900))); err != nil {
+		t.Fatal(err)
+	}
+	writeTextFile(t, filepath.Join(inputDir, "IMG_1234.png"), buf.String())
+	writeTextFile(t, filepath.Join(inputDir, "note.txt"), "quarterly budget review for the north region")
+
+	text, vision := &fakeClient{filename: "text-name"}, &fakeClient{filename: "screenshot"}
+	newAIClient = func(_ config.Config, _ bool, model string) (ai.Client, error) {
+		if model == "llava" {
+			return vision, nil
+		}
+		return text, nil
+	}
+	t.Cleanup(func() { newAIClient = ai.NewClient })
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runApp([]string{
+		"ai-file-renamer",
+		"--strategy", "auto",
+		"--confidence-threshold", "1",
+		"--vision-model", "llava",
+		"--dry-run",
+		"--quiet",
+		"--input", inputDir,
+		"--output", t.TempDir(),
+		"--report", reportPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if vision.imageCalls != 1 || vision.evidenceCalls != 0 || text.imageCalls != 0 {
+		t.Fatalf("vision image calls = %d, text image calls = %d", vision.imageCalls, text.imageCalls)
+	}
+	if vision.lastImage.MIMEType != "image/jpeg" || len(vision.lastImage.Data) == 0 || !strings.Contains(vision.lastEvidence, "1600x900") {
+		t.Fatalf("image = %s %d bytes, evidence = %q", vision.lastImage.MIMEType, len(vision.lastImage.Data), vision.lastEvidence)
+	}
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(vision.lastImage.Data))
+	if err != nil || max(thumb.Width, thumb.Height) > extractors.ThumbnailMaxSide {
+		t.Fatalf("thumbnail = %+v, %v", thumb, err)
+	}
+
+	for _, entry := range readReport(t, reportPath).Entries {
+		switch filepath.Base(entry.SourcePath) {
+		case "IMG_1234.png":
+			if entry.Method != "ai-vision" || entry.SuggestedName != "pictured-screenshot.png" {
+				t.Fatalf("image entry = %+v", entry)
+			}
+		case "note.txt":
+			if entry.Method != "ai-fallback" {
+				t.Fatalf("text entry = %+v", entry)
+			}
+		}
+	}
+}
+
+func writeTextFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
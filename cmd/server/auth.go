@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyHeader  = "X-API-Key"
+	maxBuckets    = 10000
+	bucketIdleTTL = 10 * time.Minute
+)
+
+// authConfig controls who may spend the relay's OpenAI key. With no keys the
+// server stays open, which is only meant for local development.
+type authConfig struct {
+	keys []string
+
+	// Rates are requests per minute; 0 disables the limit.
+	keyRatePerMinute float64
+	ipRatePerMinute  float64
+
+	// dailyChars caps the Content characters each key (or IP, without keys)
+	// may submit per UTC day; 0 disables the quota.
+	dailyChars int
+
+	// trustProxy uses Fly-Client-IP instead of the socket address. Only
+	// enable it behind the Fly.io proxy, which sets the header.
+	trustProxy bool
+}
+
+func authConfigFromEnv() (authConfig, error) {
+	cfg := authConfig{
+		keyRatePerMinute: 60,
+		ipRatePerMinute:  120,
+		dailyChars:       2000000,
+		trustProxy:       os.Getenv("FLY_APP_NAME") != "",
+	}
+	for _, key := range strings.Split(os.Getenv("RELAY_API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.keys = append(cfg.keys, key)
+		}
+	}
+	if path := os.Getenv("RELAY_API_KEYS_FILE"); path != "" {
+		keys, err := readKeysFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.keys = append(cfg.keys, keys...)
+	}
+
+	var err error
+	if cfg.keyRatePerMinute, err = envFloat("RELAY_KEY_RATE_PER_MINUTE", cfg.keyRatePerMinute); err != nil {
+		return cfg, err
+	}
+	if cfg.ipRatePerMinute, err = envFloat("RELAY_IP_RATE_PER_MINUTE", cfg.ipRatePerMinute); err != nil {
+		return cfg, err
+	}
+	quota, err := envFloat("RELAY_DAILY_CHAR_QUOTA", float64(cfg.dailyChars))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.dailyChars = int(quota)
+	return cfg, nil
+}
+
+// readKeysFile reads one key per line; blank lines and # comments are skipped.
+func readKeysFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+func envFloat(name string, fallback float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number, got %q", name, value)
+	}
+	return parsed, nil
+}
+
+type callerContextKey struct{}
+
+// authenticate rejects requests without a valid key and applies the per-IP
+// and per-key rate limits. The caller identity used for quotas is stored on
+// the request context.
+func (s *server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := s.clientIP(r)
+		if ok, wait := s.limits.allow("ip:"+ip, s.auth.ipRatePerMinute); !ok {
+			tooManyRequests(w, wait, "rate limit exceeded")
+			return
+		}
+
+		caller := "ip:" + ip
+		if len(s.auth.keys) > 0 {
+			key, ok := s.validKey(requestKey(r))
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+				return
+			}
+			if ok, wait := s.limits.allow("key:"+key, s.auth.keyRatePerMinute); !ok {
+				tooManyRequests(w, wait, "rate limit exceeded")
+				return
+			}
+			caller = "key:" + key
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), callerContextKey{}, caller)))
+	}
+}
+
+// callerKey returns the API key authenticate accepted for the request in
+// ctx, or "" when the server requires no keys.
+func callerKey(ctx context.Context) string {
+	caller, _ := ctx.Value(callerContextKey{}).(string)
+	if key, ok := strings.CutPrefix(caller, "key:"); ok {
+		return key
+	}
+	return ""
+}
+
+// chargeQuota counts chars against the caller's daily quota and writes a 429
+// when it would be exceeded.
+func (s *server) chargeQuota(w http.ResponseWriter, r *http.Request, chars int) bool {
+	caller, _ := r.Context().Value(callerContextKey{}).(string)
+	ok, wait := s.limits.charge(caller, chars, s.auth.dailyChars)
+	if !ok {
+		tooManyRequests(w, wait, fmt.Sprintf("daily quota of %d characters exceeded", s.auth.dailyChars))
+	}
+	return ok
+}
+
+func (s *server) validKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	for _, valid := range s.auth.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+			return valid, true
+		}
+	}
+	return "", false
+}
+
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func (s *server) clientIP(r *http.Request) string {
+	if s.auth.trustProxy {
+		if ip := r.Header.Get("Fly-Client-IP"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, message)
+}
+
+// limiter holds token buckets for rate limits and per-day character usage.
+type limiter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	usage   map[string]*dailyUsage
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type dailyUsage struct {
+	day   string
+	chars int
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+		usage:   map[string]*dailyUsage{},
+	}
+}
+
+// allow takes one token from the named bucket, which refills at perMinute
+// and holds up to ten seconds' worth of burst. It returns how long to wait
+// when the bucket is empty.
+func (l *limiter) allow(name string, perMinute float64) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+	rate := perMinute / 60
+	burst := math.Max(1, perMinute/6)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buckets) > maxBuckets {
+		l.pruneLocked(now)
+	}
+	bucket, ok := l.buckets[name]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[name] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// charge adds chars to caller's usage for the current UTC day unless that
+// would pass limit. It returns the time until the quota resets on refusal.
+func (l *limiter) charge(caller string, chars, limit int) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+	now := l.now().UTC()
+	day := now.Format(time.DateOnly)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	usage, ok := l.usage[caller]
+	if !ok || usage.day != day {
+		usage = &dailyUsage{day: day}
+		l.usage[caller] = usage
+	}
+	if usage.chars+chars > limit {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return false, midnight.Sub(now)
+	}
+	usage.chars += chars
+	return true, 0
+}
+
+func (l *limiter) pruneLocked(now time.Time) {
+	for name, bucket := range l.buckets {
+		if now.Sub(bucket.last) > bucketIdleTTL {
+			delete(l.buckets, name)
+		}
+	}
+	today := now.UTC().Format(time.DateOnly)
+	for caller, usage := range l.usage {
+		if usage.day != today {
+			delete(l.usage, caller)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuthServer(t *testing.T, auth authConfig) *server {
+	t.Helper()
+	return newFakeServer(t, defaultModelRoutes(), auth, echoClient{})
+}
+
+func postSuggest(t *testing.T, handler http.Handler, key, content string) (*httptest.ResponseRecorder, FilenameResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/suggest-filename", strings.NewReader(`{"model":"gpt-4o","content":"`+content+`"}`))
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var resp FilenameResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return rec, resp
+}
+
+func TestAuthRejectsMissingAndUnknownKeys(t *testing.T) {
+	handler := newAuthServer(t, authConfig{keys: []string{"team-a"}}).routes()
+
+	for _, key := range []string{"", "team-b"} {
+		rec, resp := postSuggest(t, handler, key, "notes")
+		if rec.Code != http.StatusUnauthorized || resp.Error == "" {
+			t.Fatalf("key %q: status = %d error = %q, want 401 JSON error", key, rec.Code, resp.Error)
+		}
+	}
+
+	rec, resp := postSuggest(t, handler, "team-a", "notes")
+	if rec.Code != http.StatusOK || resp.Filename != "content-notes" {
+		t.Fatalf("valid key: status = %d resp = %+v", rec.Code, resp)
+	}
+}
+
+func TestAuthAcceptsBearerToken(t *testing.T) {
+	handler := newAuthServer(t, authConfig{keys: []string{"team-a"}}).routes()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/batch", strings.NewReader(`{"model":"gpt-4o","items":[{"content":"a"}]}`))
+	req.Header.Set("Authorization", "Bearer team-a")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitPerKeyReturns429WithRetryAfter(t *testing.T) {
+	s := newAuthServer(t, authConfig{keys: []string{"team-a"}, keyRatePerMinute: 6})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.limits.now = func() time.Time { return now }
+	handler := s.routes()
+
+	if rec, _ := postSuggest(t, handler, "team-a", "one"); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d", rec.Code)
+	}
+	rec, resp := postSuggest(t, handler, "team-a", "two")
+	if rec.Code != http.StatusTooManyRequests || resp.Error == "" {
+		t.Fatalf("second request status = %d error = %q, want 429", rec.Code, resp.Error)
+	}
+	if rec.Header().Get("Retry-After") != "10" {
+		t.Fatalf("Retry-After = %q, want 10", rec.Header().Get("Retry-After"))
+	}
+
+	now = now.Add(10 * time.Second)
+	if rec, _ := postSuggest(t, handler, "team-a", "three"); rec.Code != http.StatusOK {
+		t.Fatalf("request after refill status = %d", rec.Code)
+	}
+}
+
+func TestDailyQuotaCountsContentCharacters(t *testing.T) {
+	s := newAuthServer(t, authConfig{keys: []string{"team-a", "team-b"}, dailyChars: 10})
+	now := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+	s.limits.now = func() time.Time { return now }
+	handler := s.routes()
+
+	if rec, _ := postSuggest(t, handler, "team-a", "12345678"); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	rec, resp := postSuggest(t, handler, "team-a", "123")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(resp.Error, "quota") {
+		t.Fatalf("status = %d error = %q, want quota 429", rec.Code, resp.Error)
+	}
+	if rec.Header().Get("Retry-After") != "3600" {
+		t.Fatalf("Retry-After = %q, want seconds until UTC midnight", rec.Header().Get("Retry-After"))
+	}
+	if rec, _ := postSuggest(t, handler, "team-b", "123"); rec.Code != http.StatusOK {
+		t.Fatalf("other key status = %d, quotas must be per key", rec.Code)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if rec, _ := postSuggest(t, handler, "team-a", "123"); rec.Code != http.StatusOK {
+		t.Fatalf("next day status = %d, want quota reset", rec.Code)
+	}
+}
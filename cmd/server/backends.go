@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+const (
+	backendOpenAI           = "openai"
+	backendOllama           = "ollama"
+	backendOpenAICompatible = "openai-compatible"
+)
+
+// modelRoute maps a model alias that clients request to the backend that
+// serves it. Model is the upstream model name and defaults to the alias.
+type modelRoute struct {
+	Backend   string `yaml:"backend"`
+	Model     string `yaml:"model,omitempty"`
+	URL       string `yaml:"url,omitempty"`
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+}
+
+type modelsFile struct {
+	Models map[string]modelRoute `yaml:"models"`
+}
+
+// ModelInfo is one entry advertised by GET /v1/models.
+type ModelInfo struct {
+	ID      string `json:"id"`
+	Backend string `json:"backend"`
+}
+
+type ModelsResponse struct {
+	Models []ModelInfo `json:"models"`
+}
+
+// newBackendClient builds the AI client for a route. It is the default for
+// server.newClient.
+func newBackendClient(cfg config.Config, route modelRoute, prompts *ai.Prompts) (ai.Client, error) {
+	switch route.Backend {
+	case backendOpenAI:
+		if cfg.OpenAIKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY is not set")
+		}
+		return ai.NewOpenAIClient(cfg.OpenAIKey, route.Model).WithPrompts(prompts), nil
+	case backendOllama:
+		url := route.URL
+		if url == "" {
+			url = cfg.OllaHost
+		}
+		return ai.NewOllamaClientWithURL(url, route.Model).WithPrompts(prompts), nil
+	case backendOpenAICompatible:
+		return ai.NewOpenAICompatibleClient(route.URL, apiKeyFromEnv(route), route.Model).WithPrompts(prompts), nil
+	}
+	return nil, fmt.Errorf("unknown backend %q", route.Backend)
+}
+
+func apiKeyFromEnv(route modelRoute) string {
+	if route.APIKeyEnv == "" {
+		return ""
+	}
+	return os.Getenv(route.APIKeyEnv)
+}
+
+// loadModelRoutes reads model aliases from RELAY_MODELS_FILE (YAML) and then
+// RELAY_MODELS, which overrides aliases from the file. Without either, the
+// built-in OpenAI models are served.
+func loadModelRoutes() (map[string]modelRoute, error) {
+	routes := map[string]modelRoute{}
+	if path := os.Getenv("RELAY_MODELS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fileRoutes, err := parseModelsYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for alias, route := range fileRoutes {
+			routes[alias] = route
+		}
+	}
+	if value := os.Getenv("RELAY_MODELS"); value != "" {
+		envRoutes, err := parseModelsEnv(value)
+		if err != nil {
+			return nil, fmt.Errorf("RELAY_MODELS: %w", err)
+		}
+		for alias, route := range envRoutes {
+			routes[alias] = route
+		}
+	}
+	if len(routes) == 0 {
+		return defaultModelRoutes(), nil
+	}
+	return routes, nil
+}
+
+func defaultModelRoutes() map[string]modelRoute {
+	routes := make(map[string]modelRoute, len(validOpenAIModels))
+	for model := range validOpenAIModels {
+		routes[model] = modelRoute{Backend: backendOpenAI, Model: model}
+	}
+	return routes
+}
+
+func parseModelsYAML(data []byte) (map[string]modelRoute, error) {
+	var file modelsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Models) == 0 {
+		return nil, fmt.Errorf("no models defined")
+	}
+	for alias, route := range file.Models {
+		route, err := normalizeRoute(alias, route)
+		if err != nil {
+			return nil, err
+		}
+		file.Models[alias] = route
+	}
+	return file.Models, nil
+}
+
+// parseModelsEnv reads comma-separated alias=backend[:model][@url] entries,
+// for example "llama3=ollama:llama3.1:8b@http://gpu:11434/api/generate".
+func parseModelsEnv(value string) (map[string]modelRoute, error) {
+	routes := map[string]modelRoute{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		alias, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("entry %q must be alias=backend[:model][@url]", entry)
+		}
+		var route modelRoute
+		spec, route.URL, _ = strings.Cut(spec, "@")
+		route.Backend, route.Model, _ = strings.Cut(spec, ":")
+		route, err := normalizeRoute(strings.TrimSpace(alias), route)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.TrimSpace(alias)] = route
+	}
+	return routes, nil
+}
+
+func normalizeRoute(alias string, route modelRoute) (modelRoute, error) {
+	if alias == "" {
+		return route, fmt.Errorf("model alias must not be empty")
+	}
+	route.Backend = strings.ToLower(strings.TrimSpace(route.Backend))
+	if route.Model == "" {
+		route.Model = alias
+	}
+	switch route.Backend {
+	case backendOpenAI, backendOllama:
+	case backendOpenAICompatible:
+		if route.URL == "" {
+			return route, fmt.Errorf("model %q: openai-compatible backend needs a url", alias)
+		}
+	default:
+		return route, fmt.Errorf("model %q: unknown backend %q (use openai, ollama, or openai-compatible)", alias, route.Backend)
+	}
+	return route, nil
+}
+
+// relayRetryPolicy keeps server-side retries short: clients retry on their
+// own once a rate limit is passed back to them as a 429.
+var relayRetryPolicy = ai.RetryPolicy{
+	MaxAttempts:    2,
+	BaseDelay:      ai.DefaultRetryPolicy.BaseDelay,
+	MaxDelay:       5 * time.Second,
+	AttemptTimeout: ai.DefaultRetryPolicy.AttemptTimeout,
+}
+
+// clientFor returns the AI client for a requested model alias.
+func (s *server) clientFor(alias string) (ai.Client, error) {
+	route, ok := s.models[alias]
+	if !ok {
+		return nil, fmt.Errorf("model %q is not available", alias)
+	}
+	client, err := s.newClient(s.cfg, route, s.prompts)
+	if err != nil {
+		return nil, err
+	}
+	return ai.WithRetry(client, relayRetryPolicy), nil
+}
+
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	resp := ModelsResponse{Models: make([]ModelInfo, 0, len(s.models))}
+	for alias, route := range s.models {
+		resp.Models = append(resp.Models, ModelInfo{ID: alias, Backend: route.Backend})
+	}
+	sort.Slice(resp.Models, func(i, j int) bool {
+		return resp.Models[i].ID < resp.Models[j].ID
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
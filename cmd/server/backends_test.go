@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+func TestParseModelsYAML(t *testing.T) {
+	routes, err := parseModelsYAML([]byte(`
+models:
+  gpt-4o:
+    backend: openai
+  llama3:
+    backend: ollama
+    model: llama3.1:8b
+    url: http://gpu-box:11434/api/generate
+  qwen:
+    backend: openai-compatible
+    url: http://gpu-box:8000/v1
+    api_key_env: VLLM_API_KEY
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes["gpt-4o"].Model != "gpt-4o" {
+		t.Fatalf("gpt-4o model = %q, want alias as default", routes["gpt-4o"].Model)
+	}
+	if got := routes["llama3"]; got.Backend != backendOllama || got.Model != "llama3.1:8b" {
+		t.Fatalf("llama3 = %+v", got)
+	}
+	if got := routes["qwen"]; got.URL != "http://gpu-box:8000/v1" || got.APIKeyEnv != "VLLM_API_KEY" {
+		t.Fatalf("qwen = %+v", got)
+	}
+}
+
+func TestParseModelsRejectsInvalidRoutes(t *testing.T) {
+	if _, err := parseModelsYAML([]byte("models:\n  x:\n    backend: bard\n")); err == nil {
+		t.Fatal("expected unknown backend error")
+	}
+	if _, err := parseModelsEnv("vllm=openai-compatible:qwen"); err == nil {
+		t.Fatal("expected missing url error")
+	}
+}
+
+func TestParseModelsEnv(t *testing.T) {
+	routes, err := parseModelsEnv("gpt-4o=openai, llama3=ollama:llama3.1:8b@http://gpu:11434/api/generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := routes["llama3"]; got.Model != "llama3.1:8b" || got.URL != "http://gpu:11434/api/generate" {
+		t.Fatalf("llama3 = %+v", got)
+	}
+	if got := routes["gpt-4o"]; got.Backend != backendOpenAI || got.Model != "gpt-4o" {
+		t.Fatalf("gpt-4o = %+v", got)
+	}
+}
+
+func TestModelsEndpointAndRouting(t *testing.T) {
+	var used modelRoute
+	routes := map[string]modelRoute{
+		"llama3": {Backend: backendOllama, Model: "llama3.1:8b"},
+		"gpt-4o": {Backend: backendOpenAI, Model: "gpt-4o"},
+	}
+	s := newFakeServer(t, routes, authConfig{}, echoClient{})
+	s.newClient = func(_ config.Config, route modelRoute, _ *ai.Prompts) (ai.Client, error) {
+		used = route
+		return echoClient{}, nil
+	}
+	handler := s.routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	var models ModelsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&models); err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Models) != 2 || models.Models[0].ID != "gpt-4o" || models.Models[1].Backend != backendOllama {
+		t.Fatalf("models = %+v", models.Models)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/suggest-filename", strings.NewReader(`{"model":"llama3","content":"x"}`)))
+	if rec.Code != http.StatusOK || used.Model != "llama3.1:8b" {
+		t.Fatalf("status = %d route = %+v, want llama3 routed to ollama", rec.Code, used)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/suggest-filename", strings.NewReader(`{"model":"gpt-4","content":"x"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 for model outside the allow-list", rec.Code)
+	}
+}
+
+type rateLimitedClient struct{}
+
+func (rateLimitedClient) SuggestFilename(context.Context, string, ai.Options) (ai.Result, error) {
+	return ai.Result{}, &ai.Error{Kind: ai.ErrRateLimited, RetryAfter: 9 * time.Second, Err: errors.New("upstream 429")}
+}
+
+func (c rateLimitedClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts ai.Options) (ai.Result, error) {
+	return c.SuggestFilename(ctx, evidence, opts)
+}
+
+func (c rateLimitedClient) SuggestFilenameCandidates(ctx context.Context, content string, _ int, opts ai.Options) (ai.Result, error) {
+	return c.SuggestFilename(ctx, content, opts)
+}
+
+func (c rateLimitedClient) SuggestFilenameFromImage(ctx context.Context, _ ai.Image, evidence string, k int, opts ai.Options) (ai.Result, error) {
+	return c.SuggestFilenameCandidates(ctx, evidence, k, opts)
+}
+
+func TestUpstreamRateLimitIsPassedToClient(t *testing.T) {
+	handler := newFakeServer(t, defaultModelRoutes(), authConfig{}, rateLimitedClient{}).routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/suggest-filename", strings.NewReader(`{"model":"gpt-4o","content":"x"}`)))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "9" {
+		t.Fatalf("status = %d Retry-After = %q, want 429 and 9", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestUpstreamRateLimitIsClassifiedPerBatchItem(t *testing.T) {
+	handler := newFakeServer(t, defaultModelRoutes(), authConfig{}, rateLimitedClient{}).routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/batch", strings.NewReader(`{"model":"gpt-4o","items":[{"content":"x"}]}`)))
+	var got BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 1 || got.Results[0].Status != http.StatusTooManyRequests || got.Results[0].RetryAfter != 9 {
+		t.Fatalf("results = %+v, want a 429 item retried after 9s", got.Results)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+)
+
+const (
+	// maxBatchItems bounds synchronous batches; larger sets go through jobs.
+	maxBatchItems    = 100
+	maxJobItems      = 5000
+	maxBatchBody     = 32 << 20
+	batchConcurrency = 4
+	jobWorkers       = 2
+	jobQueueSize     = 64
+	jobTTL           = time.Hour
+)
+
+type BatchItem struct {
+	Content      string    `json:"content"`
+	EvidenceOnly bool      `json:"evidence_only,omitempty"`
+	Candidates   int       `json:"candidates,omitempty"`
+	Image        *ai.Image `json:"image,omitempty"`
+	FileTypes    []string  `json:"file_types,omitempty"`
+	Naming       string    `json:"naming,omitempty"`
+	Topics       []string  `json:"topics,omitempty"`
+	Language     string    `json:"language,omitempty"`
+	KeepLanguage bool      `json:"keep_language,omitempty"`
+}
+
+// quotaChars is what the item counts against the daily quota; image bytes
+// count like characters.
+func (item BatchItem) quotaChars() int {
+	chars := len(item.Content)
+	if item.Image != nil {
+		chars += len(item.Image.Data)
+	}
+	return chars
+}
+
+func (item BatchItem) validate() error {
+	if item.Image == nil {
+		return nil
+	}
+	return item.Image.Validate()
+}
+
+type BatchRequest struct {
+	Model string      `json:"model"`
+	Items []BatchItem `json:"items"`
+}
+
+// BatchResponse holds one result per request item, in request order.
+type BatchResponse struct {
+	Results []FilenameResponse `json:"results"`
+	Error   string             `json:"error,omitempty"`
+}
+
+type Job struct {
+	ID      string             `json:"id"`
+	Status  string             `json:"status"`
+	Items   int                `json:"items"`
+	Results []FilenameResponse `json:"results,omitempty"`
+	Error   string             `json:"error,omitempty"`
+
+	req       BatchRequest
+	requestID string
+	// owner is the API key that submitted the job; only it may read the
+	// job. It is empty when the server requires no keys.
+	owner    string
+	finished time.Time
+}
+
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+var errQueueFull = errors.New("job queue is full; retry later")
+
+func (s *server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeBatch(w, r, maxBatchItems)
+	if !ok {
+		return
+	}
+	logger := requestLogger(r.Context())
+	logger.Info("processing batch", "items", len(req.Items), "model", req.Model)
+
+	results, err := s.runBatch(r.Context(), req)
+	if err != nil {
+		logger.Error("batch failed", "error", err)
+		writeJSON(w, http.StatusOK, BatchResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, BatchResponse{Results: results})
+}
+
+func (s *server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeBatch(w, r, maxJobItems)
+	if !ok {
+		return
+	}
+
+	job, err := s.jobs.submit(r.Context(), req)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	requestLogger(r.Context()).Info("queued job", "job_id", job.ID, "items", job.Items)
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	// Another key's job is reported as missing so IDs cannot be probed.
+	job, ok := s.jobs.get(r.PathValue("id"), callerKey(r.Context()))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *server) decodeBatch(w http.ResponseWriter, r *http.Request, maxItems int) (BatchRequest, bool) {
+	var req BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req); err != nil {
+		requestLogger(r.Context()).Warn("failed to decode JSON batch request", "error", err)
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return req, false
+	}
+	if _, ok := s.models[req.Model]; !ok {
+		requestLogger(r.Context()).Warn("invalid model requested", "model", req.Model)
+		writeError(w, http.StatusBadRequest, "Invalid model")
+		return req, false
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("batch must contain 1 to %d items", maxItems))
+		return req, false
+	}
+	chars := 0
+	for i, item := range req.Items {
+		if err := item.validate(); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("item %d: %v", i, err))
+			return req, false
+		}
+		chars += item.quotaChars()
+	}
+	return req, s.chargeQuota(w, r, chars)
+}
+
+// runBatch names every item with one AI client, a few at a time.
+func (s *server) runBatch(ctx context.Context, req BatchRequest) ([]FilenameResponse, error) {
+	client, err := s.clientFor(req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]FilenameResponse, len(req.Items))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range req.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], _ = s.suggest(ctx, client, req.Model, item)
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// jobQueue runs batch jobs in the background. Jobs live in memory only and
+// finished ones are dropped after jobTTL.
+type jobQueue struct {
+	run   func(context.Context, BatchRequest) ([]FilenameResponse, error)
+	queue chan *Job
+
+	// ctx ends with Close, which cancels running jobs and stops the workers.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func newJobQueue(run func(context.Context, BatchRequest) ([]FilenameResponse, error), workers, size int) *jobQueue {
+	q := &jobQueue{
+		run:   run,
+		queue: make(chan *Job, size),
+		jobs:  map[string]*Job{},
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			q.work()
+		}()
+	}
+	return q
+}
+
+// Close stops the workers and waits for them to return. Queued jobs are
+// left queued and running ones fail with a canceled context.
+func (q *jobQueue) Close() {
+	q.cancel()
+	q.workers.Wait()
+}
+
+// submit queues req for the key in ctx; the job's logs carry the request ID
+// from ctx.
+func (q *jobQueue) submit(ctx context.Context, req BatchRequest) (Job, error) {
+	id, err := randomID()
+	if err != nil {
+		return Job{}, err
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	job := &Job{ID: id, Status: jobQueued, Items: len(req.Items), req: req, requestID: requestID, owner: callerKey(ctx)}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pruneLocked(time.Now())
+	select {
+	case q.queue <- job:
+	default:
+		return Job{}, errQueueFull
+	}
+	q.jobs[id] = job
+	return *job, nil
+}
+
+// get returns the job with id if owner submitted it.
+func (q *jobQueue) get(id, owner string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok || job.owner != owner {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (q *jobQueue) work() {
+	for {
+		var job *Job
+		select {
+		case job = <-q.queue:
+		case <-q.ctx.Done():
+			return
+		}
+		q.mu.Lock()
+		job.Status = jobRunning
+		req := job.req
+		q.mu.Unlock()
+
+		results, err := q.run(withRequestID(q.ctx, job.requestID), req)
+
+		q.mu.Lock()
+		job.Status = jobDone
+		job.Results = results
+		if err != nil {
+			job.Status = jobFailed
+			job.Error = err.Error()
+		}
+		job.req = BatchRequest{}
+		job.finished = time.Now()
+		q.mu.Unlock()
+		requestLogger(withRequestID(context.Background(), job.requestID)).Info("job finished", "job_id", job.ID, "status", job.Status)
+	}
+}
+
+func (q *jobQueue) pruneLocked(now time.Time) {
+	for id, job := range q.jobs {
+		if !job.finished.IsZero() && now.Sub(job.finished) > jobTTL {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+type echoClient struct{}
+
+func (echoClient) SuggestFilename(_ context.Context, content string, _ ai.Options) (ai.Result, error) {
+	if content == "fail" {
+		return ai.Result{}, errors.New("model refused")
+	}
+	return ai.Result{Filename: "content-" + content}, nil
+}
+
+func (echoClient) SuggestFilenameFromEvidence(_ context.Context, evidence string, _ ai.Options) (ai.Result, error) {
+	return ai.Result{Filename: "evidence-" + evidence}, nil
+}
+
+func (echoClient) SuggestFilenameCandidates(_ context.Context, content string, k int, _ ai.Options) (ai.Result, error) {
+	candidates := []ai.Candidate{
+		{Filename: "best-" + content, Confidence: 0.8, Rationale: "closest match"},
+		{Filename: "other-" + content, Confidence: 0.3},
+	}
+	return ai.Result{Filename: candidates[0].Filename, Candidates: candidates[:min(k, len(candidates))]}, nil
+}
+
+func (echoClient) SuggestFilenameFromImage(_ context.Context, image ai.Image, evidence string, _ int, _ ai.Options) (ai.Result, error) {
+	name := fmt.Sprintf("%s-%d-bytes-%s", image.MIMEType, len(image.Data), evidence)
+	return ai.Result{Filename: name, Candidates: []ai.Candidate{{Filename: name, Confidence: 0.9}}}, nil
+}
+
+// newFakeServer answers every model with client and stops its job workers
+// when the test ends.
+func newFakeServer(t *testing.T, models map[string]modelRoute, auth authConfig, client ai.Client) *server {
+	t.Helper()
+	s := newServer(config.Config{}, models, auth)
+	s.newClient = func(config.Config, modelRoute, *ai.Prompts) (ai.Client, error) { return client, nil }
+	t.Cleanup(s.jobs.Close)
+	return s
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newFakeServer(t, defaultModelRoutes(), authConfig{}, echoClient{}).routes())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBatchReturnsResultsInOrder(t *testing.T) {
+	server := newTestServer(t)
+
+	body := `{"model":"gpt-4o","items":[{"content":"a"},{"content":"fail"},{"content":"c","evidence_only":true}]}`
+	resp, err := http.Post(server.URL+"/v1/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 3 {
+		t.Fatalf("results = %+v, want 3", got.Results)
+	}
+	if got.Results[0].Filename != "content-a" || got.Results[1].Error != "model refused" || got.Results[2].Filename != "evidence-c" {
+		t.Fatalf("results = %+v", got.Results)
+	}
+}
+
+func TestSuggestFilenameReturnsCandidates(t *testing.T) {
+	server := newTestServer(t)
+	resp, err := http.Post(server.URL+"/suggest-filename", "application/json", strings.NewReader(`{"model":"gpt-4o","content":"notes","candidates":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got FilenameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Filename != "best-notes" || len(got.Candidates) != 2 || got.Candidates[1].Confidence != 0.3 {
+		t.Fatalf("response = %+v", got)
+	}
+}
+
+func TestSuggestFilenameLimitsBodySize(t *testing.T) {
+	server := newTestServer(t)
+	body := `{"model":"gpt-4o","content":"` + strings.Repeat("x", maxBatchBody) + `"}`
+	resp, err := http.Post(server.URL+"/suggest-filename", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 for a body over the limit", resp.StatusCode)
+	}
+}
+
+func TestSuggestFilenameAcceptsImage(t *testing.T) {
+	server := newTestServer(t)
+	// "/9j/AA==" is base64 for the 4-byte JPEG start "\xff\xd8\xff\x00".
+	resp, err := http.Post(server.URL+"/suggest-filename", "application/json", strings.NewReader(`{"model":"gpt-4o","content":"scan","image":{"mime_type":"image/jpeg","data":"/9j/AA=="}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got FilenameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Filename != "image/jpeg-4-bytes-scan" || len(got.Candidates) != 1 {
+		t.Fatalf("response = %+v", got)
+	}
+
+	resp, err = http.Post(server.URL+"/suggest-filename", "application/json", strings.NewReader(`{"model":"gpt-4o","content":"scan","image":{"mime_type":"text/html","data":"PGI+"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unsupported image status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestBatchRejectsInvalidModelAndEmptyBatch(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, body := range []string{
+		`{"model":"not-a-model","items":[{"content":"a"}]}`,
+		`{"model":"gpt-4o","items":[]}`,
+	} {
+		resp, err := http.Post(server.URL+"/v1/batch", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got FilenameResponse
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || got.Error == "" {
+			t.Fatalf("%s: status = %d error = %q, want 400 with JSON error", body, resp.StatusCode, got.Error)
+		}
+	}
+}
+
+func TestJobRunsInBackground(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Post(server.URL+"/v1/jobs", "application/json", strings.NewReader(`{"model":"gpt-4o","items":[{"content":"a"},{"content":"b"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created Job
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || created.ID == "" {
+		t.Fatalf("status = %d job = %+v, want 202 with id", resp.StatusCode, created)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(server.URL + "/v1/jobs/" + created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var job Job
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if job.Status == jobDone {
+			if len(job.Results) != 2 || job.Results[1].Filename != "content-b" {
+				t.Fatalf("job results = %+v", job.Results)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s after deadline", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGetUnknownJob(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/v1/jobs/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestJobIsHiddenFromOtherKeys(t *testing.T) {
+	handler := newAuthServer(t, authConfig{keys: []string{"team-a", "team-b"}}).routes()
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(apiKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/v1/jobs", "team-a", `{"model":"gpt-4o","items":[{"content":"a"}]}`)
+	var created Job
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusAccepted || created.ID == "" {
+		t.Fatalf("status = %d job = %+v, want 202 with id", rec.Code, created)
+	}
+
+	if rec := send(http.MethodGet, "/v1/jobs/"+created.ID, "team-b", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("other key status = %d, want 404", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/v1/jobs/"+created.ID, "team-a", ""); rec.Code != http.StatusOK {
+		t.Fatalf("owner status = %d, want 200", rec.Code)
+	}
+}
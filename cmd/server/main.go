@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/djblackett/bootdev-hackathon/internal/ai"
 	"github.com/djblackett/bootdev-hackathon/internal/config"
@@ -15,13 +19,44 @@ type FilenameRequest struct {
 	Content      string `json:"content"`
 	Model        string `json:"model"`
 	EvidenceOnly bool   `json:"evidence_only,omitempty"`
+	// Candidates asks for up to this many rated alternatives instead of a
+	// single filename.
+	Candidates int `json:"candidates,omitempty"`
+	// Image is a thumbnail for a vision-capable model; Content then holds
+	// its evidence and candidates are always returned.
+	Image *ai.Image `json:"image,omitempty"`
+	// FileTypes names the file's extension and detected type, most
+	// specific first; they pick the prompt's few-shot examples.
+	FileTypes []string `json:"file_types,omitempty"`
+	// Naming replaces the default filename convention in the prompt, such
+	// as "lowercase words separated by underscores".
+	Naming string `json:"naming,omitempty"`
+	// Topics, when set, ask the model to file the file under one of them;
+	// the pick is returned in each candidate's topic.
+	Topics []string `json:"topics,omitempty"`
+	// Language is the ISO 639-1 code of the content's language. Names are
+	// translated to English unless KeepLanguage is set.
+	Language     string `json:"language,omitempty"`
+	KeepLanguage bool   `json:"keep_language,omitempty"`
 }
 
 type FilenameResponse struct {
-	Filename string `json:"filename"`
-	Error    string `json:"error,omitempty"`
+	Filename   string         `json:"filename"`
+	Candidates []ai.Candidate `json:"candidates,omitempty"`
+	// Usage is the tokens the backend reported, so clients can account for
+	// cost; it is omitted when the backend reports none.
+	Usage *ai.Usage `json:"usage,omitempty"`
+	Error string    `json:"error,omitempty"`
+	// Status is the HTTP status a failed item would have had on its own,
+	// such as 429 for a rate limit, so batch clients can tell which
+	// failures are worth retrying. RetryAfter is the wait in seconds the
+	// backend asked for.
+	Status     int `json:"status,omitempty"`
+	RetryAfter int `json:"retry_after,omitempty"`
 }
 
+// validOpenAIModels is the default allow-list when no model routes are
+// configured.
 var validOpenAIModels = map[string]bool{
 	"gpt-3.5-turbo-0125": true,
 	"gpt-3.5-turbo":      true, // alias for gpt-3.5-turbo-0125
@@ -30,83 +65,214 @@ var validOpenAIModels = map[string]bool{
 	"gpt-4-1106-preview": true, // new model
 }
 
+type server struct {
+	cfg     config.Config
+	prompts *ai.Prompts
+	models  map[string]modelRoute
+	auth    authConfig
+	limits  *limiter
+	jobs    *jobQueue
+
+	// newClient and probe reach the backends; tests replace them.
+	newClient func(config.Config, modelRoute, *ai.Prompts) (ai.Client, error)
+	probe     func(context.Context, config.Config, modelRoute) error
+
+	metrics *metrics
+	ready   readiness
+}
+
+func newServer(cfg config.Config, models map[string]modelRoute, auth authConfig) *server {
+	s := &server{cfg: cfg, prompts: ai.DefaultPrompts(), models: models, auth: auth, limits: newLimiter(), metrics: newMetrics(), newClient: newBackendClient, probe: probeBackend}
+	s.jobs = newJobQueue(s.runBatch, jobWorkers, jobQueueSize)
+	return s
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, s.instrument(pattern, h))
+	}
+	handle("/suggest-filename", s.authenticate(s.handleSuggestFilename))
+	handle("POST /v1/batch", s.authenticate(s.handleBatch))
+	handle("POST /v1/jobs", s.authenticate(s.handleCreateJob))
+	handle("GET /v1/jobs/{id}", s.authenticate(s.handleGetJob))
+	handle("GET /v1/models", s.authenticate(s.handleModels))
+	handle("GET /healthz", handleHealthz)
+	handle("GET /readyz", s.handleReadyz)
+	mux.Handle("GET /metrics", s.metrics)
+	return mux
+}
+
 func main() {
-	log.Println("Starting AI filename server...")
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	slog.Info("starting AI filename server")
 
 	// Load environment variables (API keys stay on server)
 	_ = godotenv.Load()
-	log.Println("Environment variables loaded")
 
 	cfg := config.FromEnv()
-	log.Printf("Configuration loaded: %+v", cfg)
-
-	http.HandleFunc("/suggest-filename", func(w http.ResponseWriter, r *http.Request) {
-
-		// Ensure this is a POST request
-		if r.Method != http.MethodPost {
-			log.Printf("Method not allowed: %s", r.Method)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		// Log the request
-		log.Printf("Received %s request to /suggest-filename from %s", r.Method, r.RemoteAddr)
-
-		var req FilenameRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			log.Printf("Failed to decode JSON request: %v", err)
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-		log.Printf("Processing request with model: %s, content length: %d", req.Model, len(req.Content))
-
-		// Validate the model from the request body
-		_, ok := validOpenAIModels[req.Model]
-		if !ok {
-			log.Printf("Invalid model requested: %s", req.Model)
-			http.Error(w, "Invalid model", http.StatusBadRequest)
-			return
-		}
-
-		log.Printf("Using validated model: %s", req.Model)
-
-		// Create AI client (always use OpenAI on server)
-		client, err := ai.NewClient(cfg, false, req.Model)
-		if err != nil {
-			log.Printf("Failed to create AI client: %v", err)
-			resp := FilenameResponse{Error: err.Error()}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(resp)
-			return
-		}
-		log.Println("AI client created successfully")
-
-		var suggested string
-		if req.EvidenceOnly {
-			suggested, err = client.SuggestFilenameFromEvidence(req.Content)
-		} else {
-			suggested, err = client.SuggestFilename(req.Content)
-		}
-		resp := FilenameResponse{Filename: suggested}
-		if err != nil {
-			log.Printf("AI suggestion failed: %v", err)
-			resp.Error = err.Error()
-		} else {
-			log.Printf("AI suggested filename: %s", suggested)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
-		log.Println("Response sent successfully")
-	})
+	slog.Info("configuration loaded", "config", cfg)
+
+	models, err := loadModelRoutes()
+	if err != nil {
+		fatal("invalid model configuration", err)
+	}
+	slog.Info("models loaded", "count", len(models))
+
+	auth, err := authConfigFromEnv()
+	if err != nil {
+		fatal("invalid auth configuration", err)
+	}
+	if len(auth.keys) == 0 {
+		slog.Warn("no RELAY_API_KEYS configured; the server accepts unauthenticated requests")
+	}
+	slog.Info("API keys loaded", "count", len(auth.keys))
+
+	prompts, err := ai.LoadPrompts(cfg.PromptDir)
+	if err != nil {
+		fatal("invalid prompt templates", err)
+	}
+	slog.Info("prompts loaded", "version", prompts.Version())
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	log.Printf("Using port: %s", port)
 
-	log.Printf("AI filename server starting on port %s", port)
-	log.Printf("Server binary: relay-server (from cmd/server/main.go)")
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	slog.Info("AI filename server listening", "port", port)
+	s := newServer(cfg, models, auth)
+	s.prompts = prompts
+	fatal("server stopped", http.ListenAndServe(":"+port, s.routes()))
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func (s *server) handleSuggestFilename(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r.Context())
+
+	// Ensure this is a POST request
+	if r.Method != http.MethodPost {
+		logger.Warn("method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req FilenameRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req); err != nil {
+		logger.Warn("failed to decode JSON request", "error", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	logger.Info("processing request", "model", req.Model, "content_length", len(req.Content))
+
+	// Validate the model from the request body
+	_, ok := s.models[req.Model]
+	if !ok {
+		logger.Warn("invalid model requested", "model", req.Model)
+		http.Error(w, "Invalid model", http.StatusBadRequest)
+		return
+	}
+
+	item := BatchItem{Content: req.Content, EvidenceOnly: req.EvidenceOnly, Candidates: req.Candidates, Image: req.Image, FileTypes: req.FileTypes, Naming: req.Naming, Topics: req.Topics, Language: req.Language, KeepLanguage: req.KeepLanguage}
+	if err := item.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.chargeQuota(w, r, item.quotaChars()) {
+		return
+	}
+
+	// Create the AI client for the backend serving this model
+	client, err := s.clientFor(req.Model)
+	if err != nil {
+		logger.Error("failed to create AI client", "model", req.Model, "error", err)
+		writeJSON(w, http.StatusOK, FilenameResponse{Error: err.Error()})
+		return
+	}
+
+	resp, _ := s.suggest(r.Context(), client, req.Model, item)
+	switch resp.Status {
+	case http.StatusTooManyRequests:
+		// Pass upstream rate limits through so the client backs off and retries.
+		tooManyRequests(w, time.Duration(resp.RetryAfter)*time.Second, resp.Error)
+	case 0:
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		writeError(w, resp.Status, resp.Error)
+	}
+}
+
+// errorStatus is the HTTP status that passes a retryable AI failure on to
+// the client, or 0 for failures reported in a 200 response.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ai.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ai.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ai.ErrUnavailable):
+		return http.StatusBadGateway
+	}
+	return 0
+}
+
+// suggest names one item; AI failures are reported in the response rather
+// than as an HTTP error so batch results stay aligned with their inputs. The
+// error is returned as well for callers that map it to a status.
+func (s *server) suggest(ctx context.Context, client ai.Client, model string, item BatchItem) (FilenameResponse, error) {
+	opts := ai.Options{
+		FileTypes:    item.FileTypes,
+		Naming:       item.Naming,
+		Topics:       item.Topics,
+		Language:     item.Language,
+		KeepLanguage: item.KeepLanguage,
+	}
+	var (
+		result ai.Result
+		err    error
+	)
+	switch {
+	case item.Image != nil:
+		result, err = client.SuggestFilenameFromImage(ctx, *item.Image, item.Content, max(item.Candidates, 1), opts)
+	case item.Candidates > 0:
+		result, err = client.SuggestFilenameCandidates(ctx, item.Content, item.Candidates, opts)
+	case item.EvidenceOnly:
+		result, err = client.SuggestFilenameFromEvidence(ctx, item.Content, opts)
+	default:
+		result, err = client.SuggestFilename(ctx, item.Content, opts)
+	}
+	usage := result.Usage
+	s.metrics.observeAI(model, err, usage)
+
+	logger := requestLogger(ctx)
+	resp := FilenameResponse{Filename: result.Filename, Candidates: result.Candidates}
+	if usage != (ai.Usage{}) {
+		resp.Usage = &usage
+	}
+	if err != nil {
+		logger.Error("AI suggestion failed", "model", model, "error", err)
+		resp.Error = err.Error()
+		resp.Status = errorStatus(err)
+		resp.RetryAfter = int(math.Ceil(ai.RetryAfter(err).Seconds()))
+	} else {
+		logger.Info("AI suggested filename", "model", model, "filename", result.Filename, "prompt_tokens", usage.PromptTokens, "completion_tokens", usage.CompletionTokens)
+	}
+	return resp, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", "error", err)
+	}
+}
+
+// writeError reports a request-level failure in the FilenameResponse shape
+// that clients already decode.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, FilenameResponse{Error: message})
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+)
+
+// latencyBuckets are the histogram upper bounds in seconds. AI calls
+// dominate, so the buckets reach well past typical HTTP latencies.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// metrics collects counters for /metrics in the Prometheus text format.
+type metrics struct {
+	mu       sync.Mutex
+	requests map[[2]string]uint64 // route, status code
+	latency  map[string]*histogram
+	aiCalls  map[[2]string]uint64 // model, result
+	tokens   map[[2]string]uint64 // model, prompt|completion
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: map[[2]string]uint64{},
+		latency:  map[string]*histogram{},
+		aiCalls:  map[[2]string]uint64{},
+		tokens:   map[[2]string]uint64{},
+	}
+}
+
+func (m *metrics) observeRequest(route string, status int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{route, strconv.Itoa(status)}]++
+
+	h, ok := m.latency[route]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[route] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (m *metrics) observeAI(model string, err error, usage ai.Usage) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aiCalls[[2]string{model, result}]++
+	m.tokens[[2]string{model, "prompt"}] += uint64(usage.PromptTokens)
+	m.tokens[[2]string{model, "completion"}] += uint64(usage.CompletionTokens)
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP relay_http_requests_total HTTP requests by route and status code.")
+	fmt.Fprintln(w, "# TYPE relay_http_requests_total counter")
+	for _, labels := range sortedPairs(m.requests) {
+		fmt.Fprintf(w, "relay_http_requests_total{route=%q,code=%q} %d\n", labels[0], labels[1], m.requests[labels])
+	}
+
+	fmt.Fprintln(w, "# HELP relay_http_request_duration_seconds HTTP request latency by route.")
+	fmt.Fprintln(w, "# TYPE relay_http_request_duration_seconds histogram")
+	routes := make([]string, 0, len(m.latency))
+	for route := range m.latency {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		h := m.latency[route]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "relay_http_request_duration_seconds_bucket{route=%q,le=%q} %d\n", route, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "relay_http_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, h.count)
+		fmt.Fprintf(w, "relay_http_request_duration_seconds_sum{route=%q} %g\n", route, h.sum)
+		fmt.Fprintf(w, "relay_http_request_duration_seconds_count{route=%q} %d\n", route, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP relay_ai_requests_total AI backend calls by model and result (ok or error).")
+	fmt.Fprintln(w, "# TYPE relay_ai_requests_total counter")
+	for _, labels := range sortedPairs(m.aiCalls) {
+		fmt.Fprintf(w, "relay_ai_requests_total{model=%q,result=%q} %d\n", labels[0], labels[1], m.aiCalls[labels])
+	}
+
+	fmt.Fprintln(w, "# HELP relay_ai_tokens_total Tokens reported by AI backends by model and type.")
+	fmt.Fprintln(w, "# TYPE relay_ai_tokens_total counter")
+	for _, labels := range sortedPairs(m.tokens) {
+		fmt.Fprintf(w, "relay_ai_tokens_total{model=%q,type=%q} %d\n", labels[0], labels[1], m.tokens[labels])
+	}
+}
+
+func sortedPairs(counts map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] == keys[j][0] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+	return keys
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	readinessTTL    = 15 * time.Second
+	probeTimeout    = 3 * time.Second
+)
+
+type requestIDKey struct{}
+
+// requestLogger returns the default logger tagged with the request ID from
+// ctx, when there is one.
+func requestLogger(ctx context.Context) *slog.Logger {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// statusRecorder remembers the status code a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// instrument assigns a request ID (keeping a caller-supplied X-Request-ID),
+// then records the request in metrics and the access log under route.
+func (s *server) instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id, _ = randomID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := withRequestID(r.Context(), id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+
+		elapsed := time.Since(start)
+		s.metrics.observeRequest(route, rec.status, elapsed)
+		requestLogger(ctx).Info("request completed",
+			"method", r.Method,
+			"route", route,
+			"status", rec.status,
+			"duration_ms", elapsed.Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+		)
+	}
+}
+
+type HealthResponse struct {
+	Status   string            `json:"status"`
+	Backends map[string]string `json:"backends,omitempty"`
+}
+
+func handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// readiness caches backend probe results so frequent k8s probes do not turn
+// into a stream of upstream requests.
+type readiness struct {
+	mu       sync.Mutex
+	checked  time.Time
+	failures map[string]string
+}
+
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	failures := s.checkBackends(r.Context())
+	if len(failures) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{Status: "unavailable", Backends: failures})
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ready"})
+}
+
+// checkBackends probes each distinct backend once and returns the ones that
+// are unreachable, keyed by backend and URL.
+func (s *server) checkBackends(ctx context.Context) map[string]string {
+	s.ready.mu.Lock()
+	defer s.ready.mu.Unlock()
+	if !s.ready.checked.IsZero() && time.Since(s.ready.checked) < readinessTTL {
+		return s.ready.failures
+	}
+
+	targets := map[string]modelRoute{}
+	for _, route := range s.models {
+		targets[strings.TrimSpace(route.Backend+" "+route.URL)] = route
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = map[string]string{}
+	)
+	for name, route := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.probe(ctx, s.cfg, route); err != nil {
+				requestLogger(ctx).Warn("backend not ready", "backend", name, "error", err)
+				mu.Lock()
+				failures[name] = err.Error()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.ready.checked = time.Now()
+	s.ready.failures = failures
+	return failures
+}
+
+// probeBackend checks that a backend answers a cheap listing request. It is
+// the default for server.probe.
+func probeBackend(ctx context.Context, cfg config.Config, route modelRoute) error {
+	var (
+		target string
+		key    string
+	)
+	switch route.Backend {
+	case backendOpenAI:
+		if cfg.OpenAIKey == "" {
+			return fmt.Errorf("OPENAI_API_KEY is not set")
+		}
+		target, key = "https://api.openai.com/v1/models", cfg.OpenAIKey
+	case backendOllama:
+		base := route.URL
+		if base == "" {
+			base = cfg.OllaHost
+		}
+		if base == "" {
+			base = ai.DefaultOllamaURL
+		}
+		u, err := url.Parse(base)
+		if err != nil {
+			return err
+		}
+		u.Path = "/api/tags"
+		target = u.String()
+	case backendOpenAICompatible:
+		target = strings.TrimRight(route.URL, "/") + "/models"
+		key = apiKeyFromEnv(route)
+	default:
+		return fmt.Errorf("unknown backend %q", route.Backend)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+func TestHealthzAndReadyz(t *testing.T) {
+	s := newServer(config.Config{}, map[string]modelRoute{
+		"gpt-4o": {Backend: backendOpenAI, Model: "gpt-4o"},
+		"llama3": {Backend: backendOllama, Model: "llama3", URL: "http://gpu:11434/api/generate"},
+	}, authConfig{keys: []string{"team-a"}})
+	t.Cleanup(s.jobs.Close)
+	s.probe = func(_ context.Context, _ config.Config, route modelRoute) error {
+		if route.Backend == backendOllama {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	handler := s.routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("healthz status = %d, want 200 without an API key", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var got HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || got.Backends["ollama http://gpu:11434/api/generate"] != "connection refused" {
+		t.Fatalf("readyz status = %d body = %+v, want 503 naming the ollama backend", rec.Code, got)
+	}
+}
+
+func TestMetricsCountRequestsAndRequestIDIsEchoed(t *testing.T) {
+	server := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/suggest-filename", strings.NewReader(`{"model":"gpt-4o","content":"a"}`))
+	req.Header.Set(requestIDHeader, "abc123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(requestIDHeader) != "abc123" {
+		t.Fatalf("request id = %q, want caller's id echoed", resp.Header.Get(requestIDHeader))
+	}
+
+	resp, err = http.Post(server.URL+"/suggest-filename", "application/json", strings.NewReader(`{"model":"nope"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(requestIDHeader) == "" {
+		t.Fatal("expected generated request id")
+	}
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		`relay_http_requests_total{route="/suggest-filename",code="200"} 1`,
+		`relay_http_requests_total{route="/suggest-filename",code="400"} 1`,
+		`relay_http_request_duration_seconds_count{route="/suggest-filename"} 2`,
+		`relay_ai_requests_total{model="gpt-4o",result="ok"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsRecordBackendTokenUsage(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"response":"budget-review","prompt_eval_count":120,"eval_count":8}`))
+	}))
+	defer ollama.Close()
+
+	s := newServer(config.Config{}, map[string]modelRoute{
+		"llama3": {Backend: backendOllama, Model: "llama3", URL: ollama.URL + "/api/generate"},
+	}, authConfig{})
+	t.Cleanup(s.jobs.Close)
+	handler := s.routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/suggest-filename", strings.NewReader(`{"model":"llama3","content":"x","evidence_only":true}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"usage":{"prompt_tokens":120,"completion_tokens":8}`) {
+		t.Fatalf("response does not report usage: %s", rec.Body.String())
+	}
+
+	var b strings.Builder
+	s.metrics.write(&b)
+	for _, want := range []string{
+		`relay_ai_tokens_total{model="llama3",type="prompt"} 120`,
+		`relay_ai_tokens_total{model="llama3",type="completion"} 8`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -1,32 +1,213 @@
 package ai
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/djblackett/bootdev-hackathon/internal/config"
 )
 
 type Client interface {
-	SuggestFilename(content string) (string, error)
-	SuggestFilenameFromEvidence(evidence string) (string, error)
+	SuggestFilename(ctx context.Context, content string, opts Options) (Result, error)
+	SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error)
+	// SuggestFilenameCandidates returns up to k filenames for content, best
+	// first, each with the model's self-rated confidence and rationale.
+	SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error)
+	// SuggestFilenameFromImage is SuggestFilenameCandidates for a picture,
+	// with evidence from its metadata. It needs a vision-capable model.
+	SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error)
 }
 
+// Options describe the file a call names. The zero value asks for
+// DefaultNaming in English with the default examples.
+type Options struct {
+	// FileTypes say what kind of file it is, most specific first, such as
+	// the extension and then the detected type. Clients pick few-shot
+	// examples from them.
+	FileTypes []string
+	// Naming is the filename convention, such as "Title Case words
+	// separated by spaces"; empty means DefaultNaming.
+	Naming string
+	// Topics, if any, ask candidates prompts to also pick the one that fits
+	// the file. The pick comes back in Candidate.Topic.
+	Topics []string
+	// Language is the ISO 639-1 code of the content. Prompts ask for names
+	// translated to English, or in that language when KeepLanguage is set.
+	// English and an empty code change nothing.
+	Language     string
+	KeepLanguage bool
+}
+
+// Result is what a call suggested. Candidate calls fill Candidates, best
+// first, and set Filename to the first of them. Usage is the tokens the
+// backend reported; it is also set when a call fails after spending them.
+type Result struct {
+	Filename   string
+	Candidates []Candidate
+	Usage      Usage
+}
+
+// Generation overrides sampling settings. Zero values keep each call's own
+// default; Temperature is a pointer because 0 is a valid setting.
+type Generation struct {
+	Temperature *float64
+	MaxTokens   int
+}
+
+func (g Generation) temperature(fallback float32) float32 {
+	if g.Temperature == nil {
+		return fallback
+	}
+	return float32(*g.Temperature)
+}
+
+func (g Generation) maxTokens(fallback int) int {
+	if g.MaxTokens > 0 {
+		return g.MaxTokens
+	}
+	return fallback
+}
+
+// NewClient picks the backend from cfg, gives it the prompts from
+// cfg.PromptDir, and wraps it in WithRetry and, unless cfg.NoCache is set,
+// WithCache.
 func NewClient(cfg config.Config, local bool, model string) (Client, error) {
-	fmt.Println("Creating AI client...")
-	fmt.Println("Model:", model)
-	fmt.Println("Local:", local)
-	apiKey := cfg.OpenAIKey
+	model = ResolveModel(cfg, SelectBackend(cfg, local), model)
+	prompts, err := LoadPrompts(cfg.PromptDir)
+	if err != nil {
+		return nil, err
+	}
+	client, backend, err := newBackendClient(cfg, local, model, prompts)
+	if err != nil {
+		return nil, err
+	}
+	client = WithRetry(client, retryPolicy(cfg))
+	if cfg.NoCache {
+		return client, nil
+	}
+	cache, err := OpenConfiguredCache(cfg)
+	if err != nil {
+		fmt.Println("AI response cache disabled:", err)
+		return client, nil
+	}
+	return WithCache(client, cache, backend, model, prompts), nil
+}
+
+// OpenConfiguredCache opens cfg.CacheDir, or DefaultCacheDir when unset.
+func OpenConfiguredCache(cfg config.Config) (*Cache, error) {
+	dir := cfg.CacheDir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	return OpenCache(dir, cfg.CacheTTL)
+}
+
+func retryPolicy(cfg config.Config) RetryPolicy {
+	policy := DefaultRetryPolicy
+	if cfg.AIAttempts > 0 {
+		policy.MaxAttempts = cfg.AIAttempts
+	}
+	if cfg.AITimeout > 0 {
+		policy.AttemptTimeout = cfg.AITimeout
+	}
+	return policy
+}
+
+// SelectBackend returns cfg.Backend, or the backend implied by the older
+// implicit rules when it is empty: --local means Ollama, an OpenAI key means
+// OpenAI, and otherwise the relay server.
+func SelectBackend(cfg config.Config, local bool) string {
 	switch {
+	case cfg.Backend != "":
+		return cfg.Backend
 	case local:
-		fmt.Println("Using local Ollama client with model:", model)
-		return NewOllamaClient(model), nil
-	case apiKey != "":
+		return config.BackendOllama
+	case cfg.OpenAIKey != "":
+		return config.BackendOpenAI
+	case cfg.ServerURL != "":
+		return config.BackendServer
+	}
+	return ""
+}
+
+// ResolveModel returns model if set, then the backend config's model, then
+// the backend's default. openai-compatible servers have no default.
+func ResolveModel(cfg config.Config, backend, model string) string {
+	if model != "" {
+		return model
+	}
+	if model := cfg.BackendConfigs[backend].Model; model != "" {
+		return model
+	}
+	switch backend {
+	case config.BackendOllama:
+		return "mistral"
+	case config.BackendAnthropic:
+		return "claude-3-5-haiku-latest"
+	case config.BackendOpenAI, config.BackendServer:
+		return "gpt-3.5-turbo"
+	}
+	return ""
+}
+
+// newBackendClient also returns a name for the backend that identifies it in
+// cache keys. model has already been resolved. The relay server renders its
+// own prompts, so it ignores prompts.
+func newBackendClient(cfg config.Config, local bool, model string, prompts *Prompts) (Client, string, error) {
+	backend := SelectBackend(cfg, local)
+	block := cfg.BackendConfigs[backend]
+	gen := Generation{Temperature: block.Temperature, MaxTokens: block.MaxTokens}
+
+	fmt.Println("Creating AI client...")
+	fmt.Println("Backend:", backend)
+	fmt.Println("Model:", model)
+	switch backend {
+	case config.BackendOllama:
+		url := cmp.Or(block.BaseURL, cfg.OllaHost, DefaultOllamaURL)
+		fmt.Println("Using Ollama at", url)
+		return NewOllamaClientWithURL(url, model).WithGeneration(gen).WithPrompts(prompts), "ollama " + url, nil
+	case config.BackendOpenAI:
+		key := block.APIKey(cfg.OpenAIKey)
+		if key == "" {
+			return nil, "", errors.New("openai backend needs OPENAI_API_KEY or api_key_env")
+		}
+		if block.BaseURL != "" {
+			return NewOpenAICompatibleClient(block.BaseURL, key, model).WithGeneration(gen).WithPrompts(prompts), "openai " + block.BaseURL, nil
+		}
 		fmt.Println("Using OpenAI client with web API - no relay server")
-		return NewOpenAIClient(apiKey, model), nil
-	case cfg.ServerURL != "": // new: check if server URL is configured
-		fmt.Println("Using HTTP client with remote server URL:", cfg.ServerURL)
-		return NewHTTPClient(cfg.ServerURL, model), nil
+		return NewOpenAIClient(key, model).WithGeneration(gen).WithPrompts(prompts), "openai", nil
+	case config.BackendOpenAICompatible:
+		if block.BaseURL == "" {
+			return nil, "", errors.New("openai-compatible backend needs a base URL")
+		}
+		if model == "" {
+			return nil, "", errors.New("openai-compatible backend needs a model")
+		}
+		fmt.Println("Using OpenAI-compatible server at", block.BaseURL)
+		return NewOpenAICompatibleClient(block.BaseURL, block.APIKey(""), model).WithGeneration(gen).WithPrompts(prompts), "openai-compatible " + block.BaseURL, nil
+	case config.BackendAnthropic:
+		key := block.APIKey(os.Getenv("ANTHROPIC_API_KEY"))
+		if key == "" {
+			return nil, "", errors.New("anthropic backend needs ANTHROPIC_API_KEY or api_key_env")
+		}
+		url := cmp.Or(block.BaseURL, DefaultAnthropicURL)
+		return NewAnthropicClient(url, key, model).WithGeneration(gen).WithPrompts(prompts), "anthropic " + url, nil
+	case config.BackendServer:
+		url := cmp.Or(block.BaseURL, cfg.ServerURL)
+		if url == "" {
+			return nil, "", errors.New("server backend needs AI_SERVER_URL or a base URL")
+		}
+		fmt.Println("Using HTTP client with remote server URL:", url)
+		client := NewHTTPClient(url, model).WithAPIKey(block.APIKey(cfg.ServerAPIKey)).WithBatching(cfg.ServerBatchSize, DefaultBatchWait)
+		return client, "server " + url, nil
+	case "":
+		return nil, "", errors.New("no AI backend configured")
 	}
-	return nil, errors.New("no AI backend configured")
+	return nil, "", fmt.Errorf("unknown AI backend %q", backend)
 }
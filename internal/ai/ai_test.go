@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+func TestSelectBackendKeepsImplicitRules(t *testing.T) {
+	tests := []struct {
+		cfg   config.Config
+		local bool
+		want  string
+	}{
+		{config.Config{OpenAIKey: "k", ServerURL: "http://relay"}, true, config.BackendOllama},
+		{config.Config{OpenAIKey: "k", ServerURL: "http://relay"}, false, config.BackendOpenAI},
+		{config.Config{ServerURL: "http://relay"}, false, config.BackendServer},
+		{config.Config{Backend: config.BackendAnthropic, OpenAIKey: "k"}, false, config.BackendAnthropic},
+		{config.Config{}, false, ""},
+	}
+	for _, tt := range tests {
+		if got := SelectBackend(tt.cfg, tt.local); got != tt.want {
+			t.Errorf("SelectBackend(%+v, %v) = %q, want %q", tt.cfg, tt.local, got, tt.want)
+		}
+	}
+}
+
+func TestNewClientUsesOpenAICompatibleBlock(t *testing.T) {
+	var got struct {
+		Model       string  `json:"model"`
+		MaxTokens   int     `json:"max_tokens"`
+		Temperature float64 `json:"temperature"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer lm-key" {
+			t.Errorf("path = %s auth = %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"lab-results-march"}}],"usage":{"prompt_tokens":10,"completion_tokens":3}}`))
+	}))
+	defer server.Close()
+	t.Setenv("LMSTUDIO_KEY", "lm-key")
+
+	temperature := 0.7
+	cfg := config.Config{
+		Backend: config.BackendOpenAICompatible,
+		NoCache: true,
+		BackendConfigs: map[string]config.BackendConfig{
+			config.BackendOpenAICompatible: {
+				BaseURL:     server.URL + "/v1",
+				APIKeyEnv:   "LMSTUDIO_KEY",
+				Model:       "qwen2.5-7b-instruct",
+				Temperature: &temperature,
+				MaxTokens:   48,
+			},
+		},
+	}
+	client, err := NewClient(cfg, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.SuggestFilenameFromEvidence(context.Background(), "evidence", Options{})
+	if err != nil || result.Filename != "lab-results-march" {
+		t.Fatalf("SuggestFilenameFromEvidence = %+v, %v", result, err)
+	}
+	if got.Model != "qwen2.5-7b-instruct" || got.MaxTokens != 48 || got.Temperature < 0.69 || got.Temperature > 0.71 {
+		t.Fatalf("request = %+v", got)
+	}
+}
+
+func TestNewClientRequiresBackendSettings(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	for _, cfg := range []config.Config{
+		{Backend: config.BackendOpenAICompatible, NoCache: true},
+		{Backend: config.BackendAnthropic, NoCache: true},
+		{Backend: config.BackendServer, NoCache: true},
+		{NoCache: true},
+	} {
+		if _, err := NewClient(cfg, false, ""); err == nil {
+			t.Errorf("NewClient(%+v) succeeded, want a configuration error", cfg)
+		}
+	}
+}
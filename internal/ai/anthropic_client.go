@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DefaultAnthropicURL is the Messages API host used when no base URL is set.
+const DefaultAnthropicURL = "https://api.anthropic.com"
+
+const anthropicVersion = "2023-06-01"
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicReq struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float32            `json:"temperature"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+}
+
+type anthropicResp struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// AnthropicClient calls the Anthropic Messages API over plain HTTP.
+type AnthropicClient struct {
+	baseURL string
+	key     string
+	model   string
+	gen     Generation
+	prompts *Prompts
+	client  *http.Client
+}
+
+// NewAnthropicClient uses DefaultAnthropicURL when baseURL is empty.
+func NewAnthropicClient(baseURL, key, model string) *AnthropicClient {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicURL
+	}
+	return &AnthropicClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		key:     key,
+		model:   model,
+		prompts: DefaultPrompts(),
+		client:  &http.Client{},
+	}
+}
+
+// WithGeneration overrides temperature and max tokens for every call.
+func (a *AnthropicClient) WithGeneration(gen Generation) *AnthropicClient {
+	a.gen = gen
+	return a
+}
+
+// WithPrompts replaces the built-in prompt templates.
+func (a *AnthropicClient) WithPrompts(prompts *Prompts) *AnthropicClient {
+	a.prompts = prompts
+	return a
+}
+
+func (a *AnthropicClient) SuggestFilename(ctx context.Context, content string, opts Options) (Result, error) {
+	prompt, err := a.prompts.render(opts, "filename.tmpl", content, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := a.prompts.renderSystem(opts, "filename.tmpl", 0)
+	if err != nil {
+		return Result{}, err
+	}
+	text, usage, err := a.complete(ctx, system, prompt, 32, 0.2)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	return Result{Filename: strings.TrimSpace(strings.SplitN(text, "\n", 2)[0]), Usage: usage}, nil
+}
+
+func (a *AnthropicClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	prompt, err := a.prompts.render(opts, "evidence.tmpl", evidence, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := a.prompts.renderSystem(opts, "evidence.tmpl", 0)
+	if err != nil {
+		return Result{}, err
+	}
+	text, usage, err := a.complete(ctx, system, prompt, 32, 0.2)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	return Result{Filename: strings.TrimSpace(strings.SplitN(text, "\n", 2)[0]), Usage: usage}, nil
+}
+
+func (a *AnthropicClient) SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error) {
+	k = clampCandidates(k)
+	prompt, err := a.prompts.render(opts, "candidates.tmpl", content, k)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := a.prompts.renderSystem(opts, "candidates.tmpl", k)
+	if err != nil {
+		return Result{}, err
+	}
+	text, usage, err := a.complete(ctx, system, prompt, candidatesMaxTokens(k), 0.4)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	candidates, err := parseCandidates(text, k)
+	return candidatesResult(candidates, usage), err
+}
+
+func (a *AnthropicClient) SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error) {
+	k = clampCandidates(k)
+	prompt, err := a.prompts.render(opts, "image.tmpl", evidence, k)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := a.prompts.renderSystem(opts, "image.tmpl", k)
+	if err != nil {
+		return Result{}, err
+	}
+	picture := anthropicBlock{
+		Type:   "image",
+		Source: &anthropicImageSource{Type: "base64", MediaType: image.MIMEType, Data: image.base64()},
+	}
+	text, usage, err := a.complete(ctx, system, prompt, candidatesMaxTokens(k), 0.4, picture)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	candidates, err := parseCandidates(text, k)
+	return candidatesResult(candidates, usage), err
+}
+
+// complete sends prompt as the user message, after any extra content blocks
+// such as images, and returns the text with the tokens it used.
+func (a *AnthropicClient) complete(ctx context.Context, system, prompt string, maxTokens int, temperature float32, blocks ...anthropicBlock) (string, Usage, error) {
+	blocks = append(blocks, anthropicBlock{Type: "text", Text: prompt})
+	body, err := json.Marshal(anthropicReq{
+		Model:       a.model,
+		MaxTokens:   a.gen.maxTokens(maxTokens),
+		Temperature: a.gen.temperature(temperature),
+		System:      system,
+		Messages:    []anthropicMessage{{Role: "user", Content: blocks}},
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.key)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", Usage{}, classify(err)
+	}
+	defer resp.Body.Close()
+
+	var out anthropicResp
+	if resp.StatusCode != http.StatusOK {
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		message := ""
+		if out.Error != nil {
+			message = out.Error.Message
+		}
+		return "", Usage{}, statusError(resp, message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", Usage{}, badResponse("decode anthropic response: %w", err)
+	}
+	usage := Usage{PromptTokens: out.Usage.InputTokens, CompletionTokens: out.Usage.OutputTokens}
+
+	var text strings.Builder
+	for _, block := range out.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", usage, badResponse("anthropic response had no text content")
+	}
+	return text.String(), usage, nil
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicClientSendsMessagesRequest(t *testing.T) {
+	var got anthropicReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("path = %s headers = %v", r.URL.Path, r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"content":[{"type":"text","text":"quarterly-budget-review\nextra"}],"usage":{"input_tokens":120,"output_tokens":9}}`))
+	}))
+	defer server.Close()
+
+	temperature := 0.0
+	client := NewAnthropicClient(server.URL, "test-key", "claude-test").WithGeneration(Generation{Temperature: &temperature, MaxTokens: 50})
+	result, err := client.SuggestFilenameFromEvidence(context.Background(), "evidence", Options{})
+	if err != nil || result.Filename != "quarterly-budget-review" {
+		t.Fatalf("SuggestFilenameFromEvidence = %+v, %v", result, err)
+	}
+	if got.Model != "claude-test" || got.MaxTokens != 50 || got.Temperature != 0 || len(got.Messages) != 1 || !strings.HasPrefix(got.System, "You create concise filenames") {
+		t.Fatalf("request = %+v", got)
+	}
+	if result.Usage.PromptTokens != 120 || result.Usage.CompletionTokens != 9 {
+		t.Fatalf("usage = %+v", result.Usage)
+	}
+}
+
+func TestAnthropicClientClassifiesOverload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(529)
+		w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewAnthropicClient(server.URL, "k", "m").SuggestFilename(context.Background(), "text", Options{})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+}
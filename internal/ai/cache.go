@@ -0,0 +1,241 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached suggestions stay valid.
+const DefaultCacheTTL = 30 * 24 * time.Hour
+
+// Cache stores AI responses on disk, one JSON file per key under dir, so a
+// re-run with different thresholds does not pay for the same calls again.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cacheEntry struct {
+	Created    time.Time   `json:"created"`
+	Filename   string      `json:"filename,omitempty"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// DefaultCacheDir is ai-cache under the user cache directory.
+func DefaultCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "ai-file-renamer", "ai-cache"), nil
+}
+
+// OpenCache uses dir, creating it if needed. A ttl of 0 or less means
+// DefaultCacheTTL.
+func OpenCache(dir string, ttl time.Duration) (*Cache, error) {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+// Hits and Misses count lookups since the cache was opened.
+func (c *Cache) Hits() int64   { return c.hits.Load() }
+func (c *Cache) Misses() int64 { return c.misses.Load() }
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+func (c *Cache) get(key string) (cacheEntry, bool) {
+	var entry cacheEntry
+	data, err := os.ReadFile(c.path(key))
+	if err == nil && json.Unmarshal(data, &entry) == nil && c.now().Sub(entry.Created) < c.ttl {
+		c.hits.Add(1)
+		return entry, true
+	}
+	c.misses.Add(1)
+	return cacheEntry{}, false
+}
+
+// put writes through a temporary file so concurrent workers and interrupted
+// runs never leave a half-written entry behind. Failures only cost a future
+// cache miss, so they are not reported.
+func (c *Cache) put(key string, entry cacheEntry) {
+	entry.Created = c.now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// CacheStats describes what is on disk.
+type CacheStats struct {
+	Dir     string
+	Entries int
+	Expired int
+	Bytes   int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+func (c *Cache) Stats() (CacheStats, error) {
+	stats := CacheStats{Dir: c.dir}
+	err := c.walk(func(path string, entry cacheEntry, size int64) error {
+		stats.Entries++
+		stats.Bytes += size
+		if c.now().Sub(entry.Created) >= c.ttl {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || entry.Created.Before(stats.Oldest) {
+			stats.Oldest = entry.Created
+		}
+		if entry.Created.After(stats.Newest) {
+			stats.Newest = entry.Created
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Clear removes cached entries, or only expired ones, and returns how many
+// were removed.
+func (c *Cache) Clear(expiredOnly bool) (int, error) {
+	removed := 0
+	err := c.walk(func(path string, entry cacheEntry, _ int64) error {
+		if expiredOnly && c.now().Sub(entry.Created) < c.ttl {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func (c *Cache) walk(visit func(path string, entry cacheEntry, size int64) error) error {
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var entry cacheEntry
+		// Unreadable entries count as expired so Clear can remove them.
+		_ = json.Unmarshal(data, &entry)
+		return visit(path, entry, int64(len(data)))
+	})
+}
+
+type cachingClient struct {
+	next    Client
+	cache   *Cache
+	backend string
+	model   string
+	prompts *Prompts
+}
+
+// WithCache answers repeated calls from cache. Keys cover backend, model,
+// the prompts' version, few-shot example set, naming and language rules
+// and topics, the call kind, and a hash of the content, so changing any of
+// them misses. A nil prompts means DefaultPrompts. Errors are never cached.
+func WithCache(next Client, cache *Cache, backend, model string, prompts *Prompts) Client {
+	if prompts == nil {
+		prompts = DefaultPrompts()
+	}
+	return &cachingClient{next: next, cache: cache, backend: backend, model: model, prompts: prompts}
+}
+
+func (c *cachingClient) key(opts Options, kind, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	version := c.prompts.Version() + "/" + c.prompts.ExampleSet(opts) + "/" + opts.naming() + "/" + opts.languageRule() + "/" + strings.Join(opts.Topics, ",")
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%x", c.backend, c.model, version, kind, sum)))
+	return hex.EncodeToString(key[:])
+}
+
+func (c *cachingClient) SuggestFilename(ctx context.Context, content string, opts Options) (Result, error) {
+	return c.filename(ctx, opts, "content", content, c.next.SuggestFilename)
+}
+
+func (c *cachingClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	return c.filename(ctx, opts, "evidence", evidence, c.next.SuggestFilenameFromEvidence)
+}
+
+// filename and candidates answer hits with zero Usage: nothing was spent.
+func (c *cachingClient) filename(ctx context.Context, opts Options, kind, content string, call func(context.Context, string, Options) (Result, error)) (Result, error) {
+	key := c.key(opts, kind, content)
+	if entry, ok := c.cache.get(key); ok && entry.Filename != "" {
+		return Result{Filename: entry.Filename}, nil
+	}
+	result, err := call(ctx, content, opts)
+	if err == nil {
+		c.cache.put(key, cacheEntry{Filename: result.Filename})
+	}
+	return result, err
+}
+
+func (c *cachingClient) SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error) {
+	return c.candidates(ctx, opts, "candidates-"+strconv.Itoa(clampCandidates(k)), content, func(ctx context.Context) (Result, error) {
+		return c.next.SuggestFilenameCandidates(ctx, content, k, opts)
+	})
+}
+
+func (c *cachingClient) SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error) {
+	content := image.MIMEType + "\x00" + string(image.Data) + "\x00" + evidence
+	return c.candidates(ctx, opts, "image-"+strconv.Itoa(clampCandidates(k)), content, func(ctx context.Context) (Result, error) {
+		return c.next.SuggestFilenameFromImage(ctx, image, evidence, k, opts)
+	})
+}
+
+func (c *cachingClient) candidates(ctx context.Context, opts Options, kind, content string, call func(context.Context) (Result, error)) (Result, error) {
+	key := c.key(opts, kind, content)
+	if entry, ok := c.cache.get(key); ok && len(entry.Candidates) > 0 {
+		return candidatesResult(entry.Candidates, Usage{}), nil
+	}
+	result, err := call(ctx)
+	if err == nil {
+		c.cache.put(key, cacheEntry{Candidates: result.Candidates})
+	}
+	return result, err
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheAnswersRepeatedCalls(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &scriptedClient{}
+	client := WithCache(next, cache, "openai", "gpt-4o", nil)
+	ctx := context.Background()
+
+	for i := range 2 {
+		named, err := client.SuggestFilenameFromEvidence(ctx, "evidence", Options{})
+		if err != nil || named.Filename != "named-evidence" {
+			t.Fatalf("SuggestFilenameFromEvidence = %+v, %v", named, err)
+		}
+		listed, err := client.SuggestFilenameCandidates(ctx, "evidence", 3, Options{})
+		if err != nil || len(listed.Candidates) != 1 {
+			t.Fatalf("SuggestFilenameCandidates = %+v, %v", listed, err)
+		}
+		if i == 1 && (named.Usage != Usage{} || listed.Usage != Usage{}) {
+			t.Fatalf("usage = %+v and %+v, want none spent on hits", named.Usage, listed.Usage)
+		}
+	}
+	if next.calls != 2 {
+		t.Fatalf("backend calls = %d, want one per kind", next.calls)
+	}
+	if cache.Hits() != 2 || cache.Misses() != 2 {
+		t.Fatalf("hits = %d misses = %d, want 2 and 2", cache.Hits(), cache.Misses())
+	}
+
+	other := WithCache(next, cache, "openai", "gpt-4o-mini", nil)
+	if _, err := other.SuggestFilenameFromEvidence(ctx, "evidence", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if next.calls != 3 {
+		t.Fatalf("backend calls = %d, want a miss for another model", next.calls)
+	}
+}
+
+func TestCacheDoesNotStoreErrors(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &scriptedClient{errs: []error{&Error{Kind: ErrBadResponse}}}
+	client := WithCache(next, cache, "ollama", "mistral", nil)
+	if _, err := client.SuggestFilename(context.Background(), "a", Options{}); err == nil {
+		t.Fatal("expected the backend error")
+	}
+	if result, err := client.SuggestFilename(context.Background(), "a", Options{}); err != nil || result.Filename != "named-a" {
+		t.Fatalf("SuggestFilename = %+v, %v", result, err)
+	}
+}
+
+func TestCacheExpiresAndClears(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	next := &scriptedClient{}
+	client := WithCache(next, cache, "openai", "gpt-4o", nil)
+	ctx := context.Background()
+
+	client.SuggestFilename(ctx, "old", Options{})
+	now = now.Add(2 * time.Hour)
+	client.SuggestFilename(ctx, "new", Options{})
+	client.SuggestFilename(ctx, "old", Options{})
+	if next.calls != 3 {
+		t.Fatalf("backend calls = %d, want the expired entry refetched", next.calls)
+	}
+
+	now = now.Add(90 * time.Minute)
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 2 || stats.Expired != 2 {
+		t.Fatalf("stats = %+v, want 2 entries, both expired", stats)
+	}
+	removed, err := cache.Clear(true)
+	if err != nil || removed != 2 {
+		t.Fatalf("Clear(expired) = %d, %v", removed, err)
+	}
+	if stats, _ := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("entries after clear = %d", stats.Entries)
+	}
+}
+
+func TestCacheKeyCoversPromptsExamplesAndNaming(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &scriptedClient{}
+	client := WithCache(next, cache, "openai", "gpt-4o", nil)
+	ctx := context.Background()
+	csv := Options{FileTypes: []string{".csv", "csv"}}
+
+	client.SuggestFilenameFromEvidence(ctx, "evidence", csv)
+	client.SuggestFilenameFromEvidence(ctx, "evidence", Options{FileTypes: []string{".tsv", "tsv"}})
+	if next.calls != 1 {
+		t.Fatalf("backend calls = %d, want one for two files sharing an example set", next.calls)
+	}
+	client.SuggestFilenameFromEvidence(ctx, "evidence", Options{FileTypes: []string{".go", "go"}})
+	if next.calls != 2 {
+		t.Fatalf("backend calls = %d, want a miss for another example set", next.calls)
+	}
+
+	underscores := csv
+	underscores.Naming = "lowercase words separated by underscores"
+	client.SuggestFilenameFromEvidence(ctx, "evidence", underscores)
+	if next.calls != 3 {
+		t.Fatalf("backend calls = %d, want a miss for another naming rule", next.calls)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "evidence.tmpl"), "Name this: {{.Content}}")
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithCache(next, cache, "openai", "gpt-4o", prompts).SuggestFilenameFromEvidence(ctx, "evidence", csv)
+	if next.calls != 4 {
+		t.Fatalf("backend calls = %d, want a miss for overridden prompts", next.calls)
+	}
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+)
+
+// MaxCandidates caps how many alternatives a single call may ask for.
+const MaxCandidates = 10
+
+// Candidate is one filename suggestion with the model's own rating of how
+// well it fits (0-1) and a short reason. Topic is set when the call was
+// made with Options.Topics and the model picked one.
+type Candidate struct {
+	Filename   string  `json:"filename"`
+	Confidence float64 `json:"confidence"`
+	Rationale  string  `json:"rationale,omitempty"`
+	Topic      string  `json:"topic,omitempty"`
+}
+
+func clampCandidates(k int) int {
+	return min(max(k, 1), MaxCandidates)
+}
+
+// candidatesMaxTokens is the response limit for a call asking for k
+// candidates: room for the JSON wrapper plus a name and rationale each.
+func candidatesMaxTokens(k int) int {
+	return 40 + 60*k
+}
+
+// candidatesResult is the Result of a candidate call.
+func candidatesResult(candidates []Candidate, usage Usage) Result {
+	result := Result{Candidates: candidates, Usage: usage}
+	if len(candidates) > 0 {
+		result.Filename = candidates[0].Filename
+	}
+	return result
+}
+
+// parseCandidates reads the JSON a model returned for candidates.tmpl,
+// tolerating markdown fences and a bare array. Candidates come back sorted
+// by confidence and limited to k.
+func parseCandidates(raw string, k int) ([]Candidate, error) {
+	var parsed struct {
+		Candidates []Candidate `json:"candidates"`
+	}
+	start := strings.IndexAny(raw, "{[")
+	end := strings.LastIndexAny(raw, "}]")
+	if start == -1 || end < start {
+		return nil, badResponse("could not find JSON in candidates response: %q", raw)
+	}
+	body := raw[start : end+1]
+	if body[0] == '[' {
+		if err := json.Unmarshal([]byte(body), &parsed.Candidates); err != nil {
+			return nil, badResponse("parse candidates JSON: %w (raw=%s)", err, body)
+		}
+	} else if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+		return nil, badResponse("parse candidates JSON: %w (raw=%s)", err, body)
+	}
+
+	candidates := make([]Candidate, 0, len(parsed.Candidates))
+	seen := map[string]bool{}
+	for _, c := range parsed.Candidates {
+		c.Filename = strings.TrimSpace(strings.SplitN(c.Filename, "\n", 2)[0])
+		if c.Filename == "" || seen[c.Filename] {
+			continue
+		}
+		seen[c.Filename] = true
+		if math.IsNaN(c.Confidence) {
+			c.Confidence = 0
+		}
+		c.Confidence = min(max(c.Confidence, 0), 1)
+		c.Rationale = strings.TrimSpace(c.Rationale)
+		c.Topic = strings.TrimSpace(c.Topic)
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		return nil, badResponse("candidates response had no filenames: %q", raw)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates, nil
+}
@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCandidatesSortsClampsAndLimits(t *testing.T) {
+	raw := "```json\n" + `{"candidates":[
+		{"filename":"budget-review-2023","confidence":0.4,"rationale":"mentions a budget"},
+		{"filename":"north-region-quarterly-budget","confidence":1.7,"rationale":"title and region"},
+		{"filename":"budget-review-2023","confidence":0.3},
+		{"filename":"","confidence":0.9},
+		{"filename":"meeting-minutes","confidence":0.1}
+	]}` + "\n```"
+
+	got, err := parseCandidates(raw, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("candidates = %+v, want 2", got)
+	}
+	if got[0].Filename != "north-region-quarterly-budget" || got[0].Confidence != 1 {
+		t.Fatalf("first = %+v, want the clamped best candidate", got[0])
+	}
+	if got[1].Filename != "budget-review-2023" || got[1].Rationale != "mentions a budget" {
+		t.Fatalf("second = %+v", got[1])
+	}
+}
+
+func TestParseCandidatesAcceptsBareArray(t *testing.T) {
+	got, err := parseCandidates(`[{"filename":"tax-return-2021","confidence":0.6}]`, 3)
+	if err != nil || len(got) != 1 || got[0].Filename != "tax-return-2021" {
+		t.Fatalf("parseCandidates = %+v, %v", got, err)
+	}
+}
+
+func TestParseCandidatesRejectsProse(t *testing.T) {
+	for _, raw := range []string{"tax-return-2021", `{"candidates":[]}`, `{"candidates":`} {
+		if _, err := parseCandidates(raw, 3); !errors.Is(err, ErrBadResponse) {
+			t.Fatalf("parseCandidates(%q) err = %v, want ErrBadResponse", raw, err)
+		}
+	}
+}
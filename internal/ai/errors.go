@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Error kinds returned by every backend. Check them with errors.Is:
+//
+//	if errors.Is(err, ai.ErrRateLimited) { ... }
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrAuth        = errors.New("authentication failed")
+	ErrBadResponse = errors.New("bad response")
+	ErrTimeout     = errors.New("timed out")
+	ErrUnavailable = errors.New("backend unavailable")
+)
+
+// Error is a classified backend failure. RetryAfter is set when the backend
+// said how long to wait before trying again.
+type Error struct {
+	Kind       error
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// RetryAfter returns the wait a backend asked for, or zero.
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+// Retryable reports whether another attempt could succeed. Models sometimes
+// answer with malformed JSON, so bad responses are retried as well.
+func Retryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, ErrBadResponse)
+}
+
+func badResponse(format string, args ...any) error {
+	return &Error{Kind: ErrBadResponse, Err: fmt.Errorf(format, args...)}
+}
+
+// kindForStatus maps an HTTP status to an error kind, or nil for statuses
+// that are not worth classifying (other 4xx errors are the caller's fault).
+func kindForStatus(status int) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status >= 500:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// statusError classifies a non-200 response from a relay server or Ollama.
+// message is the error text from the body, when the body had one.
+func statusError(resp *http.Response, message string) error {
+	if message == "" {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		message = strings.TrimSpace(string(snippet))
+	}
+	err := fmt.Errorf("%s returned %s", resp.Request.URL.Redacted(), resp.Status)
+	if message != "" {
+		err = fmt.Errorf("%w: %s", err, message)
+	}
+	kind := kindForStatus(resp.StatusCode)
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")), Err: err}
+}
+
+// parseRetryAfter reads either form of the header: delay seconds or an
+// HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// classify wraps transport and go-openai errors in an *Error when their kind
+// is known, leaving everything else untouched.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: ErrTimeout, Err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &Error{Kind: ErrTimeout, Err: err}
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if kind := kindForStatus(apiErr.HTTPStatusCode); kind != nil {
+			return &Error{Kind: kind, Err: err}
+		}
+		return err
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		if kind := kindForStatus(reqErr.HTTPStatusCode); kind != nil {
+			return &Error{Kind: kind, Err: err}
+		}
+		return err
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+	return err
+}
@@ -2,26 +2,107 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 )
 
 type HTTPClient struct {
 	baseURL string
 	model   string
+	apiKey  string
 	client  *http.Client
+
+	// Batching groups concurrent calls into one POST /v1/batch. A batch is
+	// sent once batchSize calls are waiting or batchWait has passed.
+	batchSize int
+	batchWait time.Duration
+	batchMu   sync.Mutex
+	pending   []*pendingCall
+	timer     *time.Timer
 }
 
+// DefaultBatchWait is how long a partial batch waits for more calls.
+const DefaultBatchWait = 250 * time.Millisecond
+
+// maxBatchTime bounds a batch none of whose callers set a deadline, so a
+// relay that hangs cannot hold the batch's goroutine forever.
+var maxBatchTime = DefaultRetryPolicy.AttemptTimeout
+
 type filenameRequest struct {
 	Content      string `json:"content"`
 	Model        string `json:"model"`
 	EvidenceOnly bool   `json:"evidence_only,omitempty"`
+	Candidates   int    `json:"candidates,omitempty"`
+	Image        *Image `json:"image,omitempty"`
+	// FileTypes lets the relay pick the same few-shot examples a local
+	// client would; see Options.FileTypes.
+	FileTypes []string `json:"file_types,omitempty"`
+	// Naming is the filename convention from Options.Naming, when one is set.
+	Naming string `json:"naming,omitempty"`
+	// Topics are the topics from Options.Topics the model may pick from.
+	Topics []string `json:"topics,omitempty"`
+	// Language and KeepLanguage are the content language from Options
+	// and whether to name in it rather than in English.
+	Language     string `json:"language,omitempty"`
+	KeepLanguage bool   `json:"keep_language,omitempty"`
 }
 
 type filenameResponse struct {
-	Filename string `json:"filename"`
-	Error    string `json:"error,omitempty"`
+	Filename   string      `json:"filename"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+	Usage      *Usage      `json:"usage,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// Status and RetryAfter classify Error: the HTTP status the failure
+	// would have had on its own and the seconds to wait before retrying.
+	Status     int `json:"status,omitempty"`
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// resultError is the error the relay reported in resp, typed when the relay
+// gave its status so per-item rate limits and timeouts are retried.
+func resultError(resp filenameResponse) error {
+	err := fmt.Errorf("server error: %s", resp.Error)
+	kind := kindForStatus(resp.Status)
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, RetryAfter: time.Duration(resp.RetryAfter) * time.Second, Err: err}
+}
+
+type batchItem struct {
+	Content      string   `json:"content"`
+	EvidenceOnly bool     `json:"evidence_only,omitempty"`
+	Candidates   int      `json:"candidates,omitempty"`
+	FileTypes    []string `json:"file_types,omitempty"`
+	Naming       string   `json:"naming,omitempty"`
+	Topics       []string `json:"topics,omitempty"`
+	Language     string   `json:"language,omitempty"`
+	KeepLanguage bool     `json:"keep_language,omitempty"`
+}
+
+type batchRequest struct {
+	Model string      `json:"model"`
+	Items []batchItem `json:"items"`
+}
+
+type batchResponse struct {
+	Results []filenameResponse `json:"results"`
+	Error   string             `json:"error,omitempty"`
+}
+
+type pendingCall struct {
+	item     batchItem
+	deadline time.Time
+	done     chan batchResult
+}
+
+type batchResult struct {
+	resp filenameResponse
+	err  error
 }
 
 func NewHTTPClient(baseURL, model string) *HTTPClient {
@@ -32,43 +113,239 @@ func NewHTTPClient(baseURL, model string) *HTTPClient {
 	}
 }
 
-func (c *HTTPClient) SuggestFilename(content string) (string, error) {
+// WithAPIKey authenticates requests to a relay server that requires keys.
+func (c *HTTPClient) WithAPIKey(key string) *HTTPClient {
+	c.apiKey = key
+	return c
+}
+
+// WithBatching enables batching of up to size concurrent calls. Sizes below 2
+// leave the client sending one request per call.
+func (c *HTTPClient) WithBatching(size int, wait time.Duration) *HTTPClient {
+	if size > 1 {
+		c.batchSize = size
+		c.batchWait = wait
+	}
+	return c
+}
+
+func (c *HTTPClient) SuggestFilename(ctx context.Context, content string, opts Options) (Result, error) {
 	req := filenameRequest{
 		Content: content,
 		Model:   c.model,
 	}
-	return c.suggest(req)
+	return c.suggest(ctx, req, opts)
+}
+
+func (c *HTTPClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	req := filenameRequest{
+		Content:      evidence,
+		Model:        c.model,
+		EvidenceOnly: true,
+	}
+	return c.suggest(ctx, req, opts)
+}
+
+func (c *HTTPClient) SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error) {
+	req := filenameRequest{
+		Content:    content,
+		Model:      c.model,
+		Candidates: clampCandidates(k),
+	}
+	return c.suggest(ctx, req, opts)
 }
 
-func (c *HTTPClient) SuggestFilenameFromEvidence(evidence string) (string, error) {
+// SuggestFilenameFromImage sends image with the request; the relay's model
+// must be vision-capable.
+func (c *HTTPClient) SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error) {
 	req := filenameRequest{
 		Content:      evidence,
 		Model:        c.model,
 		EvidenceOnly: true,
+		Candidates:   clampCandidates(k),
+		Image:        &image,
+	}
+	return c.suggest(ctx, req, opts)
+}
+
+// suggest returns the token usage the server reports, whether or not the
+// call was batched. Image calls are never batched so one large request
+// cannot hold up many small ones.
+func (c *HTTPClient) suggest(ctx context.Context, req filenameRequest, opts Options) (Result, error) {
+	var (
+		resp filenameResponse
+		err  error
+	)
+	req.FileTypes = opts.fileTypes()
+	if rule := opts.naming(); rule != DefaultNaming {
+		req.Naming = rule
 	}
-	return c.suggest(req)
+	req.Topics = opts.Topics
+	req.Language, req.KeepLanguage = opts.Language, opts.KeepLanguage
+	if c.batchSize > 1 && req.Image == nil {
+		resp, err = c.suggestBatched(ctx, batchItem{Content: req.Content, EvidenceOnly: req.EvidenceOnly, Candidates: req.Candidates, FileTypes: req.FileTypes, Naming: req.Naming, Topics: req.Topics, Language: req.Language, KeepLanguage: req.KeepLanguage})
+	} else {
+		resp, err = c.post(ctx, req)
+	}
+	var usage Usage
+	if resp.Usage != nil {
+		usage = *resp.Usage
+	}
+	if req.Candidates == 0 {
+		return Result{Filename: resp.Filename, Usage: usage}, err
+	}
+	if err == nil && len(resp.Candidates) == 0 {
+		err = badResponse("server returned no candidates")
+	}
+	return candidatesResult(resp.Candidates, usage), err
 }
 
-func (c *HTTPClient) suggest(req filenameRequest) (string, error) {
+func (c *HTTPClient) post(ctx context.Context, req filenameRequest) (filenameResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return "", err
+		return filenameResponse{}, err
 	}
 
-	resp, err := c.client.Post(c.baseURL+"/suggest-filename", "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/suggest-filename", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", err
+		return filenameResponse{}, err
+	}
+	c.setHeaders(httpReq)
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return filenameResponse{}, classify(err)
 	}
 	defer resp.Body.Close()
 
 	var response filenameResponse
+	if resp.StatusCode != http.StatusOK {
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return filenameResponse{}, statusError(resp, response.Error)
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
+		return filenameResponse{}, badResponse("decode server response: %w", err)
 	}
 
 	if response.Error != "" {
-		return "", fmt.Errorf("server error: %s", response.Error)
+		return filenameResponse{Usage: response.Usage}, resultError(response)
+	}
+
+	return response, nil
+}
+
+func (c *HTTPClient) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+}
+
+func (c *HTTPClient) suggestBatched(ctx context.Context, item batchItem) (filenameResponse, error) {
+	call := &pendingCall{item: item, done: make(chan batchResult, 1)}
+	call.deadline, _ = ctx.Deadline()
+
+	c.batchMu.Lock()
+	c.pending = append(c.pending, call)
+	var ready []*pendingCall
+	switch {
+	case len(c.pending) >= c.batchSize:
+		ready = c.takePendingLocked()
+	case len(c.pending) == 1:
+		c.timer = time.AfterFunc(c.batchWait, c.flushPending)
 	}
+	c.batchMu.Unlock()
+	if ready != nil {
+		go c.sendBatch(ready)
+	}
+
+	select {
+	case result := <-call.done:
+		return result.resp, result.err
+	case <-ctx.Done():
+		return filenameResponse{}, ctx.Err()
+	}
+}
+
+func (c *HTTPClient) takePendingLocked() []*pendingCall {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	ready := c.pending
+	c.pending = nil
+	return ready
+}
+
+func (c *HTTPClient) flushPending() {
+	c.batchMu.Lock()
+	ready := c.takePendingLocked()
+	c.batchMu.Unlock()
+	if len(ready) > 0 {
+		c.sendBatch(ready)
+	}
+}
 
-	return response.Filename, nil
+// sendBatch is not tied to any one caller's context; callers that give up
+// stop waiting but the rest of the batch still completes. It runs until the
+// latest caller deadline, such as a retry attempt timeout, or for
+// maxBatchTime when a caller has none.
+func (c *HTTPClient) sendBatch(calls []*pendingCall) {
+	items := make([]batchItem, len(calls))
+	var deadline time.Time
+	for i, call := range calls {
+		items[i] = call.item
+		if call.deadline.IsZero() {
+			call.deadline = time.Now().Add(maxBatchTime)
+		}
+		if call.deadline.After(deadline) {
+			deadline = call.deadline
+		}
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	results, err := c.postBatch(ctx, items)
+	for i, call := range calls {
+		switch {
+		case err != nil:
+			call.done <- batchResult{err: err}
+		case results[i].Error != "":
+			call.done <- batchResult{resp: filenameResponse{Usage: results[i].Usage}, err: resultError(results[i])}
+		default:
+			call.done <- batchResult{resp: results[i]}
+		}
+	}
+}
+
+func (c *HTTPClient) postBatch(ctx context.Context, items []batchItem) ([]filenameResponse, error) {
+	jsonData, err := json.Marshal(batchRequest{Model: c.model, Items: items})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/batch", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(httpReq)
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, classify(err)
+	}
+	defer resp.Body.Close()
+
+	var response batchResponse
+	if resp.StatusCode != http.StatusOK {
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return nil, statusError(resp, response.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, badResponse("decode batch response: %w", err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("server error: %s", response.Error)
+	}
+	if len(response.Results) != len(items) {
+		return nil, badResponse("server returned %d results for %d batch items", len(response.Results), len(items))
+	}
+	return response.Results, nil
 }
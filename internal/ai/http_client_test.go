@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientBatchesConcurrentCalls(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches []int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/batch" {
+			t.Errorf("path = %s, want /v1/batch", r.URL.Path)
+		}
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		batches = append(batches, len(req.Items))
+		mu.Unlock()
+
+		resp := batchResponse{}
+		for _, item := range req.Items {
+			resp.Results = append(resp.Results, filenameResponse{Filename: "name-for-" + item.Content})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, "gpt-4o").WithBatching(3, time.Hour)
+	var wg sync.WaitGroup
+	got := make([]string, 3)
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := client.SuggestFilenameFromEvidence(context.Background(), fmt.Sprint(i), Options{})
+			if err != nil {
+				t.Error(err)
+			}
+			got[i] = result.Filename
+		}()
+	}
+	wg.Wait()
+
+	if len(batches) != 1 || batches[0] != 3 {
+		t.Fatalf("batches = %v, want one batch of 3", batches)
+	}
+	for i, name := range got {
+		if name != fmt.Sprintf("name-for-%d", i) {
+			t.Fatalf("result %d = %q, results must map back to their caller", i, name)
+		}
+	}
+}
+
+func TestHTTPClientFlushesPartialBatchAfterWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(batchResponse{Results: []filenameResponse{{Error: "model overloaded"}}})
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, "gpt-4o").WithBatching(8, 10*time.Millisecond)
+	_, err := client.SuggestFilename(context.Background(), "lonely call", Options{})
+	if err == nil || err.Error() != "server error: model overloaded" {
+		t.Fatalf("err = %v, want per-item server error", err)
+	}
+}
+
+func TestHTTPClientClassifiesBatchItemErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(batchResponse{Results: []filenameResponse{{Error: "rate limited", Status: http.StatusTooManyRequests, RetryAfter: 2}}})
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, "gpt-4o").WithBatching(8, time.Millisecond)
+	_, err := client.SuggestFilename(context.Background(), "busy call", Options{})
+	if !errors.Is(err, ErrRateLimited) || !Retryable(err) || RetryAfter(err) != 2*time.Second {
+		t.Fatalf("err = %v, want a retryable rate limit after 2s", err)
+	}
+}
+
+func TestHTTPClientBoundsAHangingBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server notices the client leave only once the body is read.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	old := maxBatchTime
+	maxBatchTime = 20 * time.Millisecond
+	t.Cleanup(func() { maxBatchTime = old })
+
+	client := NewHTTPClient(server.URL, "gpt-4o").WithBatching(8, time.Millisecond)
+	_, err := client.SuggestFilename(context.Background(), "stuck call", Options{})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+}
+
+func TestHTTPClientRequestsCandidates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req filenameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req.Candidates != 2 {
+			t.Errorf("candidates = %d, want 2", req.Candidates)
+		}
+		if len(req.FileTypes) != 2 || req.FileTypes[0] != "csv" {
+			t.Errorf("file types = %q, want the caller's", req.FileTypes)
+		}
+		if req.Naming != "camelCase words with no separators" {
+			t.Errorf("naming = %q, want the caller's", req.Naming)
+		}
+		json.NewEncoder(w).Encode(filenameResponse{
+			Filename:   "first",
+			Candidates: []Candidate{{Filename: "first", Confidence: 0.7}, {Filename: "second", Confidence: 0.2}},
+		})
+	}))
+	defer server.Close()
+
+	opts := Options{FileTypes: []string{".csv", "csv"}, Naming: "camelCase words with no separators"}
+	got, err := NewHTTPClient(server.URL, "gpt-4o").SuggestFilenameCandidates(context.Background(), "evidence", 2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Candidates) != 2 || got.Candidates[1].Filename != "second" || got.Candidates[0].Confidence != 0.7 || got.Filename != "first" {
+		t.Fatalf("result = %+v", got)
+	}
+}
+
+func TestHTTPClientReturnsServerUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(filenameResponse{Filename: "named", Usage: &Usage{PromptTokens: 120, CompletionTokens: 8}})
+	}))
+	defer server.Close()
+
+	got, err := NewHTTPClient(server.URL, "gpt-4o").SuggestFilename(context.Background(), "content", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Usage != (Usage{PromptTokens: 120, CompletionTokens: 8}) {
+		t.Fatalf("usage = %+v", got.Usage)
+	}
+}
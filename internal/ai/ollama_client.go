@@ -2,57 +2,151 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
-	"os"
-	"time"
 )
 
 type ollamaReq struct {
 	Model  string `json:"model"`
 	Prompt string `json:"prompt"`
 	Stream bool   `json:"stream"`
+	Format string `json:"format,omitempty"`
+	// Images are base64 pictures for multimodal models such as llava.
+	Images []string `json:"images,omitempty"`
+
+	Options map[string]any `json:"options,omitempty"`
 }
 type ollamaResp struct {
-	Response string `json:"response"`
+	Response        string `json:"response"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
 }
 
 type OllamaClient struct {
-	model string
-	url   string
+	model   string
+	url     string
+	gen     Generation
+	prompts *Prompts
 }
 
-func NewOllamaClient(model string) *OllamaClient {
-	// Get OLLAMA_HOST from environment, fallback to localhost for local development
-	url := os.Getenv("OLLAMA_HOST")
+// DefaultOllamaURL is used when neither the backend config nor OLLAMA_HOST
+// names a server.
+const DefaultOllamaURL = "http://localhost:11434/api/generate"
+
+// NewOllamaClientWithURL targets the /api/generate endpoint at url, or the
+// local default when url is empty.
+func NewOllamaClientWithURL(url, model string) *OllamaClient {
 	if url == "" {
-		url = "http://localhost:11434/api/generate"
+		url = DefaultOllamaURL
+	}
+	return &OllamaClient{model: model, url: url, prompts: DefaultPrompts()}
+}
+
+// WithGeneration sets Ollama's temperature and num_predict options.
+func (o *OllamaClient) WithGeneration(gen Generation) *OllamaClient {
+	o.gen = gen
+	return o
+}
+
+// WithPrompts replaces the built-in prompt templates.
+func (o *OllamaClient) WithPrompts(prompts *Prompts) *OllamaClient {
+	o.prompts = prompts
+	return o
+}
+
+func (o *OllamaClient) SuggestFilename(ctx context.Context, content string, opts Options) (Result, error) {
+	prompt, err := o.prompts.render(opts, "filename.tmpl", content, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	return o.generate(ctx, prompt)
+}
+
+func (o *OllamaClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	prompt, err := o.prompts.render(opts, "evidence.tmpl", evidence, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	return o.generate(ctx, prompt)
+}
+
+func (o *OllamaClient) SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error) {
+	k = clampCandidates(k)
+	prompt, err := o.prompts.render(opts, "candidates.tmpl", content, k)
+	if err != nil {
+		return Result{}, err
+	}
+	raw, usage, err := o.send(ctx, ollamaReq{Model: o.model, Prompt: prompt, Format: "json"})
+	if err != nil {
+		return Result{}, err
 	}
-	return &OllamaClient{model: model, url: url}
+	candidates, err := parseCandidates(raw, k)
+	return candidatesResult(candidates, usage), err
 }
 
-func (o *OllamaClient) SuggestFilename(content string) (string, error) {
-	prompt := buildPrompt(content)
-	return o.generate(prompt)
+func (o *OllamaClient) SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error) {
+	k = clampCandidates(k)
+	prompt, err := o.prompts.render(opts, "image.tmpl", evidence, k)
+	if err != nil {
+		return Result{}, err
+	}
+	raw, usage, err := o.send(ctx, ollamaReq{
+		Model:  o.model,
+		Prompt: prompt,
+		Format: "json",
+		Images: []string{image.base64()},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	candidates, err := parseCandidates(raw, k)
+	return candidatesResult(candidates, usage), err
 }
 
-func (o *OllamaClient) SuggestFilenameFromEvidence(evidence string) (string, error) {
-	return o.generate(buildEvidencePrompt(evidence))
+func (o *OllamaClient) generate(ctx context.Context, prompt string) (Result, error) {
+	raw, usage, err := o.send(ctx, ollamaReq{Model: o.model, Prompt: prompt})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Filename: raw, Usage: usage}, nil
 }
 
-func (o *OllamaClient) generate(prompt string) (string, error) {
-	body, _ := json.Marshal(ollamaReq{Model: o.model, Prompt: prompt})
+func (o *OllamaClient) send(ctx context.Context, in ollamaReq) (string, Usage, error) {
+	if o.gen.Temperature != nil || o.gen.MaxTokens > 0 {
+		in.Options = map[string]any{}
+		if o.gen.Temperature != nil {
+			in.Options["temperature"] = *o.gen.Temperature
+		}
+		if o.gen.MaxTokens > 0 {
+			in.Options["num_predict"] = o.gen.MaxTokens
+		}
+	}
+	body, _ := json.Marshal(in)
 
-	cl := http.Client{Timeout: 300 * time.Second} // Increased to 5 minutes for model loading
-	resp, err := cl.Post(o.url, "application/json", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewBuffer(body))
 	if err != nil {
-		return "", err
+		return "", Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// No client timeout: model loading can be slow, so callers bound each
+	// attempt through ctx (see RetryPolicy.AttemptTimeout).
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", Usage{}, classify(err)
 	}
 	defer resp.Body.Close()
 
 	var out ollamaResp
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return "", Usage{}, statusError(resp, body.Error)
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return "", err
+		return "", Usage{}, badResponse("decode ollama response: %w", err)
 	}
-	return out.Response, nil
+	return out.Response, Usage{PromptTokens: out.PromptEvalCount, CompletionTokens: out.EvalCount}, nil
 }
@@ -3,15 +3,18 @@ package ai
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"io"
+	"net/http"
 	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
 type OpenAIClient struct {
-	cl    *openai.Client
-	model string
+	cl      *openai.Client
+	model   string
+	gen     Generation
+	prompts *Prompts
 }
 
 type reasoning struct {
@@ -21,46 +24,115 @@ type reasoning struct {
 
 func NewOpenAIClient(key, model string) *OpenAIClient {
 	return &OpenAIClient{
-		cl:    openai.NewClient(key),
-		model: model,
+		cl:      openai.NewClientWithConfig(openAIConfig(key)),
+		model:   model,
+		prompts: DefaultPrompts(),
 	}
 }
 
-func (o *OpenAIClient) SuggestFilename(content string) (string, error) {
-	ctx := context.Background()
-	reasonPrompt := fmt.Sprintf(`Identify the main subject of this text in ≤5 words.
-If a clear year (e.g., 1959, 2022) appears, include it.
-Return JSON exactly like {"topic":"...", "year":""} with empty year if none. Do not include any other text or markdown.
+// NewOpenAICompatibleClient talks to any server implementing the OpenAI chat
+// completions API, such as vLLM or the llama.cpp server. baseURL includes the
+// version prefix, e.g. http://gpu-box:8000/v1.
+func NewOpenAICompatibleClient(baseURL, key, model string) *OpenAIClient {
+	cfg := openAIConfig(key)
+	cfg.BaseURL = baseURL
+	return &OpenAIClient{
+		cl:      openai.NewClientWithConfig(cfg),
+		model:   model,
+		prompts: DefaultPrompts(),
+	}
+}
+
+// openAIConfig is go-openai's default config with a client that keeps the
+// Retry-After header of rate-limited responses.
+func openAIConfig(key string) openai.ClientConfig {
+	cfg := openai.DefaultConfig(key)
+	cfg.HTTPClient = retryAfterDoer{next: &http.Client{}}
+	return cfg
+}
 
-TEXT:
-"""
-%s
-"""`, content)
+// retryAfterDoer keeps the Retry-After header go-openai drops when it turns
+// an error response into an *openai.APIError. A response that carries one
+// becomes an *Error around the same APIError, or a RequestError when the
+// body is not an OpenAI error.
+type retryAfterDoer struct {
+	next openai.HTTPDoer
+}
+
+func (d retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.next.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	kind := kindForStatus(resp.StatusCode)
+	wait := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if kind == nil || wait == 0 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
 
-	step1, err := o.cl.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr error = &openai.RequestError{HTTPStatus: resp.Status, HTTPStatusCode: resp.StatusCode, Body: body}
+	var errRes openai.ErrorResponse
+	if json.Unmarshal(body, &errRes) == nil && errRes.Error != nil {
+		errRes.Error.HTTPStatus, errRes.Error.HTTPStatusCode = resp.Status, resp.StatusCode
+		apiErr = errRes.Error
+	}
+	return nil, &Error{Kind: kind, RetryAfter: wait, Err: apiErr}
+}
+
+// WithGeneration overrides temperature and max tokens for every call.
+func (o *OpenAIClient) WithGeneration(gen Generation) *OpenAIClient {
+	o.gen = gen
+	return o
+}
+
+// WithPrompts replaces the built-in prompt templates.
+func (o *OpenAIClient) WithPrompts(prompts *Prompts) *OpenAIClient {
+	o.prompts = prompts
+	return o
+}
+
+// SuggestFilename asks for the topic first and then turns it into a
+// filename, both with the client's model.
+func (o *OpenAIClient) SuggestFilename(ctx context.Context, content string, opts Options) (Result, error) {
+	reasonPrompt, err := o.prompts.render(opts, "topic.tmpl", content, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	reasonSystem, err := o.prompts.renderSystem(opts, "topic.tmpl", 0)
+	if err != nil {
+		return Result{}, err
+	}
+
+	step1, err := o.cl.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model:       o.model,
-		MaxTokens:   32,
-		Temperature: 0.2,
+		MaxTokens:   o.gen.maxTokens(32),
+		Temperature: o.gen.temperature(0.2),
 		Messages: []openai.ChatCompletionMessage{
-			{Role: "system", Content: "You are a structured data extractor."},
+			{Role: "system", Content: reasonSystem},
 			{Role: "user", Content: reasonPrompt},
 		},
 	})
 	if err != nil {
-		return "", err
+		return Result{}, classify(err)
 	}
+	usage := completionUsage(step1)
 
 	var r reasoning
 	// Sanitize the response to extract JSON only - had problem with json being enclosed in ```json markdown style`
-	raw := step1.Choices[0].Message.Content
+	raw, err := firstChoice(step1)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
 	start := strings.Index(raw, "{")
 	end := strings.LastIndex(raw, "}")
 	if start == -1 || end == -1 || end < start {
-		return "", fmt.Errorf("could not find JSON object in response: %q", raw)
+		return Result{Usage: usage}, badResponse("could not find JSON object in response: %q", raw)
 	}
 	jsonStr := raw[start : end+1]
 	if err := json.Unmarshal([]byte(jsonStr), &r); err != nil {
-		return "", fmt.Errorf("parse step1 JSON: %w (raw=%s)", err, jsonStr)
+		return Result{Usage: usage}, badResponse("parse step1 JSON: %w (raw=%s)", err, jsonStr)
 	}
 
 	builder := strings.TrimSpace(r.Topic)
@@ -68,44 +140,145 @@ TEXT:
 		builder = builder + " " + r.Year
 	}
 
-	formatPrompt := fmt.Sprintf(`Create a single filename (5-10 words) from: %q
-Format: lowercase, words separated by dashes, no extension, no generic words like "document" or "file".
-Respond with the filename only.`, builder)
+	formatPrompt, err := o.prompts.render(opts, "title.tmpl", builder, 0)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	formatSystem, err := o.prompts.renderSystem(opts, "title.tmpl", 0)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
 
 	step2, err := o.cl.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:       openai.GPT3Dot5Turbo0125,
-		MaxTokens:   20,
-		Temperature: 0.3,
+		Model:       o.model,
+		MaxTokens:   o.gen.maxTokens(20),
+		Temperature: o.gen.temperature(0.3),
 		Messages: []openai.ChatCompletionMessage{
-			{Role: "system", Content: "You are a file‑naming assistant."},
+			{Role: "system", Content: formatSystem},
 			{Role: "user", Content: formatPrompt},
 		},
 	})
 	if err != nil {
-		return "", err
+		return Result{Usage: usage}, classify(err)
+	}
+	usage = usage.Add(completionUsage(step2))
+	answer, err := firstChoice(step2)
+	if err != nil {
+		return Result{Usage: usage}, err
 	}
 	// return first line trimmed – post‑processing will sanitize
-	filename := strings.SplitN(step2.Choices[0].Message.Content, "\n", 2)[0]
+	filename := strings.SplitN(answer, "\n", 2)[0]
 
-	return strings.TrimSpace(filename), nil
+	return Result{Filename: strings.TrimSpace(filename), Usage: usage}, nil
 }
 
-func (o *OpenAIClient) SuggestFilenameFromEvidence(evidence string) (string, error) {
-	ctx := context.Background()
+func (o *OpenAIClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	prompt, err := o.prompts.render(opts, "evidence.tmpl", evidence, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := o.prompts.renderSystem(opts, "evidence.tmpl", 0)
+	if err != nil {
+		return Result{}, err
+	}
+	resp, err := o.cl.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       o.model,
+		MaxTokens:   o.gen.maxTokens(32),
+		Temperature: o.gen.temperature(0.2),
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return Result{}, classify(err)
+	}
+	usage := completionUsage(resp)
+
+	content, err := firstChoice(resp)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	filename := strings.SplitN(content, "\n", 2)[0]
+	return Result{Filename: strings.TrimSpace(filename), Usage: usage}, nil
+}
+
+func (o *OpenAIClient) SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error) {
+	k = clampCandidates(k)
+	prompt, err := o.prompts.render(opts, "candidates.tmpl", content, k)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := o.prompts.renderSystem(opts, "candidates.tmpl", k)
+	if err != nil {
+		return Result{}, err
+	}
+	resp, err := o.cl.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       o.model,
+		MaxTokens:   o.gen.maxTokens(candidatesMaxTokens(k)),
+		Temperature: o.gen.temperature(0.4),
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return Result{}, classify(err)
+	}
+	usage := completionUsage(resp)
 
+	answer, err := firstChoice(resp)
+	if err != nil {
+		return Result{Usage: usage}, err
+	}
+	candidates, err := parseCandidates(answer, k)
+	return candidatesResult(candidates, usage), err
+}
+
+func (o *OpenAIClient) SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error) {
+	k = clampCandidates(k)
+	prompt, err := o.prompts.render(opts, "image.tmpl", evidence, k)
+	if err != nil {
+		return Result{}, err
+	}
+	system, err := o.prompts.renderSystem(opts, "image.tmpl", k)
+	if err != nil {
+		return Result{}, err
+	}
 	resp, err := o.cl.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model:       o.model,
-		MaxTokens:   32,
-		Temperature: 0.2,
+		MaxTokens:   o.gen.maxTokens(candidatesMaxTokens(k)),
+		Temperature: o.gen.temperature(0.4),
 		Messages: []openai.ChatCompletionMessage{
-			{Role: "system", Content: "You create concise filenames from recovered-file metadata. Respond with one filename only."},
-			{Role: "user", Content: buildEvidencePrompt(evidence)},
+			{Role: "system", Content: system},
+			{Role: "user", MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: prompt},
+				// Low detail is a fixed, small token cost and enough to
+				// recognize what a picture shows.
+				{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: image.dataURL(), Detail: openai.ImageURLDetailLow}},
+			}},
 		},
 	})
 	if err != nil {
-		return "", err
+		return Result{}, classify(err)
+	}
+	usage := completionUsage(resp)
+
+	answer, err := firstChoice(resp)
+	if err != nil {
+		return Result{Usage: usage}, err
 	}
+	candidates, err := parseCandidates(answer, k)
+	return candidatesResult(candidates, usage), err
+}
+
+func firstChoice(resp openai.ChatCompletionResponse) (string, error) {
+	if len(resp.Choices) == 0 {
+		return "", badResponse("completion returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
 
-	filename := strings.SplitN(resp.Choices[0].Message.Content, "\n", 2)[0]
-	return strings.TrimSpace(filename), nil
+func completionUsage(resp openai.ChatCompletionResponse) Usage {
+	return Usage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens}
 }
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestOpenAIClientUsesItsModelForBothSteps(t *testing.T) {
+	type chatRequest struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	var requests []chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		answer := `{"topic":"modal jazz","year":"1959"}`
+		if len(requests) == 2 {
+			answer = "modal-jazz-kind-of-blue-1959"
+		}
+		body, _ := json.Marshal(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": answer}}},
+		})
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient(server.URL+"/v1", "key", "llama-3-8b")
+	result, err := client.SuggestFilename(context.Background(), "miles davis kind of blue sessions, 1959", Options{})
+	if err != nil || result.Filename != "modal-jazz-kind-of-blue-1959" {
+		t.Fatalf("SuggestFilename = %+v, %v", result, err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(requests))
+	}
+	for i, req := range requests {
+		if req.Model != "llama-3-8b" {
+			t.Fatalf("step %d model = %q, want the client's model", i+1, req.Model)
+		}
+	}
+	if prompt := requests[1].Messages[1].Content; !strings.Contains(prompt, `"modal jazz 1959"`) {
+		t.Fatalf("step 2 prompt = %q, want the step 1 topic", prompt)
+	}
+}
+
+func TestOpenAIClientKeepsRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached for requests","type":"requests"}}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient(server.URL+"/v1", "key", "gpt-4o")
+	_, err := client.SuggestFilenameCandidates(context.Background(), "notes", 1, Options{})
+	if !errors.Is(err, ErrRateLimited) || RetryAfter(err) != 7*time.Second {
+		t.Fatalf("err = %v, retry after %v; want a rate limit with Retry-After 7s", err, RetryAfter(err))
+	}
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "Rate limit reached for requests" {
+		t.Fatalf("err = %v, want the OpenAI error kept", err)
+	}
+}
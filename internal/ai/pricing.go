@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Price is what a model charges in US dollars per million tokens.
+type Price struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// Cost estimates what usage cost at p.
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1_000_000
+}
+
+// PriceTable maps model names to prices.
+type PriceTable map[string]Price
+
+// DefaultPrices are list prices for the hosted models the CLI defaults to or
+// the relay allows. Local Ollama models are free and not listed.
+var DefaultPrices = PriceTable{
+	"gpt-3.5-turbo":            {Input: 0.50, Output: 1.50},
+	"gpt-4":                    {Input: 30, Output: 60},
+	"gpt-4o":                   {Input: 2.50, Output: 10},
+	"gpt-4o-mini":              {Input: 0.15, Output: 0.60},
+	"claude-3-5-haiku-latest":  {Input: 0.80, Output: 4},
+	"claude-3-5-sonnet-latest": {Input: 3, Output: 15},
+}
+
+// Lookup returns the price for model, falling back to the longest listed
+// prefix so dated snapshots such as gpt-4o-2024-08-06 match gpt-4o.
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+type priceFile struct {
+	Models PriceTable `yaml:"models"`
+}
+
+// LoadPriceTable returns DefaultPrices with the models listed in the YAML file
+// at path added or replaced. An empty path returns DefaultPrices.
+//
+//	models:
+//	  gpt-4o: {input: 2.50, output: 10.00}
+func LoadPriceTable(path string) (PriceTable, error) {
+	table := maps.Clone(DefaultPrices)
+	if path == "" {
+		return table, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file priceFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse price table %s: %w", path, err)
+	}
+	for model, price := range file.Models {
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("price table %s: negative price for %s", path, model)
+		}
+		table[model] = price
+	}
+	return table, nil
+}
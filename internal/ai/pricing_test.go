@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPriceTableLookupMatchesDatedSnapshots(t *testing.T) {
+	price, ok := DefaultPrices.Lookup("gpt-4o-2024-08-06")
+	if !ok || price != DefaultPrices["gpt-4o"] {
+		t.Fatalf("Lookup(gpt-4o-2024-08-06) = %+v, %v", price, ok)
+	}
+	if price, _ := DefaultPrices.Lookup("gpt-4o-mini"); price != DefaultPrices["gpt-4o-mini"] {
+		t.Fatalf("gpt-4o-mini matched %+v, want its own price", price)
+	}
+	if _, ok := DefaultPrices.Lookup("mistral"); ok {
+		t.Fatal("local model should have no price")
+	}
+}
+
+func TestPriceCost(t *testing.T) {
+	cost := Price{Input: 2.5, Output: 10}.Cost(Usage{PromptTokens: 1000, CompletionTokens: 100})
+	if math.Abs(cost-0.0035) > 1e-12 {
+		t.Fatalf("cost = %v, want 0.0035", cost)
+	}
+}
+
+func TestLoadPriceTableOverridesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	data := "models:\n  gpt-4o: {input: 1, output: 2}\n  qwen2.5-7b-instruct: {input: 0.1, output: 0.1}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := LoadPriceTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table["gpt-4o"] != (Price{Input: 1, Output: 2}) || table["qwen2.5-7b-instruct"].Input != 0.1 {
+		t.Fatalf("table = %+v", table)
+	}
+	if table["gpt-4"] != DefaultPrices["gpt-4"] {
+		t.Fatal("defaults were not kept")
+	}
+	if DefaultPrices["gpt-4o"].Input != 2.5 {
+		t.Fatal("LoadPriceTable modified DefaultPrices")
+	}
+
+	if err := os.WriteFile(path, []byte("models:\n  gpt-4o: {input: -1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPriceTable(path); err == nil {
+		t.Fatal("expected negative price to be rejected")
+	}
+}
@@ -1,98 +1,314 @@
 package ai
 
-import "fmt"
-
-func buildEvidencePrompt(evidence string) string {
-	return fmt.Sprintf(`You are a file recovery assistant.
-A file was recovered without trustworthy filesystem metadata. You are receiving only selected internal metadata and ranked text snippets, not the full file.
-
-Evidence:
-"""
-%s
-"""
-
-Generate one descriptive filename from the evidence.
-Rules:
-- lowercase words separated by dashes
-- no file extension
-- 5-8 meaningful words when possible
-- avoid generic words: document, file, draft, text, note, notes, recovered, unknown
-- use dates, IDs, project names, titles, or subjects when they are clearly meaningful
-- respond with the filename only`, evidence)
-}
-
-func buildPrompt(content string) string {
-	return fmt.Sprintf(`
-	%s
-	You are a file recovery assistant.
-A document was recovered from a damaged hard drive, but its filename was lost.
-
-Here is the document:
-"""
-%s
-"""
-
-Generate a single, meaningful filename that summarizes this file.
-• 5-10 words
-• lowercase letters, numbers, dashes or underscores
-• no file extension
-Respond with the filename only.
-
-Respond **with one line only** - a single filename and nothing else. Do **not** return multiple suggestions or bullet points.
-Return one filename **without generic words such as _document, file, draft, text, note, notes_.
-Return one filename only, avoid repeating the same word, 5-8 words max…
-`, fewShot, content)
-}
-
-const fewShot = `
-Example 1:
-"""
-prepare slides for jazz history class; focus on modal jazz; miles davis kind of blue 1959 sessions
-"""
-filename: miles-davis-kind-of-blue-jazz-history
-
-Example 2:
-"""
-ome things are only actually, some potentially, some potentially and actually, what they are, viz. in one case a particular 
-reality, in another, characterized by a particular quantity, or the like. There is no movement apart from things; for change is 
-always according to the categories of being, and there is nothing common to these and in no one category. But each of the 
-categories belongs to all its subjects in either of two ways (e.g. 'this-ness'-for one kind of it is 'positive form', and the other is 
-'privation'); and as regards quality one kind is 'white' and the other 'black', and as regards quantity one kind is 'complete' and 
-the other 'incomplete', and as regards spatial movement one is 'upwards' and the other 'downwards', or one thing is 'light' and 
-another 'heavy'); so that there are as many kinds of movement and change as of being. There being a distinction in each 
-class of things between the potential and the completely real, I call the actuality of the potential as such, movement.
-"""
-filename: aristotle-movement-change-categories-being
-
-Example 3:
-"""
-Shall I compare thee to a summer's day?
-Thou art more lovely and more temperate:
-Rough winds do shake the darling buds of May,
-And summer's lease hath all too short a date;
-Sometime too hot the eye of heaven shines,
-And often is his gold complexion dimm'd;
-And every fair from fair sometime declines,
-By chance or nature's changing course untrimm'd;
-But thy eternal summer shall not fade,
-Nor lose possession of that fair thou ow'st;
-Nor shall death brag thou wander'st in his shade,
-When in eternal lines to time thou grow'st:
-   So long as men can breathe or eyes can see,
-   So long lives this, and this gives life to thee.
-"""
-filename: shakespeare-sonnet-18-summer-day
-
-Example 4:
-"""
-Hey!
-Just wanted to check in — I was thinking about you earlier today for some reason. I think it's 'cause I passed that little café we went to last spring, the one with the weird plant wall and the cinnamon rolls that were, like, offensively good? Anyway, made me laugh.
-
-How's your week going? Things here have been a bit of a blur — work's been nonstop, and I finally finished that project I was ranting about. You would've been proud of the amount of coffee it took.
-
-Also, random: I saw a dog today that looked exactly like yours, but somehow even more dramatic. It barked at a leaf and then looked genuinely offended when it moved.
-
-Anyway, no pressure to reply fast or anything — just wanted to say hey and that I hope everything's going okay on your end. Talk soon?
-"""
-filename: casual-check-in-funny-dog-story
-`
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/djblackett/bootdev-hackathon/internal/language"
+)
+
+// PromptVersion identifies the built-in prompt wording. Bump it whenever a
+// template under prompts/ changes so cached responses from the old wording
+// are not reused. Prompts.Version extends it for overridden templates.
+const PromptVersion = "5"
+
+// DefaultNaming is the filename convention prompts ask for unless the
+// caller sets Options.Naming.
+const DefaultNaming = "lowercase words separated by dashes"
+
+// DefaultExampleSet is the few-shot example set used when no set matches the
+// file type.
+const DefaultExampleSet = "default"
+
+//go:embed prompts
+var embeddedPrompts embed.FS
+
+// exampleAliases maps extensions and detected types to the example set that
+// fits them, for types without a set of their own.
+var exampleAliases = map[string]string{
+	"csv": "spreadsheet", "tsv": "spreadsheet", "xls": "spreadsheet", "xlsx": "spreadsheet", "ods": "spreadsheet",
+	"eml": "email", "msg": "email", "mbox": "email",
+	"c": "code", "cpp": "code", "cs": "code", "go": "code", "h": "code", "java": "code", "js": "code",
+	"kt": "code", "php": "code", "py": "code", "rb": "code", "rs": "code", "sh": "code", "sql": "code",
+	"swift": "code", "ts": "code", "ipynb": "code", "notebook": "code",
+	"json": "code", "toml": "code", "xml": "code", "yaml": "code", "yml": "code",
+}
+
+// Prompts renders the prompt templates sent to backends. The zero value is
+// not usable; use DefaultPrompts or LoadPrompts.
+type Prompts struct {
+	tmpl     *template.Template
+	examples map[string]string
+	version  string
+}
+
+// promptData is what every template sees. Count is the number of
+// candidates asked for, Examples the few-shot set for the file type,
+// Naming the filename convention, Language the language to name the file
+// in, if not English, and Topics the comma-separated topics the model may
+// file the file under, if any.
+type promptData struct {
+	Content  string
+	Count    int
+	Examples string
+	Naming   string
+	Language string
+	Topics   string
+}
+
+var defaultPrompts = sync.OnceValue(func() *Prompts {
+	sub, err := fs.Sub(embeddedPrompts, "prompts")
+	if err != nil {
+		panic(err)
+	}
+	p, err := loadPrompts(sub, nil, PromptVersion)
+	if err != nil {
+		panic(fmt.Sprintf("built-in prompts: %v", err))
+	}
+	return p
+})
+
+// DefaultPrompts returns the built-in prompts.
+func DefaultPrompts() *Prompts {
+	return defaultPrompts()
+}
+
+// LoadPrompts returns the built-in prompts with the templates in dir laid
+// over them. dir may hold any of the built-in *.tmpl files and an examples
+// directory of <set>.txt few-shot sets; files it leaves out keep their
+// built-in text. An empty dir returns DefaultPrompts.
+func LoadPrompts(dir string) (*Prompts, error) {
+	if dir == "" {
+		return DefaultPrompts(), nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("prompt dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("prompt dir %s is not a directory", dir)
+	}
+	sub, err := fs.Sub(embeddedPrompts, "prompts")
+	if err != nil {
+		return nil, err
+	}
+	p, err := loadPrompts(sub, os.DirFS(dir), PromptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("prompt dir %s: %w", dir, err)
+	}
+	return p, nil
+}
+
+func loadPrompts(base, overrides fs.FS, version string) (*Prompts, error) {
+	tmpl := template.New("")
+	builtin, err := templateFiles(base)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range builtin {
+		text, err := fs.ReadFile(base, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.New(name).Parse(string(text)); err != nil {
+			return nil, err
+		}
+	}
+	examples, err := readExamples(base)
+	if err != nil {
+		return nil, err
+	}
+	if overrides != nil {
+		hash := sha256.New()
+		names, err := templateFiles(overrides)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if tmpl.Lookup(name) == nil {
+				return nil, fmt.Errorf("unknown prompt template %s; want one of %s", name, strings.Join(templateNames(tmpl), ", "))
+			}
+			text, err := fs.ReadFile(overrides, name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tmpl.New(name).Parse(string(text)); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(hash, "%s\x00%s\x00", name, text)
+		}
+		sets, err := readExamples(overrides)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range slices.Sorted(maps.Keys(sets)) {
+			examples[name] = sets[name]
+			fmt.Fprintf(hash, "examples/%s\x00%s\x00", name, sets[name])
+		}
+		if len(names) > 0 || len(sets) > 0 {
+			version += "+" + hex.EncodeToString(hash.Sum(nil))[:8]
+		}
+	}
+
+	p := &Prompts{tmpl: tmpl, examples: examples, version: version}
+	// Render every template once so a broken override fails at startup
+	// rather than on the first file.
+	for _, name := range templateNames(tmpl) {
+		if _, err := p.execute(name, promptData{Content: "sample", Count: 3, Examples: examples[DefaultExampleSet], Naming: DefaultNaming, Language: "in German, the language of the content", Topics: "finance, travel"}); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func readExamples(fsys fs.FS) (map[string]string, error) {
+	names, err := fs.Glob(fsys, "examples/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	sets := map[string]string{}
+	for _, name := range names {
+		text, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		sets[strings.TrimSuffix(filepath.Base(name), ".txt")] = strings.TrimSpace(string(text))
+	}
+	return sets, nil
+}
+
+// templateFiles lists the user prompt templates in fsys and the system
+// prompts sent with them under system/, named by their paths so that
+// system/evidence.tmpl and evidence.tmpl stay apart.
+func templateFiles(fsys fs.FS) ([]string, error) {
+	names, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	system, err := fs.Glob(fsys, "system/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	return append(names, system...), nil
+}
+
+func templateNames(tmpl *template.Template) []string {
+	var names []string
+	for _, t := range tmpl.Templates() {
+		if strings.HasSuffix(t.Name(), ".tmpl") {
+			names = append(names, t.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Version identifies the wording: PromptVersion for the built-in prompts,
+// with a hash of any overrides appended.
+func (p *Prompts) Version() string {
+	return p.version
+}
+
+// ExampleSet returns the name of the few-shot set used for calls made with
+// opts: the first of opts.FileTypes that has a set of its own, then the
+// first that has one through a known alias, and otherwise
+// DefaultExampleSet.
+func (p *Prompts) ExampleSet(opts Options) string {
+	types := opts.fileTypes()
+	for _, t := range types {
+		if _, ok := p.examples[t]; ok {
+			return t
+		}
+	}
+	for _, t := range types {
+		if set, ok := exampleAliases[t]; ok {
+			if _, ok := p.examples[set]; ok {
+				return set
+			}
+		}
+	}
+	return DefaultExampleSet
+}
+
+func (p *Prompts) render(opts Options, name, content string, count int) (string, error) {
+	return p.execute(name, promptData{Content: content, Count: count, Examples: p.examples[p.ExampleSet(opts)], Naming: opts.naming(), Language: opts.languageRule(), Topics: strings.Join(opts.Topics, ", ")})
+}
+
+// renderSystem renders the system prompt sent along with the user template
+// name.
+func (p *Prompts) renderSystem(opts Options, name string, count int) (string, error) {
+	return p.render(opts, "system/"+name, "", count)
+}
+
+func (p *Prompts) execute(name string, data promptData) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("render prompt: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// EstimateCandidatesUsage approximates the tokens one SuggestFilenameCandidates
+// call for content would use, at about four characters per token, without
+// calling a backend. Completion tokens are the call's response limit, so the
+// estimate errs high.
+func (p *Prompts) EstimateCandidatesUsage(content string, k int, opts Options) Usage {
+	k = clampCandidates(k)
+	prompt, _ := p.render(opts, "candidates.tmpl", content, k)
+	return Usage{
+		PromptTokens:     (len(prompt) + 3) / 4,
+		CompletionTokens: candidatesMaxTokens(k),
+	}
+}
+
+// EstimateImageCandidatesUsage is EstimateCandidatesUsage for a
+// SuggestFilenameFromImage call, counting the thumbnail at a high guess.
+func (p *Prompts) EstimateImageCandidatesUsage(evidence string, k int, opts Options) Usage {
+	k = clampCandidates(k)
+	prompt, _ := p.render(opts, "image.tmpl", evidence, k)
+	return Usage{
+		PromptTokens:     (len(prompt)+3)/4 + imageTokenEstimate,
+		CompletionTokens: candidatesMaxTokens(k),
+	}
+}
+
+// fileTypes are opts.FileTypes in lower case without a leading dot.
+func (opts Options) fileTypes() []string {
+	var normalized []string
+	for _, t := range opts.FileTypes {
+		if t = strings.ToLower(strings.TrimPrefix(t, ".")); t != "" {
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized
+}
+
+// naming is opts.Naming, or DefaultNaming when it is empty.
+func (opts Options) naming() string {
+	if opts.Naming != "" {
+		return opts.Naming
+	}
+	return DefaultNaming
+}
+
+// languageRule is the prompt's language rule for opts, or "" for English.
+func (opts Options) languageRule() string {
+	if opts.Language == "" || opts.Language == "en" {
+		return ""
+	}
+	if opts.KeepLanguage {
+		return "in " + language.Name(opts.Language) + ", the language of the content"
+	}
+	return "in English, translated from " + language.Name(opts.Language)
+}
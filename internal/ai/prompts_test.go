@@ -1,14 +1,19 @@
 package ai
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
-func TestBuildEvidencePromptUsesEvidenceNotFullDocumentLanguage(t *testing.T) {
+func TestEvidencePromptUsesEvidenceNotFullDocumentLanguage(t *testing.T) {
 	evidence := "detected_type: pdf\ntop_samples:\n- source: pdf-first-text\n  text: Quarterly Revenue Review"
 
-	got := buildEvidencePrompt(evidence)
+	got, err := DefaultPrompts().render(Options{}, "evidence.tmpl", evidence, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !strings.Contains(got, evidence) {
 		t.Fatalf("prompt missing evidence: %q", got)
@@ -21,8 +26,11 @@ func TestBuildEvidencePromptUsesEvidenceNotFullDocumentLanguage(t *testing.T) {
 	}
 }
 
-func TestBuildEvidencePromptKeepsFilenameConstraints(t *testing.T) {
-	got := buildEvidencePrompt("detected_type: csv")
+func TestEvidencePromptKeepsFilenameConstraints(t *testing.T) {
+	got, err := DefaultPrompts().render(Options{}, "evidence.tmpl", "detected_type: csv", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	for _, want := range []string{"lowercase", "no file extension", "5-8 meaningful words"} {
 		if !strings.Contains(got, want) {
@@ -30,3 +38,215 @@ func TestBuildEvidencePromptKeepsFilenameConstraints(t *testing.T) {
 		}
 	}
 }
+
+func TestPromptsPickExamplesByFileType(t *testing.T) {
+	prompts := DefaultPrompts()
+	for _, tc := range []struct {
+		types []string
+		want  string
+	}{
+		{[]string{".csv", "csv"}, "spreadsheet"},
+		{[]string{"", "xlsx"}, "spreadsheet"},
+		{[]string{".eml", "email"}, "email"},
+		{[]string{".ipynb", "notebook"}, "code"},
+		{[]string{".go", "go"}, "code"},
+		{[]string{".pdf", "pdf"}, DefaultExampleSet},
+		{nil, DefaultExampleSet},
+	} {
+		if got := prompts.ExampleSet(Options{FileTypes: tc.types}); got != tc.want {
+			t.Errorf("ExampleSet(%q) = %q, want %q", tc.types, got, tc.want)
+		}
+	}
+
+	got, err := prompts.render(Options{FileTypes: []string{".csv", "csv"}}, "candidates.tmpl", "detected_type: csv", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "2022-checking-account-transactions-by-category") || strings.Contains(got, "miles-davis") {
+		t.Fatalf("candidates prompt should use the spreadsheet examples: %q", got)
+	}
+}
+
+func TestLoadPromptsOverridesTemplatesAndExamples(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "candidates.tmpl"), "List {{.Count}} names for: {{.Content}}\n{{.Examples}}")
+	writeFile(t, filepath.Join(dir, "examples", "code.txt"), "filename: house-style-code-example\n")
+
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(prompts.Version(), PromptVersion+"+") {
+		t.Fatalf("Version = %q, want the built-in version plus an override hash", prompts.Version())
+	}
+
+	opts := Options{FileTypes: []string{".py", "py"}}
+	got, err := prompts.render(opts, "candidates.tmpl", "def main():", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "List 4 names for: def main():\nfilename: house-style-code-example" {
+		t.Fatalf("candidates prompt = %q", got)
+	}
+	// image.tmpl includes candidates.tmpl, so it follows the override.
+	image, err := prompts.render(opts, "image.tmpl", "evidence", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(image, "List 2 names for: evidence") || !strings.Contains(image, "attached image") {
+		t.Fatalf("image prompt = %q", image)
+	}
+	// Templates the dir leaves out keep their built-in text.
+	evidence, err := prompts.render(opts, "evidence.tmpl", "evidence", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(evidence, "respond with the filename only") {
+		t.Fatalf("evidence prompt = %q", evidence)
+	}
+
+	writeFile(t, filepath.Join(dir, "examples", "code.txt"), "filename: another-code-example\n")
+	changed, err := LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Version() == prompts.Version() {
+		t.Fatalf("Version did not change with the examples: %q", changed.Version())
+	}
+}
+
+func TestLoadPromptsOverridesSystemPrompts(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "system", "evidence.tmpl"), "You name files {{.Naming}}.")
+
+	prompts, err := LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(prompts.Version(), PromptVersion+"+") {
+		t.Fatalf("Version = %q, want the built-in version plus an override hash", prompts.Version())
+	}
+	system, err := prompts.renderSystem(Options{}, "evidence.tmpl", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system != "You name files "+DefaultNaming+"." {
+		t.Fatalf("evidence system prompt = %q", system)
+	}
+	// The user template of the same name keeps its built-in text.
+	evidence, err := prompts.render(Options{}, "evidence.tmpl", "evidence", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(evidence, "respond with the filename only") {
+		t.Fatalf("evidence prompt = %q", evidence)
+	}
+}
+
+func TestLoadPromptsRejectsBadTemplates(t *testing.T) {
+	for name, text := range map[string]string{
+		"unknown.tmpl":  "{{.Content}}",
+		"evidence.tmpl": "{{.Content",
+		"title.tmpl":    "{{.Topic}}",
+	} {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, name), text)
+		if _, err := LoadPrompts(dir); err == nil {
+			t.Errorf("LoadPrompts accepted %s = %q", name, text)
+		}
+	}
+	if _, err := LoadPrompts(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadPrompts accepted a missing dir")
+	}
+}
+
+func TestNamingSetsTheConvention(t *testing.T) {
+	prompts := DefaultPrompts()
+	plain, err := prompts.render(Options{}, "candidates.tmpl", "evidence", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(plain, "- "+DefaultNaming+"\n") {
+		t.Fatalf("default prompt = %q", plain)
+	}
+
+	opts := Options{Naming: "Title Case words separated by spaces"}
+	for _, name := range []string{"candidates.tmpl", "evidence.tmpl", "filename.tmpl", "title.tmpl"} {
+		got, err := prompts.render(opts, name, "evidence", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, "Title Case words separated by spaces") || strings.Contains(got, DefaultNaming) {
+			t.Errorf("%s ignores the naming rule: %q", name, got)
+		}
+	}
+}
+
+func TestTopicsAskForATopic(t *testing.T) {
+	prompts := DefaultPrompts()
+	plain, err := prompts.render(Options{}, "candidates.tmpl", "evidence", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain, `"topic"`) {
+		t.Fatalf("prompt without topics asks for one: %q", plain)
+	}
+
+	got, err := prompts.render(Options{Topics: []string{"finance", "travel"}}, "candidates.tmpl", "evidence", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "from: finance, travel.") || !strings.Contains(got, `"topic":"..."`) {
+		t.Fatalf("prompt = %q", got)
+	}
+	candidates, err := parseCandidates(`{"candidates":[{"filename":"lisbon-trip","confidence":0.8,"topic":" travel "}]}`, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candidates[0].Topic != "travel" {
+		t.Fatalf("topic = %q", candidates[0].Topic)
+	}
+}
+
+func TestLanguageTranslatesOrKeeps(t *testing.T) {
+	prompts := DefaultPrompts()
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Language: "de"}, DefaultNaming + ", in English, translated from German"},
+		{Options{Language: "ja", KeepLanguage: true}, DefaultNaming + ", in Japanese, the language of the content"},
+		{Options{Language: "en", KeepLanguage: true}, ""},
+	} {
+		for _, name := range []string{"candidates.tmpl", "evidence.tmpl", "filename.tmpl", "title.tmpl"} {
+			got, err := prompts.render(tc.opts, name, "evidence", 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want == "" && strings.Contains(got, DefaultNaming+", in ") || !strings.Contains(got, tc.want) {
+				t.Errorf("%s missing %q: %q", name, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestEstimateCandidatesUsage(t *testing.T) {
+	short := DefaultPrompts().EstimateCandidatesUsage("x", 3, Options{})
+	long := DefaultPrompts().EstimateCandidatesUsage(strings.Repeat("x", 4000), 3, Options{})
+	if long.PromptTokens-short.PromptTokens < 999 || long.PromptTokens-short.PromptTokens > 1000 {
+		t.Fatalf("4000 extra chars added %d tokens, want about 1000", long.PromptTokens-short.PromptTokens)
+	}
+	if short.CompletionTokens != candidatesMaxTokens(3) {
+		t.Fatalf("completion tokens = %d, want the response limit", short.CompletionTokens)
+	}
+}
+
+func writeFile(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
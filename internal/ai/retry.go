@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how WithRetry repeats failed calls.
+type RetryPolicy struct {
+	// MaxAttempts counts the first call; 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// AttemptTimeout bounds each attempt; 0 leaves only the caller's context.
+	AttemptTimeout time.Duration
+}
+
+// DefaultRetryPolicy allows five minutes per attempt because a local Ollama
+// server may load the model on the first request.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	BaseDelay:      500 * time.Millisecond,
+	MaxDelay:       30 * time.Second,
+	AttemptTimeout: 5 * time.Minute,
+}
+
+type retryClient struct {
+	next   Client
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// WithRetry retries rate limits, timeouts, unavailable backends, and bad
+// responses with exponential backoff and full jitter. A Retry-After from the
+// backend is honored, unless it is longer than MaxDelay, in which case the
+// error is returned right away.
+func WithRetry(next Client, policy RetryPolicy) Client {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &retryClient{next: next, policy: policy, sleep: sleepContext}
+}
+
+func (r *retryClient) SuggestFilename(ctx context.Context, content string, opts Options) (Result, error) {
+	return r.retry(ctx, func(ctx context.Context) (Result, error) {
+		return r.next.SuggestFilename(ctx, content, opts)
+	})
+}
+
+func (r *retryClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	return r.retry(ctx, func(ctx context.Context) (Result, error) {
+		return r.next.SuggestFilenameFromEvidence(ctx, evidence, opts)
+	})
+}
+
+func (r *retryClient) SuggestFilenameCandidates(ctx context.Context, content string, k int, opts Options) (Result, error) {
+	return r.retry(ctx, func(ctx context.Context) (Result, error) {
+		return r.next.SuggestFilenameCandidates(ctx, content, k, opts)
+	})
+}
+
+func (r *retryClient) SuggestFilenameFromImage(ctx context.Context, image Image, evidence string, k int, opts Options) (Result, error) {
+	return r.retry(ctx, func(ctx context.Context) (Result, error) {
+		return r.next.SuggestFilenameFromImage(ctx, image, evidence, k, opts)
+	})
+}
+
+// retry returns the last attempt's result with Usage summed over every
+// attempt, since failed attempts may have spent tokens too.
+func (r *retryClient) retry(ctx context.Context, call func(context.Context) (Result, error)) (Result, error) {
+	var usage Usage
+	for n := 1; ; n++ {
+		result, err := attempt(ctx, r.policy.AttemptTimeout, call)
+		usage = usage.Add(result.Usage)
+		result.Usage = usage
+		if err == nil || ctx.Err() != nil || !Retryable(err) || n >= r.policy.MaxAttempts {
+			return result, err
+		}
+		wait := r.policy.backoff(n)
+		if after := RetryAfter(err); after > 0 {
+			if r.policy.MaxDelay > 0 && after > r.policy.MaxDelay {
+				return Result{Usage: usage}, err
+			}
+			wait = max(wait, after)
+		}
+		if sleepErr := r.sleep(ctx, wait); sleepErr != nil {
+			return Result{Usage: usage}, err
+		}
+	}
+}
+
+func attempt(ctx context.Context, timeout time.Duration, call func(context.Context) (Result, error)) (Result, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result, err := call(ctx)
+	return result, classify(err)
+}
+
+// backoff returns a random delay up to BaseDelay*2^(attempt-1), capped at
+// MaxDelay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || limit < p.MaxDelay); i++ {
+		limit *= 2
+	}
+	if p.MaxDelay > 0 && limit > p.MaxDelay {
+		limit = p.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit + 1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// scriptedClient returns the queued errors in order, then a filename. Every
+// call, failed or not, reports scriptedUsage.
+type scriptedClient struct {
+	errs  []error
+	calls int
+}
+
+var scriptedUsage = Usage{PromptTokens: 10, CompletionTokens: 1}
+
+func (c *scriptedClient) SuggestFilename(ctx context.Context, content string, _ Options) (Result, error) {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return Result{Usage: scriptedUsage}, err
+	}
+	return Result{Filename: "named-" + content, Usage: scriptedUsage}, nil
+}
+
+func (c *scriptedClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	return c.SuggestFilename(ctx, evidence, opts)
+}
+
+func (c *scriptedClient) SuggestFilenameCandidates(ctx context.Context, content string, _ int, opts Options) (Result, error) {
+	result, err := c.SuggestFilename(ctx, content, opts)
+	if err != nil {
+		return result, err
+	}
+	return candidatesResult([]Candidate{{Filename: result.Filename, Confidence: 0.5}}, result.Usage), nil
+}
+
+func (c *scriptedClient) SuggestFilenameFromImage(ctx context.Context, _ Image, evidence string, k int, opts Options) (Result, error) {
+	return c.SuggestFilenameCandidates(ctx, evidence, k, opts)
+}
+
+// blockingClient waits for its context to end.
+type blockingClient struct{}
+
+func (blockingClient) SuggestFilename(ctx context.Context, _ string, _ Options) (Result, error) {
+	<-ctx.Done()
+	return Result{}, ctx.Err()
+}
+
+func (c blockingClient) SuggestFilenameFromEvidence(ctx context.Context, evidence string, opts Options) (Result, error) {
+	return c.SuggestFilename(ctx, evidence, opts)
+}
+
+func (c blockingClient) SuggestFilenameCandidates(ctx context.Context, content string, _ int, opts Options) (Result, error) {
+	return c.SuggestFilename(ctx, content, opts)
+}
+
+func (c blockingClient) SuggestFilenameFromImage(ctx context.Context, _ Image, evidence string, k int, opts Options) (Result, error) {
+	return c.SuggestFilenameCandidates(ctx, evidence, k, opts)
+}
+
+func newTestRetry(next Client, policy RetryPolicy) (*retryClient, *[]time.Duration) {
+	var waits []time.Duration
+	r := WithRetry(next, policy).(*retryClient)
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return r, &waits
+}
+
+func TestRetryBacksOffAndHonorsRetryAfter(t *testing.T) {
+	next := &scriptedClient{errs: []error{
+		&Error{Kind: ErrRateLimited, RetryAfter: 3 * time.Second},
+		&Error{Kind: ErrTimeout},
+	}}
+	r, waits := newTestRetry(next, RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 10 * time.Second})
+
+	result, err := r.SuggestFilename(context.Background(), "a", Options{})
+	if err != nil || result.Filename != "named-a" {
+		t.Fatalf("SuggestFilename = %+v, %v", result, err)
+	}
+	if next.calls != 3 {
+		t.Fatalf("calls = %d, want 3", next.calls)
+	}
+	if want := (Usage{PromptTokens: 30, CompletionTokens: 3}); result.Usage != want {
+		t.Fatalf("usage = %+v, want %+v summed over every attempt", result.Usage, want)
+	}
+	if (*waits)[0] != 3*time.Second {
+		t.Fatalf("first wait = %v, want Retry-After of 3s", (*waits)[0])
+	}
+	if (*waits)[1] > 200*time.Millisecond {
+		t.Fatalf("second wait = %v, want at most 200ms of jittered backoff", (*waits)[1])
+	}
+}
+
+func TestRetryStopsOnPermanentErrors(t *testing.T) {
+	tests := map[string]struct {
+		err   error
+		calls int
+	}{
+		"auth":                 {err: &Error{Kind: ErrAuth}, calls: 1},
+		"unclassified":         {err: errors.New("invalid request"), calls: 1},
+		"retry after too long": {err: &Error{Kind: ErrRateLimited, RetryAfter: time.Hour}, calls: 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			next := &scriptedClient{errs: []error{tt.err, tt.err, tt.err}}
+			r, _ := newTestRetry(next, RetryPolicy{MaxAttempts: 3, MaxDelay: time.Minute})
+			if _, err := r.SuggestFilename(context.Background(), "a", Options{}); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if next.calls != tt.calls {
+				t.Fatalf("calls = %d, want %d", next.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	next := &scriptedClient{errs: []error{
+		&Error{Kind: ErrUnavailable}, &Error{Kind: ErrUnavailable}, &Error{Kind: ErrUnavailable},
+	}}
+	r, waits := newTestRetry(next, RetryPolicy{MaxAttempts: 2})
+	result, err := r.SuggestFilenameFromEvidence(context.Background(), "a", Options{})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if result.Usage.PromptTokens != 20 {
+		t.Fatalf("usage = %+v, want the failed attempts' tokens", result.Usage)
+	}
+	if next.calls != 2 || len(*waits) != 1 {
+		t.Fatalf("calls = %d waits = %d, want 2 and 1", next.calls, len(*waits))
+	}
+}
+
+func TestRetryCandidates(t *testing.T) {
+	next := &scriptedClient{errs: []error{&Error{Kind: ErrBadResponse}}}
+	r, _ := newTestRetry(next, RetryPolicy{MaxAttempts: 2})
+	got, err := r.SuggestFilenameCandidates(context.Background(), "a", 3, Options{})
+	if err != nil || len(got.Candidates) != 1 || got.Filename != "named-a" {
+		t.Fatalf("SuggestFilenameCandidates = %+v, %v", got, err)
+	}
+}
+
+func TestRetryAttemptTimeoutIsClassified(t *testing.T) {
+	r, _ := newTestRetry(blockingClient{}, RetryPolicy{MaxAttempts: 2, AttemptTimeout: time.Millisecond})
+	if _, err := r.SuggestFilename(context.Background(), "a", Options{}); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+}
+
+func TestHTTPClientClassifiesStatuses(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   error
+	}{
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusUnauthorized, ErrAuth},
+		{http.StatusBadGateway, ErrUnavailable},
+		{http.StatusGatewayTimeout, ErrTimeout},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(tt.status)
+			w.Write([]byte(`{"error":"upstream said no"}`))
+		}))
+		_, err := NewHTTPClient(server.URL, "gpt-4o").SuggestFilename(context.Background(), "a", Options{})
+		server.Close()
+		if !errors.Is(err, tt.kind) {
+			t.Fatalf("status %d: err = %v, want %v", tt.status, err, tt.kind)
+		}
+		if tt.status == http.StatusTooManyRequests && RetryAfter(err) != 7*time.Second {
+			t.Fatalf("RetryAfter = %v, want 7s", RetryAfter(err))
+		}
+	}
+}
+
+func TestHTTPClientBadResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>proxy error</html>"))
+	}))
+	defer server.Close()
+	_, err := NewHTTPClient(server.URL, "gpt-4o").SuggestFilename(context.Background(), "a", Options{})
+	if !errors.Is(err, ErrBadResponse) {
+		t.Fatalf("err = %v, want ErrBadResponse", err)
+	}
+}
@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// MaxImageBytes bounds the images sent to vision models. Thumbnails are far
+// smaller; the limit protects the relay from arbitrary uploads.
+const MaxImageBytes = 4 << 20
+
+// imageTokenEstimate is a high guess at what one thumbnail costs in prompt
+// tokens across backends; OpenAI's low-detail mode charges 85.
+const imageTokenEstimate = 800
+
+// Image is a picture for a vision-capable model. Data is base64 in JSON.
+type Image struct {
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// Validate rejects images a vision model would not accept.
+func (img Image) Validate() error {
+	switch img.MIMEType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return fmt.Errorf("unsupported image type %q", img.MIMEType)
+	}
+	if len(img.Data) == 0 {
+		return fmt.Errorf("image is empty")
+	}
+	if len(img.Data) > MaxImageBytes {
+		return fmt.Errorf("image is %d bytes; the limit is %d", len(img.Data), MaxImageBytes)
+	}
+	return nil
+}
+
+func (img Image) base64() string {
+	return base64.StdEncoding.EncodeToString(img.Data)
+}
+
+func (img Image) dataURL() string {
+	return "data:" + img.MIMEType + ";base64," + img.base64()
+}
@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testImage = Image{MIMEType: "image/jpeg", Data: []byte("\xff\xd8\xff thumbnail")}
+
+func TestOpenAIClientSendsImageAsDataURL(t *testing.T) {
+	var got struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"candidates\":[{\"filename\":\"whiteboard-sprint-plan\",\"confidence\":0.7}]}"}}],"usage":{"prompt_tokens":300,"completion_tokens":20}}`))
+	}))
+	defer server.Close()
+
+	result, err := NewOpenAICompatibleClient(server.URL+"/v1", "k", "gpt-4o").SuggestFilenameFromImage(context.Background(), testImage, "png image 1920x1080", 2, Options{})
+	if err != nil || len(result.Candidates) != 1 || result.Filename != "whiteboard-sprint-plan" || result.Usage.PromptTokens != 300 {
+		t.Fatalf("SuggestFilenameFromImage = %+v, %v", result, err)
+	}
+	user := string(got.Messages[len(got.Messages)-1].Content)
+	if !strings.Contains(user, "data:image/jpeg;base64,"+base64.StdEncoding.EncodeToString(testImage.Data)) || !strings.Contains(user, "png image 1920x1080") {
+		t.Fatalf("user message = %s", user)
+	}
+}
+
+func TestOllamaClientSendsImages(t *testing.T) {
+	var got ollamaReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"response":"{\"candidates\":[{\"filename\":\"receipt-hardware-store\",\"confidence\":0.6}]}"}`))
+	}))
+	defer server.Close()
+
+	result, err := NewOllamaClientWithURL(server.URL, "llava").SuggestFilenameFromImage(context.Background(), testImage, "evidence", 1, Options{})
+	if err != nil || result.Candidates[0].Filename != "receipt-hardware-store" {
+		t.Fatalf("SuggestFilenameFromImage = %+v, %v", result, err)
+	}
+	if len(got.Images) != 1 || got.Images[0] != base64.StdEncoding.EncodeToString(testImage.Data) || got.Format != "json" {
+		t.Fatalf("request = %+v", got)
+	}
+}
+
+func TestHTTPClientSendsImageOutsideBatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/suggest-filename" {
+			t.Errorf("path = %s, image calls should not be batched", r.URL.Path)
+		}
+		var req filenameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req.Image == nil || string(req.Image.Data) != string(testImage.Data) || req.Content != "evidence" {
+			t.Errorf("request = %+v", req)
+		}
+		json.NewEncoder(w).Encode(filenameResponse{Candidates: []Candidate{{Filename: "sunset-beach-photo", Confidence: 0.5}}})
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, "gpt-4o").WithBatching(8, time.Hour)
+	result, err := client.SuggestFilenameFromImage(context.Background(), testImage, "evidence", 3, Options{})
+	if err != nil || result.Candidates[0].Filename != "sunset-beach-photo" {
+		t.Fatalf("SuggestFilenameFromImage = %+v, %v", result, err)
+	}
+}
+
+func TestImageValidate(t *testing.T) {
+	if err := testImage.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range []Image{
+		{MIMEType: "application/pdf", Data: []byte("x")},
+		{MIMEType: "image/png"},
+		{MIMEType: "image/png", Data: make([]byte, MaxImageBytes+1)},
+	} {
+		if err := img.Validate(); err == nil {
+			t.Fatalf("Validate(%s, %d bytes) = nil", img.MIMEType, len(img.Data))
+		}
+	}
+}
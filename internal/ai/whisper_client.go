@@ -1,7 +1,99 @@
 package ai
 
-func NewWhisperClient(model string) Client {
-	// Placeholder for the actual implementation of the Whisper client.
-	// This function should return an instance of a Whisper client that implements the Client interface.
-	return nil
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+const transcriptionTimeout = 2 * time.Minute
+
+// Transcriber turns a short audio clip into text. Each call is also bounded
+// by transcriptionTimeout.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath string) (string, error)
+}
+
+// NewWhisperClient returns an OpenAI-compatible /audio/transcriptions client
+// when a Whisper URL is configured, otherwise a local whisper.cpp client.
+func NewWhisperClient(cfg config.Config) (Transcriber, error) {
+	if cfg.WhisperURL != "" {
+		model := cfg.WhisperModel
+		if model == "" {
+			model = openai.Whisper1
+		}
+		return NewWhisperAPIClient(cfg.WhisperURL, cfg.OpenAIKey, model), nil
+	}
+	if cfg.WhisperModel == "" {
+		return nil, errors.New("whisper.cpp needs a ggml model path; set WHISPER_MODEL or --whisper-model")
+	}
+	return NewWhisperCPPClient(cfg.WhisperModel), nil
+}
+
+type WhisperAPIClient struct {
+	cl    *openai.Client
+	model string
+}
+
+func NewWhisperAPIClient(baseURL, key, model string) *WhisperAPIClient {
+	clientCfg := openai.DefaultConfig(key)
+	clientCfg.BaseURL = strings.TrimRight(baseURL, "/")
+	return &WhisperAPIClient{
+		cl:    openai.NewClientWithConfig(clientCfg),
+		model: model,
+	}
+}
+
+func (w *WhisperAPIClient) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, transcriptionTimeout)
+	defer cancel()
+
+	resp, err := w.cl.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    w.model,
+		FilePath: audioPath,
+		Format:   openai.AudioResponseFormatJSON,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
+type WhisperCPPClient struct {
+	model string
+}
+
+func NewWhisperCPPClient(model string) *WhisperCPPClient {
+	return &WhisperCPPClient{model: model}
+}
+
+// Transcribe runs whisper.cpp over a 16 kHz WAV clip. Newer builds ship the
+// CLI as whisper-cli; older packages install it as whisper-cpp.
+func (w *WhisperCPPClient) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	binary := ""
+	for _, name := range []string{"whisper-cli", "whisper-cpp"} {
+		if _, err := exec.LookPath(name); err == nil {
+			binary = name
+			break
+		}
+	}
+	if binary == "" {
+		return "", errors.New("whisper.cpp not available")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transcriptionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, binary, "-m", w.model, "-f", audioPath, "-nt", "-np").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", binary, err)
+	}
+	return strings.TrimSpace(string(out)), nil
 }
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/config"
+)
+
+func TestNewWhisperClientSelectsBackend(t *testing.T) {
+	if _, err := NewWhisperClient(config.Config{}); err == nil {
+		t.Fatal("expected error without whisper model")
+	}
+
+	got, err := NewWhisperClient(config.Config{WhisperModel: "ggml-base.en.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(*WhisperCPPClient); !ok {
+		t.Fatalf("client = %T, want *WhisperCPPClient", got)
+	}
+
+	got, err = NewWhisperClient(config.Config{WhisperURL: "http://localhost:8000/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, ok := got.(*WhisperAPIClient)
+	if !ok {
+		t.Fatalf("client = %T, want *WhisperAPIClient", got)
+	}
+	if api.model != "whisper-1" {
+		t.Fatalf("model = %q, want whisper-1", api.model)
+	}
+}
+
+func TestWhisperAPIClientTranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if got := r.FormValue("model"); got != "whisper-large-v3" {
+			t.Errorf("model = %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":" Quarterly planning call. "}`))
+	}))
+	defer server.Close()
+
+	clip := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(clip, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewWhisperAPIClient(server.URL+"/v1", "", "whisper-large-v3").Transcribe(context.Background(), clip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Quarterly planning call." {
+		t.Fatalf("transcript = %q", got)
+	}
+}
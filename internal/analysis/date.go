@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+)
+
+// contentDateKeys are metadata keys that hold when a file's content was
+// made, most trustworthy first: email Date headers, office and media
+// creation times, EXIF capture times, and office modification times.
+var contentDateKeys = []string{"date", "created", "creation_time", "DateTimeOriginal", "CreateDate", "modified"}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006:01:02 15:04:05",
+	"2006-01-02",
+}
+
+// ContentDate returns the date recorded inside the file, if its metadata
+// has one that parses.
+func ContentDate(info extractors.ExtractedFileInfo) (time.Time, bool) {
+	for _, key := range contentDateKeys {
+		value := strings.TrimSpace(info.Metadata[key])
+		if value == "" {
+			continue
+		}
+		if date, ok := parseDate(value); ok {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FileDate is ContentDate, falling back to the file's modification time. It
+// is zero when neither is known.
+func FileDate(info extractors.ExtractedFileInfo) time.Time {
+	if date, ok := ContentDate(info); ok {
+		return date
+	}
+	if stat, err := os.Stat(info.Path); err == nil {
+		return stat.ModTime()
+	}
+	return time.Time{}
+}
+
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	// EXIF times may carry a zone or subseconds after the layout above.
+	if len(value) > 19 {
+		if date, err := time.Parse("2006:01:02 15:04:05", value[:19]); err == nil {
+			return date, true
+		}
+	}
+	if date, err := mail.ParseDate(value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
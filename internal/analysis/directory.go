@@ -0,0 +1,299 @@
+package analysis
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+)
+
+// DirectoryContextSource is the evidence source AddDirectoryContext adds.
+const DirectoryContextSource = "directory-context"
+
+const (
+	// seriesScore lets a detected series outrank each file's own title, so
+	// every member is named the same way.
+	seriesScore = 0.97
+	// contextScore is for files that share terms with their siblings but
+	// have nothing that tells them apart; their own evidence usually wins.
+	contextScore = 0.6
+
+	// sharedTermShare is the share of siblings a word must appear in to be
+	// one of the folder's shared terms.
+	sharedTermShare = 0.6
+	maxSharedTerms  = 4
+)
+
+// sharedMetadataKeys are metadata values that name a set when every sibling
+// has the same one: a document author, a music artist or album, an email
+// sender, or an XML root element.
+var sharedMetadataKeys = []string{"creator", "author", "artist", "album", "from", "root"}
+
+// genericFolderWords say nothing about what a folder holds; recovery tools
+// and operating systems create folders with these names.
+var genericFolderWords = map[string]struct{}{
+	"desktop": {}, "dir": {}, "downloads": {}, "files": {}, "folder": {}, "input": {},
+	"misc": {}, "output": {}, "recovered": {}, "recup": {}, "tmp": {},
+}
+
+var numberPattern = regexp.MustCompile(`\d{1,4}`)
+
+// AddDirectoryContext adds a directory-context sample to files that share a
+// folder with siblings. The sample joins the words the siblings share, taken
+// from their evidence, their metadata and the folder's own name, with what
+// sets the file apart within the set: a date, or a number such as a
+// chapter. Names of a set then line up, as in acme-invoice-2023-03 and
+// acme-invoice-2023-04. infos is not modified.
+func AddDirectoryContext(infos []extractors.ExtractedFileInfo) []extractors.ExtractedFileInfo {
+	out := make([]extractors.ExtractedFileInfo, len(infos))
+	copy(out, infos)
+
+	folders := map[string][]int{}
+	for i, info := range out {
+		dir := filepath.Dir(info.Path)
+		folders[dir] = append(folders[dir], i)
+	}
+	for dir, members := range folders {
+		if len(members) < 2 {
+			continue
+		}
+		sort.Slice(members, func(a, b int) bool { return out[members[a]].Path < out[members[b]].Path })
+		addFolderContext(out, dir, members)
+	}
+	return out
+}
+
+func addFolderContext(infos []extractors.ExtractedFileInfo, dir string, members []int) {
+	terms := make([][]string, len(members))
+	for i, m := range members {
+		terms[i] = siblingTerms(infos[m])
+	}
+	shared := sharedTerms(terms)
+
+	context := folderWords(dir)
+	context = appendNew(context, sharedMetadataWords(infos, members)...)
+	context = appendNew(context, shared...)
+	if len(context) == 0 {
+		return
+	}
+
+	// Only files with every shared term belong to the set.
+	var set []int
+	for i, m := range members {
+		if containsAll(terms[i], shared) {
+			set = append(set, m)
+		}
+	}
+	if len(set) < 2 {
+		return
+	}
+
+	// A folder name or shared author alone does not make a series.
+	var labels map[int]string
+	if len(shared) > 0 {
+		labels = seriesLabels(infos, set)
+	}
+	for _, m := range set {
+		text, score := strings.Join(context, " "), contextScore
+		if label := labels[m]; label != "" {
+			text, score = text+" "+label, seriesScore
+		}
+		info := &infos[m]
+		info.TextSamples = append(append([]extractors.TextSample(nil), info.TextSamples...), extractors.TextSample{
+			Source: DirectoryContextSource,
+			Text:   text,
+			Score:  score,
+		})
+	}
+}
+
+// siblingTerms are the meaningful words of a file's best evidence, without
+// numbers, which belong to series labels instead.
+func siblingTerms(info extractors.ExtractedFileInfo) []string {
+	var terms []string
+	for i, sample := range RankEvidence(info) {
+		if i >= 3 {
+			break
+		}
+		for _, word := range meaningfulWords(sample.Text, 12) {
+			if strings.Trim(word, "0123456789") != "" {
+				terms = appendNew(terms, word)
+			}
+		}
+	}
+	return terms
+}
+
+// sharedTerms returns the words found in at least sharedTermShare of the
+// siblings, and in at least two, most common first and otherwise in the
+// order the first sibling uses them.
+func sharedTerms(terms [][]string) []string {
+	counts := map[string]int{}
+	var order []string
+	for _, words := range terms {
+		for _, word := range words {
+			if counts[word] == 0 {
+				order = append(order, word)
+			}
+			counts[word]++
+		}
+	}
+	need := max(2, int(float64(len(terms))*sharedTermShare+0.5))
+	var shared []string
+	for _, word := range order {
+		if counts[word] >= need {
+			shared = append(shared, word)
+		}
+	}
+	sort.SliceStable(shared, func(i, j int) bool { return counts[shared[i]] > counts[shared[j]] })
+	if len(shared) > maxSharedTerms {
+		shared = shared[:maxSharedTerms]
+	}
+	return shared
+}
+
+// folderWords names the folder, unless its name is generic or random, as
+// recovery tools' numbered folders are.
+func folderWords(dir string) []string {
+	name := filepath.Base(dir)
+	if looksRandom(name) {
+		return nil
+	}
+	var words []string
+	for _, word := range meaningfulWords(name, 3) {
+		if _, ok := genericFolderWords[word]; ok {
+			return nil
+		}
+		if len(word) >= 3 && strings.Trim(word, "0123456789") != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// sharedMetadataWords returns the words of each sharedMetadataKeys value
+// that every member has in common.
+func sharedMetadataWords(infos []extractors.ExtractedFileInfo, members []int) []string {
+	var words []string
+	for _, key := range sharedMetadataKeys {
+		value := strings.TrimSpace(infos[members[0]].Metadata[key])
+		if value == "" {
+			continue
+		}
+		same := true
+		for _, m := range members[1:] {
+			if !strings.EqualFold(strings.TrimSpace(infos[m].Metadata[key]), value) {
+				same = false
+				break
+			}
+		}
+		if same {
+			words = appendNew(words, meaningfulWords(personName(value), 3)...)
+		}
+	}
+	return words
+}
+
+// seriesLabels tells the members of set apart, by content date when every
+// member has a different one and otherwise by the first number in their
+// evidence that differs between them. Members get no label when neither
+// tells them all apart.
+func seriesLabels(infos []extractors.ExtractedFileInfo, set []int) map[int]string {
+	dates := map[int]time.Time{}
+	for _, m := range set {
+		if date, ok := ContentDate(infos[m]); ok {
+			dates[m] = date
+		}
+	}
+	if len(dates) == len(set) {
+		for _, layout := range []string{"2006-01", "2006-01-02"} {
+			if labels := distinctLabels(set, func(m int) string { return dates[m].Format(layout) }); labels != nil {
+				return labels
+			}
+		}
+	}
+
+	numbers := make(map[int][]string, len(set))
+	common := map[string]int{}
+	for _, m := range set {
+		numbers[m] = evidenceNumbers(infos[m])
+		seen := map[string]struct{}{}
+		for _, n := range numbers[m] {
+			if _, ok := seen[n]; !ok {
+				seen[n] = struct{}{}
+				common[n]++
+			}
+		}
+	}
+	width := 2
+	picked := map[int]string{}
+	for _, m := range set {
+		for _, n := range numbers[m] {
+			if common[n] < len(set) {
+				picked[m] = n
+				width = max(width, len(n))
+				break
+			}
+		}
+	}
+	if len(picked) < len(set) {
+		return nil
+	}
+	return distinctLabels(set, func(m int) string {
+		n := strings.TrimLeft(picked[m], "0")
+		return strings.Repeat("0", max(0, width-len(n))) + n
+	})
+}
+
+// evidenceNumbers lists the numbers in a file's best evidence and then in
+// its own name, with leading zeros kept.
+func evidenceNumbers(info extractors.ExtractedFileInfo) []string {
+	var numbers []string
+	for i, sample := range RankEvidence(info) {
+		if i >= 3 {
+			break
+		}
+		numbers = append(numbers, numberPattern.FindAllString(sample.Text, -1)...)
+	}
+	base := filepath.Base(info.Path)
+	return append(numbers, numberPattern.FindAllString(strings.TrimSuffix(base, filepath.Ext(base)), -1)...)
+}
+
+// distinctLabels labels each member with label, or returns nil when two
+// members would share one.
+func distinctLabels(set []int, label func(int) string) map[int]string {
+	labels := make(map[int]string, len(set))
+	seen := map[string]struct{}{}
+	for _, m := range set {
+		l := label(m)
+		if _, dup := seen[l]; dup {
+			return nil
+		}
+		seen[l] = struct{}{}
+		labels[m] = l
+	}
+	return labels
+}
+
+func containsAll(words, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(words, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendNew appends the words not already in words.
+func appendNew(words []string, more ...string) []string {
+	for _, word := range more {
+		if !slices.Contains(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
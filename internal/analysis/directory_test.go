@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+)
+
+func invoiceEmail(path, subject, date string) extractors.ExtractedFileInfo {
+	return extractors.ExtractedFileInfo{
+		Path:         path,
+		DetectedType: "email",
+		Metadata:     map[string]string{"from": "Acme Billing <billing@acme.example>", "date": date, "subject": subject},
+		TextSamples:  []extractors.TextSample{{Source: "email-subject", Text: subject, Score: 0.95}},
+	}
+}
+
+func TestDirectoryContextNamesASeriesConsistently(t *testing.T) {
+	infos := []extractors.ExtractedFileInfo{
+		invoiceEmail("acme/f0001.eml", "Your invoice for March is ready", "Tue, 14 Mar 2023 09:00:00 +0000"),
+		invoiceEmail("acme/f0002.eml", "Invoice attached: April services", "Fri, 14 Apr 2023 09:00:00 +0000"),
+		invoiceEmail("acme/f0003.eml", "May invoice", "Sun, 14 May 2023 09:00:00 +0000"),
+		{Path: "other/lonely.txt", TextSamples: []extractors.TextSample{{Source: "first-meaningful-line", Text: "Lonely file notes", Score: 0.58}}},
+	}
+
+	got := AddDirectoryContext(infos)
+
+	for i, want := range []string{"acme-billing-invoice-2023-03", "acme-billing-invoice-2023-04", "acme-billing-invoice-2023-05"} {
+		suggestion := GenerateFilename(got[i])
+		if suggestion.Filename != want || suggestion.Evidence[0] != DirectoryContextSource {
+			t.Errorf("%s: name = %q from %v, want %q", got[i].Path, suggestion.Filename, suggestion.Evidence, want)
+		}
+	}
+	if len(got[3].TextSamples) != 1 {
+		t.Fatal("a file without siblings got directory context")
+	}
+	if len(infos[0].TextSamples) != 1 {
+		t.Fatal("AddDirectoryContext modified its input")
+	}
+}
+
+func TestDirectoryContextNumbersChaptersAndSkipsUnrelatedFiles(t *testing.T) {
+	chapter := func(path, heading string) extractors.ExtractedFileInfo {
+		return extractors.ExtractedFileInfo{
+			Path:        path,
+			TextSamples: []extractors.TextSample{{Source: "markdown-heading", Text: heading, Score: 0.9}},
+		}
+	}
+	infos := []extractors.ExtractedFileInfo{
+		chapter("recup_dir.12/a.md", "Chapter 1: Harbor Lights Arrival"),
+		chapter("recup_dir.12/b.md", "Chapter 2: Harbor Lights Storm"),
+		chapter("recup_dir.12/c.md", "Chapter 10: Harbor Lights Departure"),
+		chapter("recup_dir.12/d.md", "Grocery list for the weekend"),
+	}
+
+	got := AddDirectoryContext(infos)
+
+	for i, want := range []string{"chapter-harbor-lights-01", "chapter-harbor-lights-02", "chapter-harbor-lights-10"} {
+		if name := GenerateFilename(got[i]).Filename; name != want {
+			t.Errorf("%s: name = %q, want %q", got[i].Path, name, want)
+		}
+	}
+	if name := GenerateFilename(got[3]).Filename; name != "grocery-list-weekend" {
+		t.Errorf("unrelated sibling name = %q, want its own", name)
+	}
+}
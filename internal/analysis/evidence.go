@@ -4,8 +4,10 @@ import (
 	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
 )
 
 type RankedEvidence struct {
@@ -40,6 +42,11 @@ func RankEvidence(info extractors.ExtractedFileInfo) []RankedEvidence {
 
 	sort.SliceStable(ranked, func(i, j int) bool {
 		if ranked[i].Score == ranked[j].Score {
+			// A directory series is scored to beat each file's own title,
+			// which can reach the same clamped score.
+			if (ranked[i].Source == DirectoryContextSource) != (ranked[j].Source == DirectoryContextSource) {
+				return ranked[i].Source == DirectoryContextSource
+			}
 			return sourceWeight(ranked[i].Source) > sourceWeight(ranked[j].Source)
 		}
 		return ranked[i].Score > ranked[j].Score
@@ -70,6 +77,8 @@ func sourceWeight(source string) float64 {
 		return 0.9
 	case "media-filename":
 		return 0.7
+	case "media-transcript":
+		return 0.78
 	case "image-filename":
 		return 0.75
 	case "media-timestamp":
@@ -126,6 +135,10 @@ func sourceWeight(source string) float64 {
 		return 0.72
 	case "tika-first-text":
 		return 0.62
+	case "ocr-heading":
+		return 0.8
+	case "ocr-first-text":
+		return 0.66
 	case "notebook-markdown":
 		return 0.72
 	case "office-first-paragraph":
@@ -142,6 +155,8 @@ func sourceWeight(source string) float64 {
 		return 0.68
 	case "first-meaningful-line":
 		return 0.58
+	case KeyphrasesSource:
+		return keyphraseScore
 	case "image-properties":
 		return 0.42
 	case "content":
@@ -201,7 +216,11 @@ func containsBoilerplate(s string) bool {
 	return false
 }
 
+// looksRandom reports whether s looks like an ID or hash rather than words.
+// Greek and Cyrillic are judged in their ASCII spelling, and scripts written
+// without vowels or spaces, such as Chinese, are only judged by their digits.
 func looksRandom(s string) bool {
+	s = utils.Transliterate(s)
 	letters := 0
 	vowels := 0
 	digits := 0
@@ -215,6 +234,8 @@ func looksRandom(s string) bool {
 		case unicode.IsDigit(r):
 			digits++
 			currentRun++
+		case unicode.IsLetter(r) && r >= utf8.RuneSelf && !unicode.Is(unicode.Latin, r):
+			currentRun = 0
 		case unicode.IsLetter(r):
 			letters++
 			currentRun++
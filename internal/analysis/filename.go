@@ -3,10 +3,15 @@ package analysis
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/language"
 	"github.com/djblackett/bootdev-hackathon/internal/utils"
 )
 
@@ -22,6 +27,9 @@ const minimumLocalEvidenceScore = 0.4
 
 var wordPattern = regexp.MustCompile(`[a-zA-Z0-9]+`)
 
+// unicodeWordPattern also keeps letters outside ASCII, with their accents.
+var unicodeWordPattern = regexp.MustCompile(`[\p{L}\p{N}\p{Mn}]+`)
+
 var stopWords = map[string]struct{}{
 	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {},
 	"attached": {}, "been": {}, "check": {}, "earlier": {}, "find": {},
@@ -36,8 +44,20 @@ var genericWords = map[string]struct{}{
 	"note": {}, "notes": {}, "scan": {}, "text": {}, "untitled": {},
 }
 
+// GenerateFilename names info with utils.DefaultProfile.
 func GenerateFilename(info extractors.ExtractedFileInfo) FilenameSuggestion {
+	return GenerateFilenameWith(info, utils.DefaultProfile)
+}
+
+// GenerateFilenameWith names info from its best local evidence in the
+// style of profile. Banned words are skipped when picking words, and the
+// date-prefix style is dated with FileDate.
+func GenerateFilenameWith(info extractors.ExtractedFileInfo, profile utils.NamingProfile) FilenameSuggestion {
 	samples := RankEvidence(info)
+	var date time.Time
+	if profile.Style == utils.StyleDatePrefix {
+		date = FileDate(info)
+	}
 
 	for _, sample := range samples {
 		if !filenameEvidenceSource(sample.Source) {
@@ -46,12 +66,12 @@ func GenerateFilename(info extractors.ExtractedFileInfo) FilenameSuggestion {
 		if sample.Score < minimumLocalEvidenceScore {
 			continue
 		}
-		words := meaningfulWords(sample.Text, 8)
+		words := profileWords(sample.Text, 8, profile, info.Metadata["language"])
 		if len(words) < 2 && !allowSingleWordEvidence(sample.Source, words) {
 			continue
 		}
 
-		filename := utils.Sanitize(strings.Join(words, "-"))
+		filename := profile.Format(strings.Join(words, " "), date)
 		if filename == "" {
 			continue
 		}
@@ -77,7 +97,7 @@ func GenerateFilename(info extractors.ExtractedFileInfo) FilenameSuggestion {
 	}
 
 	return FilenameSuggestion{
-		Filename:   unidentifiedFallback(info),
+		Filename:   profile.Format(unidentifiedFallback(info), date),
 		Confidence: 0.1,
 		Method:     "metadata",
 		Reason:     "no strong local evidence found",
@@ -124,7 +144,9 @@ func allowSingleWordEvidence(source string, words []string) bool {
 	case "media-filename", "image-filename":
 		return len(words) == 1 && len(words[0]) >= 4
 	default:
-		return false
+		// A Chinese compound such as 季度预算报告 is one word but a
+		// whole title.
+		return len(words) == 1 && strings.ContainsFunc(words[0], unspacedRune) && utf8.RuneCountInString(words[0]) >= 4
 	}
 }
 
@@ -160,7 +182,7 @@ func CompactEvidence(info extractors.ExtractedFileInfo, maxChars int) string {
 			fmt.Fprintf(&b, "- source: %s\n  text: %s\n", sample.Source, trimForEvidence(sample.Text, 360))
 		}
 	}
-	b.WriteString("constraints: lowercase kebab-case, no extension, 5-8 meaningful words, filename only\n")
+	b.WriteString("constraints: no extension, 5-8 meaningful words, filename only\n")
 
 	out := b.String()
 	if len(out) > maxChars {
@@ -169,9 +191,36 @@ func CompactEvidence(info extractors.ExtractedFileInfo, maxChars int) string {
 	return out
 }
 
+// meaningfulWords are the words of text worth naming a file after, in any
+// script.
 func meaningfulWords(text string, limit int) []string {
-	normalized := splitCompoundWords(text)
-	raw := wordPattern.FindAllString(normalized, -1)
+	return profileWords(text, limit, anyScript, "")
+}
+
+// anyScript keeps words in every script, for judging evidence; names are
+// formatted by the caller's profile afterwards.
+var anyScript = utils.NamingProfile{Unicode: true}
+
+// profileWords is meaningfulWords without the words profile bans or the
+// stop words of lang, an ISO 639-1 code. Letters outside ASCII are kept
+// when profile allows them and transliterated otherwise. English keeps
+// stopWords alone, which leaves in words such as "how" that make titles
+// read well.
+func profileWords(text string, limit int, profile utils.NamingProfile, lang string) []string {
+	var raw []string
+	for _, token := range unicodeWordPattern.FindAllString(splitCompoundWords(text), -1) {
+		for _, word := range splitScripts(token) {
+			word = strings.ToLower(word)
+			if lang != "en" && language.IsStopWord(lang, word) {
+				continue
+			}
+			if profile.Unicode {
+				raw = append(raw, word)
+				continue
+			}
+			raw = append(raw, wordPattern.FindAllString(utils.Transliterate(word), -1)...)
+		}
+	}
 	words := make([]string, 0, limit)
 	seen := map[string]struct{}{}
 
@@ -186,6 +235,9 @@ func meaningfulWords(text string, limit int) []string {
 		if _, ok := genericWords[word]; ok {
 			continue
 		}
+		if slices.ContainsFunc(profile.BannedWords, func(banned string) bool { return strings.EqualFold(banned, word) }) {
+			continue
+		}
 		if _, ok := seen[word]; ok {
 			continue
 		}
@@ -199,6 +251,48 @@ func meaningfulWords(text string, limit int) []string {
 	return words
 }
 
+// splitScripts splits Chinese and Japanese text, which has no spaces,
+// where it changes between kanji and kana, and drops the hiragana runs,
+// which are mostly particles and verb endings. Other words are returned
+// as they are.
+func splitScripts(word string) []string {
+	if !strings.ContainsFunc(word, unspacedRune) {
+		return []string{word}
+	}
+	var pieces []string
+	first, _ := utf8.DecodeRuneInString(word)
+	start, class := 0, runeClass(first)
+	for i, r := range word {
+		if c := runeClass(r); c != class {
+			if class != unicode.Hiragana && i > start {
+				pieces = append(pieces, word[start:i])
+			}
+			start, class = i, c
+		}
+	}
+	if class != unicode.Hiragana {
+		pieces = append(pieces, word[start:])
+	}
+	return pieces
+}
+
+// runeClass is the script table r belongs to among those splitScripts
+// splits on, or nil for any other.
+func runeClass(r rune) *unicode.RangeTable {
+	for _, table := range []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana} {
+		if unicode.Is(table, r) {
+			return table
+		}
+	}
+	return nil
+}
+
+// unspacedRune reports whether r belongs to a script written without
+// spaces between words.
+func unspacedRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+}
+
 func splitCompoundWords(s string) string {
 	replacer := strings.NewReplacer("_", " ", "-", " ", ".", " ", "/", " ")
 	return replacer.Replace(s)
@@ -1,10 +1,14 @@
 package analysis
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
 )
 
 func TestGenerateFilenamePrefersHighScoringEvidence(t *testing.T) {
@@ -89,7 +93,7 @@ top_samples:
   text: invoice_id customer_name total_due
 - source: content
   text: ` + strings.TrimSpace(strings.Repeat("full body should not dominate ", 12)) + `
-constraints: lowercase kebab-case, no extension, 5-8 meaningful words, filename only
+constraints: no extension, 5-8 meaningful words, filename only
 `
 	if got != want {
 		t.Fatalf("compact evidence mismatch\n got:\n%q\nwant:\n%q", got, want)
@@ -98,3 +102,75 @@ constraints: lowercase kebab-case, no extension, 5-8 meaningful words, filename
 		t.Fatal("compact evidence included raw content")
 	}
 }
+
+func TestGenerateFilenameWithProfile(t *testing.T) {
+	info := extractors.ExtractedFileInfo{
+		DetectedType: "email",
+		Metadata:     map[string]string{"date": "Thu, 14 Sep 2023 09:12:00 -0400"},
+		TextSamples: []extractors.TextSample{
+			{Source: "email-subject", Text: "Draft vendor contract renewal for Northwind", Score: 0.9},
+		},
+	}
+
+	snake := GenerateFilenameWith(info, utils.NamingProfile{Style: utils.StyleSnake, BannedWords: []string{"draft"}})
+	if snake.Filename != "vendor_contract_renewal_northwind" {
+		t.Fatalf("snake filename = %q", snake.Filename)
+	}
+	dated := GenerateFilenameWith(info, utils.NamingProfile{Style: utils.StyleDatePrefix})
+	if dated.Filename != "2023-09-14-draft-vendor-contract-renewal-northwind" {
+		t.Fatalf("date-prefix filename = %q", dated.Filename)
+	}
+	fallback := GenerateFilenameWith(extractors.ExtractedFileInfo{DetectedType: "text"}, utils.NamingProfile{Style: utils.StyleTitle})
+	if fallback.Filename != "Unidentified Content" {
+		t.Fatalf("fallback filename = %q", fallback.Filename)
+	}
+}
+
+func TestGenerateFilenameInOtherLanguages(t *testing.T) {
+	named := func(lang, title string, profile utils.NamingProfile) string {
+		info := extractors.ExtractedFileInfo{
+			DetectedType: "pdf",
+			Metadata:     map[string]string{"language": lang},
+			TextSamples:  []extractors.TextSample{{Source: "pdf-title", Text: title, Score: 0.9}},
+		}
+		return GenerateFilenameWith(info, profile).Filename
+	}
+	unicode := utils.NamingProfile{Unicode: true}
+	for _, tc := range []struct {
+		lang, title string
+		profile     utils.NamingProfile
+		want        string
+	}{
+		{"de", "Die Rechnung für März und April", utils.DefaultProfile, "rechnung-maerz-april"},
+		{"de", "Die Rechnung für März und April", unicode, "rechnung-märz-april"},
+		{"ru", "Отчёт о бюджете за 2023", utils.DefaultProfile, "otchyot-byudzhete-2023"},
+		{"ja", "四半期の予算報告書です", unicode, "四半期-予算報告書"},
+		{"zh", "季度预算报告", unicode, "季度预算报告"},
+		// Stop words of one language are words in another.
+		{"en", "Die Hard sequel notes", utils.DefaultProfile, "die-hard-sequel"},
+	} {
+		if got := named(tc.lang, tc.title, tc.profile); got != tc.want {
+			t.Errorf("%s %q: filename = %q, want %q", tc.lang, tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestFileDatePrefersMetadataOverModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	info := extractors.ExtractedFileInfo{Path: path, Metadata: map[string]string{"DateTimeOriginal": "2019:07:04 18:30:00"}}
+	if got := FileDate(info); got.Format("2006-01-02") != "2019-07-04" {
+		t.Fatalf("FileDate = %v, want the EXIF capture date", got)
+	}
+	info.Metadata = map[string]string{"created": "not a date"}
+	if got := FileDate(info); !got.Equal(mtime) {
+		t.Fatalf("FileDate = %v, want the modification time", got)
+	}
+}
@@ -0,0 +1,267 @@
+package analysis
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/language"
+)
+
+// KeyphrasesSource is the evidence source AddKeyphrases adds.
+const KeyphrasesSource = "keyphrases"
+
+const (
+	// keyphraseScore ranks keyphrases just below a text file's first
+	// sentence, which usually says what the file is about.
+	keyphraseScore = 0.7
+	// boilerplateKeyphraseScore is used when that sentence is mostly words
+	// most of the run shares, such as a letterhead or a confidentiality
+	// notice; keyphrases then name the file instead.
+	boilerplateKeyphraseScore = 0.8
+	// boilerplateRarity is how rare, between shared by every file (0) and
+	// found in one file (1), the opening line's words must be on average
+	// not to be boilerplate.
+	boilerplateRarity = 0.5
+
+	// minKeyphraseWords is the shortest body worth extracting keyphrases
+	// from; shorter files have titles or first lines that say enough.
+	minKeyphraseWords = 80
+	// keyphraseBodyBytes bounds how much of each body is read.
+	keyphraseBodyBytes = 64 << 10
+	maxPhraseWords     = 3
+	maxKeyphraseWords  = 5
+)
+
+// keyphraseTypes are the detected types whose content is running prose.
+var keyphraseTypes = map[string]struct{}{
+	"text": {}, "markdown": {}, "log": {}, "pdf": {}, "office": {}, "opendocument": {},
+	"rtf": {}, "html": {}, "email": {}, "epub": {},
+}
+
+// phraseBreak ends a candidate phrase: punctuation that ends a clause.
+var phraseBreak = regexp.MustCompile(`[.,;:!?()\[\]{}"“”|\n\r\t]+|\s[-–—]\s`)
+
+// keyphraseDoc is one file's body split into candidate phrases.
+type keyphraseDoc struct {
+	phrases [][]string
+	words   map[string]int
+	total   int
+}
+
+// AddKeyphrases adds a keyphrases sample to every file with a long body of
+// prose. Candidate phrases are the runs of words between stop words and
+// punctuation, as in RAKE, and are ranked by the TF-IDF of their words
+// against every file in infos, so terms the whole run shares, such as a
+// company name in each letterhead, give way to what sets the file apart.
+// The best phrases are joined into one sample of up to five words. infos
+// is not modified.
+func AddKeyphrases(infos []extractors.ExtractedFileInfo) []extractors.ExtractedFileInfo {
+	out := make([]extractors.ExtractedFileInfo, len(infos))
+	copy(out, infos)
+
+	docs := make([]*keyphraseDoc, len(out))
+	df := map[string]int{}
+	for i, info := range out {
+		if _, ok := keyphraseTypes[info.DetectedType]; !ok {
+			continue
+		}
+		doc := splitPhrases(info.RawContent, info.Metadata["language"])
+		if doc.total < minKeyphraseWords {
+			continue
+		}
+		docs[i] = doc
+		for word := range doc.words {
+			df[word]++
+		}
+	}
+	n := 0
+	for _, doc := range docs {
+		if doc != nil {
+			n++
+		}
+	}
+
+	idf := func(word string) float64 {
+		return math.Log(float64(1+n)/float64(1+max(1, df[word]))) + 1
+	}
+	// rarity maps IDF onto 0 for words every file has and 1 for words
+	// only one file has. A single file tells nothing apart.
+	rarity := func(idf float64) float64 {
+		if n < 2 {
+			return 1
+		}
+		return (idf - 1) / math.Log(float64(1+n)/2)
+	}
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		phrases := rankPhrases(doc, idf)
+		if len(phrases) == 0 {
+			continue
+		}
+		score := keyphraseScore
+		if opening := openingLine(out[i]); opening != "" && rarity(meanIDF(opening, out[i].Metadata["language"], idf)) < boilerplateRarity {
+			score = boilerplateKeyphraseScore
+		}
+		info := &out[i]
+		info.TextSamples = append(append([]extractors.TextSample(nil), info.TextSamples...), extractors.TextSample{
+			Source: KeyphrasesSource,
+			Text:   strings.Join(phrases, " "),
+			Score:  score,
+		})
+	}
+	return out
+}
+
+// splitPhrases splits body into candidate phrases of meaningful words and
+// counts the words. Stop words, generic words and numbers break phrases.
+func splitPhrases(body, lang string) *keyphraseDoc {
+	if len(body) > keyphraseBodyBytes {
+		body = body[:keyphraseBodyBytes]
+	}
+	doc := &keyphraseDoc{words: map[string]int{}}
+	for _, clause := range phraseBreak.Split(strings.ToLower(body), -1) {
+		var phrase []string
+		flush := func() {
+			if len(phrase) > 0 && len(phrase) <= maxPhraseWords {
+				doc.phrases = append(doc.phrases, phrase)
+			}
+			phrase = nil
+		}
+		for _, token := range unicodeWordPattern.FindAllString(clause, -1) {
+			for _, word := range splitScripts(token) {
+				doc.total++
+				if !keyphraseWord(word, lang) {
+					flush()
+					continue
+				}
+				doc.words[word]++
+				phrase = append(phrase, word)
+			}
+		}
+		flush()
+	}
+	return doc
+}
+
+func keyphraseWord(word, lang string) bool {
+	if len(word) < 3 || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+		return false
+	}
+	if _, ok := stopWords[word]; ok {
+		return false
+	}
+	if _, ok := genericWords[word]; ok {
+		return false
+	}
+	return !language.IsStopWord("en", word) && !language.IsStopWord(lang, word)
+}
+
+// rankPhrases scores each distinct phrase by how often it occurs times the
+// sum of its words' TF-IDF, and returns the best phrases that share no
+// words, up to maxKeyphraseWords words in all. A phrase must occur at least
+// twice; one mention in a long body is not what the file is about. Ties go
+// to the phrase that comes first, as the subject usually does.
+func rankPhrases(doc *keyphraseDoc, idf func(string) float64) []string {
+	counts := map[string]int{}
+	first := map[string]int{}
+	words := map[string][]string{}
+	for i, phrase := range doc.phrases {
+		key := strings.Join(phrase, " ")
+		if counts[key] == 0 {
+			first[key] = i
+		}
+		counts[key]++
+		words[key] = phrase
+	}
+
+	type scored struct {
+		text  string
+		score float64
+	}
+	var ranked []scored
+	for text, count := range counts {
+		if count < 2 {
+			continue
+		}
+		weight := 0.0
+		for _, word := range words[text] {
+			weight += float64(doc.words[word]) / float64(doc.total) * idf(word)
+		}
+		ranked = append(ranked, scored{text, float64(count) * weight})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score == ranked[j].score {
+			return first[ranked[i].text] < first[ranked[j].text]
+		}
+		return ranked[i].score > ranked[j].score
+	})
+
+	var picked []string
+	used := map[string]struct{}{}
+	total := 0
+	for _, r := range ranked {
+		phrase := words[r.text]
+		// A single word tacked onto a name seldom adds to it.
+		if total+len(phrase) > maxKeyphraseWords || len(picked) > 0 && len(phrase) == 1 {
+			continue
+		}
+		overlaps := false
+		for _, word := range phrase {
+			if _, ok := used[word]; ok {
+				overlaps = true
+			}
+		}
+		if overlaps {
+			continue
+		}
+		for _, word := range phrase {
+			used[word] = struct{}{}
+		}
+		picked = append(picked, r.text)
+		total += len(phrase)
+	}
+	// One word alone seldom names a file.
+	if total < 2 {
+		return nil
+	}
+	return picked
+}
+
+// openingLine is the best scored of the file's first line and summary, the
+// samples that would name it and that a boilerplate header spoils.
+func openingLine(info extractors.ExtractedFileInfo) string {
+	best, bestScore := "", -1.0
+	for _, sample := range info.TextSamples {
+		switch sample.Source {
+		case "text-summary", "first-meaningful-line", "pdf-first-text", "office-first-paragraph":
+			if sample.Score > bestScore {
+				best, bestScore = sample.Text, sample.Score
+			}
+		}
+	}
+	return best
+}
+
+// meanIDF is the average IDF of the meaningful words of text, or the
+// highest possible IDF when it has none.
+func meanIDF(text, lang string, idf func(string) float64) float64 {
+	sum, n := 0.0, 0
+	for _, token := range unicodeWordPattern.FindAllString(strings.ToLower(text), -1) {
+		for _, word := range splitScripts(token) {
+			if keyphraseWord(word, lang) {
+				sum += idf(word)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return math.Inf(1)
+	}
+	return sum / float64(n)
+}
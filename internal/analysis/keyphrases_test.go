@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+)
+
+const bulletinHeader = "Northwind Holdings staff bulletin prepared by the communications office for all employees"
+
+func bulletin(path string, paragraphs ...string) extractors.ExtractedFileInfo {
+	body := bulletinHeader + "\n\n" + strings.Join(paragraphs, "\n\n")
+	return extractors.ExtractedFileInfo{
+		Path:         path,
+		DetectedType: "text",
+		RawContent:   body,
+		Metadata:     map[string]string{"language": "en"},
+		TextSamples: []extractors.TextSample{
+			{Source: "first-meaningful-line", Text: bulletinHeader, Score: 0.55},
+			{Source: "text-summary", Text: bulletinHeader, Score: 0.72},
+		},
+	}
+}
+
+func TestKeyphrasesNameLongReportsBehindBoilerplate(t *testing.T) {
+	infos := []extractors.ExtractedFileInfo{
+		bulletin("f0001.txt",
+			"This quarter the facilities team finished the solar panel installation on the warehouse roof. The solar panel installation was delayed twice by weather, and the roof inspection found two cracked supports that were replaced before work resumed.",
+			"Staff can follow the solar panel installation results on the energy dashboard. A second roof inspection is planned for the autumn, and the facilities team will report on savings once a full season of readings is in.",
+			"Questions about the solar panel installation should go to the facilities desk. Northwind Holdings thanks everyone for their patience while the roof inspection closed the loading bay for a week."),
+		bulletin("f0002.txt",
+			"The payroll migration to the new accounting platform is complete for every office. During the payroll migration, each employee record was checked against last year's tax forms, and the pension contributions were recalculated where rates had changed.",
+			"If your pension contributions look wrong on the first payslip after the payroll migration, contact human resources. Northwind Holdings will correct any errors in the following pay run without waiting for the quarter to close.",
+			"The old payroll system stays readable until March for anyone who needs earlier payslips, and the pension contributions history has been copied across in full."),
+		bulletin("f0003.txt",
+			"The annual volunteer day took place at the river cleanup site downstream of the head office. Teams collected more than two tonnes of litter during the river cleanup, and the volunteer day raised funds for the local wildlife trust.",
+			"Photos from the river cleanup are on the intranet. The wildlife trust has asked Northwind Holdings to make the volunteer day a spring event next year, when water levels are lower and the banks are easier to reach.",
+			"Thanks to every team that joined the river cleanup, and to the wildlife trust for the equipment and safety briefing."),
+		{Path: "note.txt", DetectedType: "text", RawContent: "Pick up milk and bread.",
+			TextSamples: []extractors.TextSample{{Source: "first-meaningful-line", Text: "Pick up milk and bread.", Score: 0.55}}},
+	}
+
+	got := AddKeyphrases(infos)
+
+	for i, want := range []string{"solar-panel-installation", "payroll-migration", "river-cleanup-wildlife-trust"} {
+		suggestion := GenerateFilename(got[i])
+		if suggestion.Filename != want || suggestion.Evidence[0] != KeyphrasesSource {
+			t.Errorf("%s: name = %q from %v, want %q", got[i].Path, suggestion.Filename, suggestion.Evidence, want)
+		}
+	}
+	if len(got[3].TextSamples) != 1 {
+		t.Fatal("a short file got keyphrases")
+	}
+	if len(infos[0].TextSamples) != 2 {
+		t.Fatal("AddKeyphrases modified its input")
+	}
+}
+
+func TestKeyphrasesRankBelowADistinctiveSummary(t *testing.T) {
+	body := strings.Repeat("The lighthouse restoration budget is spent on the lenses and the keeper cottage. ", 12)
+	info := extractors.ExtractedFileInfo{
+		Path:         "f0009.txt",
+		DetectedType: "text",
+		RawContent:   body,
+		TextSamples:  []extractors.TextSample{{Source: "text-summary", Text: "Lighthouse restoration budget for the harbour trust", Score: 0.72}},
+	}
+
+	got := AddKeyphrases([]extractors.ExtractedFileInfo{info})[0]
+
+	sample := got.TextSamples[len(got.TextSamples)-1]
+	if sample.Source != KeyphrasesSource || sample.Score != keyphraseScore {
+		t.Fatalf("last sample = %+v, want keyphrases at %v", sample, keyphraseScore)
+	}
+	if name := GenerateFilename(got).Filename; name != "lighthouse-restoration-budget-harbour-trust" {
+		t.Fatalf("name = %q, want the summary's", name)
+	}
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+)
+
+const (
+	shingleWords   = 3
+	minShingles    = 5
+	minHashBands   = 16
+	minHashRows    = 4
+	minHashLength  = minHashBands * minHashRows
+	minHashSeedMix = 0x9e3779b97f4a7c15
+)
+
+// ClusterNearDuplicates groups files whose extracted text is similar enough to
+// be versions of one document. Similarity is the MinHash estimate of the
+// Jaccard index over word shingles; pairs at or above threshold are joined.
+// Each cluster has at least two members, ordered oldest version first, and
+// clusters are ordered by their first member's path.
+func ClusterNearDuplicates(infos []extractors.ExtractedFileInfo, threshold float64) [][]extractors.ExtractedFileInfo {
+	signatures := make([][]uint64, len(infos))
+	buckets := map[string][]int{}
+	for i, info := range infos {
+		signatures[i] = minHashSignature(documentText(info))
+		if signatures[i] == nil {
+			continue
+		}
+		// Locality-sensitive hashing: only files that agree on a whole band
+		// are compared, so the pass stays close to linear.
+		for band := 0; band < minHashBands; band++ {
+			var key strings.Builder
+			key.WriteByte(byte(band))
+			for _, v := range signatures[i][band*minHashRows : (band+1)*minHashRows] {
+				for shift := 0; shift < 64; shift += 8 {
+					key.WriteByte(byte(v >> shift))
+				}
+			}
+			buckets[key.String()] = append(buckets[key.String()], i)
+		}
+	}
+
+	parent := make([]int, len(infos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, members := range buckets {
+		for a := 0; a < len(members); a++ {
+			for b := a + 1; b < len(members); b++ {
+				i, j := members[a], members[b]
+				if find(i) == find(j) || minHashSimilarity(signatures[i], signatures[j]) < threshold {
+					continue
+				}
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	grouped := map[int][]extractors.ExtractedFileInfo{}
+	for i, info := range infos {
+		if signatures[i] != nil {
+			grouped[find(i)] = append(grouped[find(i)], info)
+		}
+	}
+	var clusters [][]extractors.ExtractedFileInfo
+	for _, members := range grouped {
+		if len(members) < 2 {
+			continue
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			ti, tj := VersionTime(members[i]), VersionTime(members[j])
+			if ti.Equal(tj) {
+				return members[i].Path < members[j].Path
+			}
+			return ti.Before(tj)
+		})
+		clusters = append(clusters, members)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0].Path < clusters[j][0].Path
+	})
+	return clusters
+}
+
+// VersionTime is when a file version was last saved: the document's own
+// modified metadata when present, otherwise the file mtime.
+func VersionTime(info extractors.ExtractedFileInfo) time.Time {
+	if modified, err := time.Parse(time.RFC3339, info.Metadata["modified"]); err == nil {
+		return modified
+	}
+	if stat, err := os.Stat(info.Path); err == nil {
+		return stat.ModTime()
+	}
+	return time.Time{}
+}
+
+func documentText(info extractors.ExtractedFileInfo) string {
+	if strings.TrimSpace(info.RawContent) != "" {
+		return info.RawContent
+	}
+	texts := make([]string, 0, len(info.TextSamples))
+	for _, sample := range info.TextSamples {
+		texts = append(texts, sample.Text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// minHashSignature returns nil for text too short to compare reliably.
+func minHashSignature(text string) []uint64 {
+	words := wordPattern.FindAllString(strings.ToLower(text), -1)
+	if len(words)-shingleWords+1 < minShingles {
+		return nil
+	}
+
+	signature := make([]uint64, minHashLength)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	seen := map[string]struct{}{}
+	for i := 0; i+shingleWords <= len(words); i++ {
+		shingle := strings.Join(words[i:i+shingleWords], " ")
+		if _, ok := seen[shingle]; ok {
+			continue
+		}
+		seen[shingle] = struct{}{}
+
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		base := h.Sum64()
+		for k := range signature {
+			if v := mix64(base ^ (uint64(k+1) * minHashSeedMix)); v < signature[k] {
+				signature[k] = v
+			}
+		}
+	}
+	if len(seen) < minShingles {
+		return nil
+	}
+	return signature
+}
+
+func minHashSimilarity(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// mix64 is the splitmix64 finalizer; it turns one base hash into independent
+// hash functions cheaply.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
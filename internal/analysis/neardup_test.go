@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+)
+
+const budgetDraft = "The quarterly budget plan covers staffing, travel, equipment and software for the north region. " +
+	"Staffing grows by two engineers in the second quarter. Travel is limited to customer visits and the annual offsite. " +
+	"Equipment spending replaces the aging laptops and the conference room displays. Software renewals are consolidated " +
+	"under a single vendor agreement to reduce overhead and simplify procurement for the finance team."
+
+func TestClusterNearDuplicatesOrdersVersionsByModifiedMetadata(t *testing.T) {
+	infos := []extractors.ExtractedFileInfo{
+		{Path: "budget-final.docx", RawContent: budgetDraft + " Approved by the board.", Metadata: map[string]string{"modified": "2025-03-02T10:00:00Z"}},
+		{Path: "budget-autosave.docx", RawContent: budgetDraft, Metadata: map[string]string{"modified": "2025-03-01T10:00:00Z"}},
+		{Path: "recipes.txt", RawContent: "Whisk the eggs with sugar until pale, fold in the flour, and bake the sponge for twenty five minutes at a moderate heat."},
+		{Path: "short.txt", RawContent: "budget plan"},
+	}
+
+	clusters := ClusterNearDuplicates(infos, 0.7)
+
+	if len(clusters) != 1 {
+		t.Fatalf("clusters = %d, want 1", len(clusters))
+	}
+	if len(clusters[0]) != 2 || clusters[0][0].Path != "budget-autosave.docx" || clusters[0][1].Path != "budget-final.docx" {
+		t.Fatalf("cluster = %+v, want autosave then final", clusters[0])
+	}
+}
+
+func TestClusterNearDuplicatesKeepsDistinctDocumentsApart(t *testing.T) {
+	infos := []extractors.ExtractedFileInfo{
+		{Path: "a.txt", RawContent: budgetDraft},
+		{Path: "b.txt", RawContent: "Minutes from the south region planning meeting. Attendees reviewed the hiring pipeline, the delayed warehouse lease, and the marketing calendar for the spring launch."},
+	}
+
+	if clusters := ClusterNearDuplicates(infos, 0.7); len(clusters) != 0 {
+		t.Fatalf("clusters = %+v, want none", clusters)
+	}
+}
@@ -0,0 +1,269 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+// DefaultOrganizeLayout files each category by topic, or by year when no
+// topic fits: photos/2019, documents/finance.
+const DefaultOrganizeLayout = "{category}/{topic|date|mtime:2006}"
+
+// OrganizeRules decide the folder each file goes to under the output
+// directory. Layouts are folder paths whose segments are name templates;
+// besides the usual placeholders they see {category} and {topic}. A segment
+// without placeholders is a literal folder, and one that resolves to nothing
+// is left out.
+//
+//	layout: "{category}/{topic|date|mtime:2006}"
+//	layouts:
+//	  photos: "photos/{date|mtime:2006}/{Model}"
+//	categories:
+//	  photos: [image, heic]
+//	  documents: [pdf, docx, text, markdown]
+//	topics:
+//	  finance: [invoice, receipt, budget, tax]
+type OrganizeRules struct {
+	Layout     string              `yaml:"layout"`
+	Layouts    map[string]string   `yaml:"layouts"`
+	Categories map[string][]string `yaml:"categories"`
+	Topics     map[string][]string `yaml:"topics"`
+	// Other is the category of files no category lists.
+	Other string `yaml:"other"`
+
+	categoryOf map[string]string
+	layouts    map[string][]layoutSegment
+}
+
+// layoutSegment is one folder of a layout: a literal name, or a template.
+type layoutSegment struct {
+	literal  string
+	template *NameTemplate
+}
+
+// DefaultOrganizeRules returns the built-in rules.
+func DefaultOrganizeRules() *OrganizeRules {
+	rules, err := defaultOrganizeRules().compile()
+	if err != nil {
+		panic(fmt.Sprintf("built-in organize rules: %v", err))
+	}
+	return rules
+}
+
+func defaultOrganizeRules() *OrganizeRules {
+	return &OrganizeRules{
+		Layout: DefaultOrganizeLayout,
+		Categories: map[string][]string{
+			"photos":        {"image", "jpg", "jpeg", "png", "gif", "heic", "tiff", "webp", "bmp"},
+			"audio":         {"mp3", "m4a", "wav", "flac", "ogg", "aac", "opus"},
+			"videos":        {"media", "mp4", "mov", "mkv", "avi", "webm"},
+			"documents":     {"pdf", "text", "txt", "markdown", "md", "rtf", "docx", "doc", "odt", "epub", "html", "office", "opendocument"},
+			"spreadsheets":  {"csv", "tsv", "xlsx", "xls", "ods"},
+			"presentations": {"pptx", "ppt", "odp"},
+			"email":         {"email", "eml", "msg", "mbox"},
+			"data":          {"json", "xml", "musicxml", "yaml", "yml", "toml", "notebook", "ipynb", "log"},
+			"archives":      {"archive", "zip", "tar.gz", "gz", "7z", "rar"},
+		},
+		Topics: map[string][]string{
+			"finance": {"invoice", "receipt", "budget", "tax", "bank", "statement", "payroll", "expense", "payment"},
+			"legal":   {"contract", "agreement", "lease", "nda", "terms", "policy", "court"},
+			"medical": {"medical", "patient", "prescription", "clinic", "diagnosis", "insurance"},
+			"travel":  {"flight", "hotel", "itinerary", "boarding", "booking", "trip"},
+		},
+		Other: "other",
+	}
+}
+
+// LoadOrganizeRules reads rules from the YAML file at path. Each section the
+// file sets replaces the built-in one, so a file with only topics keeps the
+// built-in categories. An empty path returns DefaultOrganizeRules.
+func LoadOrganizeRules(path string) (*OrganizeRules, error) {
+	if path == "" {
+		return DefaultOrganizeRules(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file OrganizeRules
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse organize rules %s: %w", path, err)
+	}
+	rules := defaultOrganizeRules()
+	if file.Layout != "" {
+		rules.Layout = file.Layout
+	}
+	if file.Layouts != nil {
+		rules.Layouts = file.Layouts
+	}
+	if file.Categories != nil {
+		rules.Categories = file.Categories
+	}
+	if file.Topics != nil {
+		rules.Topics = file.Topics
+	}
+	if file.Other != "" {
+		rules.Other = file.Other
+	}
+	compiled, err := rules.compile()
+	if err != nil {
+		return nil, fmt.Errorf("organize rules %s: %w", path, err)
+	}
+	return compiled, nil
+}
+
+func (r *OrganizeRules) compile() (*OrganizeRules, error) {
+	r.categoryOf = map[string]string{}
+	for _, category := range slices.Sorted(maps.Keys(r.Categories)) {
+		for _, t := range r.Categories[category] {
+			t = strings.ToLower(strings.TrimPrefix(t, "."))
+			if other, ok := r.categoryOf[t]; ok {
+				return nil, fmt.Errorf("type %q is in both %s and %s", t, other, category)
+			}
+			r.categoryOf[t] = category
+		}
+	}
+	for topic, keywords := range r.Topics {
+		if len(keywords) == 0 {
+			return nil, fmt.Errorf("topic %q has no keywords", topic)
+		}
+		for i, keyword := range keywords {
+			keywords[i] = strings.ToLower(keyword)
+		}
+	}
+
+	r.layouts = map[string][]layoutSegment{}
+	layouts := map[string]string{"": r.Layout}
+	maps.Copy(layouts, r.Layouts)
+	for category, layout := range layouts {
+		if _, ok := r.Categories[category]; !ok && category != "" && category != r.Other {
+			return nil, fmt.Errorf("layout for unknown category %q", category)
+		}
+		segments, err := parseLayout(layout)
+		if err != nil {
+			return nil, err
+		}
+		r.layouts[category] = segments
+	}
+	return r, nil
+}
+
+func parseLayout(layout string) ([]layoutSegment, error) {
+	var segments []layoutSegment
+	for _, part := range strings.Split(layout, "/") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+			continue
+		case part == "." || part == "..":
+			return nil, fmt.Errorf("layout %q must stay inside the output directory", layout)
+		case !strings.ContainsAny(part, "{}"):
+			segments = append(segments, layoutSegment{literal: part})
+		default:
+			tmpl, err := ParseNameTemplate(part)
+			if err != nil {
+				return nil, fmt.Errorf("layout %q: %w", layout, err)
+			}
+			segments = append(segments, layoutSegment{template: tmpl})
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("layout %q has no folders", layout)
+	}
+	return segments, nil
+}
+
+// Category returns the category folder for info, matching its suggested
+// extension, then its detected subtype, then its detected type.
+func (r *OrganizeRules) Category(info extractors.ExtractedFileInfo) string {
+	for _, t := range []string{info.SuggestedExtension, info.Metadata["detected_subtype"], info.DetectedType} {
+		if category, ok := r.categoryOf[strings.ToLower(t)]; ok {
+			return category
+		}
+	}
+	return r.Other
+}
+
+// TopicNames lists the topics in order, for asking the AI to pick one.
+func (r *OrganizeRules) TopicNames() []string {
+	return slices.Sorted(maps.Keys(r.Topics))
+}
+
+// HasTopic reports whether topic is one of the rules' topics.
+func (r *OrganizeRules) HasTopic(topic string) bool {
+	_, ok := r.Topics[topic]
+	return ok
+}
+
+// Topic returns the topic whose keywords appear most often among the words
+// of info's best evidence, or "" when none does. Keywords also match their
+// plurals, and ties go to the first topic by name.
+func (r *OrganizeRules) Topic(info extractors.ExtractedFileInfo) string {
+	words := map[string]struct{}{}
+	for i, sample := range RankEvidence(info) {
+		if i >= 5 {
+			break
+		}
+		for _, word := range wordPattern.FindAllString(sample.Text, -1) {
+			words[strings.ToLower(word)] = struct{}{}
+		}
+	}
+
+	best, bestHits := "", 0
+	for _, topic := range r.TopicNames() {
+		hits := 0
+		for _, keyword := range r.Topics[topic] {
+			_, singular := words[keyword]
+			_, plural := words[keyword+"s"]
+			if singular || plural {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = topic, hits
+		}
+	}
+	return best
+}
+
+// Folder returns the folder for info under the output directory, relative
+// and slash-separated, from the layout for its category. topic may be "".
+func (r *OrganizeRules) Folder(info extractors.ExtractedFileInfo, topic string, profile utils.NamingProfile) string {
+	category := r.Category(info)
+	segments, ok := r.layouts[category]
+	if !ok {
+		segments = r.layouts[""]
+	}
+
+	// The template placeholders read these like metadata.
+	info.Metadata = maps.Clone(info.Metadata)
+	if info.Metadata == nil {
+		info.Metadata = map[string]string{}
+	}
+	info.Metadata["category"] = category
+	info.Metadata["topic"] = topic
+
+	var folders []string
+	for _, segment := range segments {
+		folder := segment.literal
+		if segment.template != nil {
+			folder = segment.template.Render(info, "", profile)
+		}
+		if folder = strings.Trim(folder, ". "); folder != "" {
+			folders = append(folders, folder)
+		}
+	}
+	if len(folders) == 0 {
+		return profile.Format(category, time.Time{})
+	}
+	return strings.Join(folders, "/")
+}
@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+func TestOrganizeRulesPickCategoryTopicAndYear(t *testing.T) {
+	rules := DefaultOrganizeRules()
+	for _, tc := range []struct {
+		info  extractors.ExtractedFileInfo
+		topic string
+		want  string
+	}{
+		{
+			info: extractors.ExtractedFileInfo{
+				Path: "IMG_0001.jpg", Extension: "jpg", SuggestedExtension: "jpg", DetectedType: "image",
+				Metadata: map[string]string{"DateTimeOriginal": "2019:07:04 18:30:00"},
+			},
+			want: "photos/2019",
+		},
+		{
+			info: extractors.ExtractedFileInfo{
+				Path: "scan.pdf", Extension: "pdf", SuggestedExtension: "pdf", DetectedType: "pdf",
+				TextSamples: []extractors.TextSample{{Source: "title", Text: "Receipts and invoices for the tax year", Score: 0.9}},
+			},
+			topic: "finance",
+			want:  "documents/finance",
+		},
+		{
+			info: extractors.ExtractedFileInfo{Path: "blob.bin", Extension: "bin", DetectedType: "binary"},
+			want: "other",
+		},
+	} {
+		topic := rules.Topic(tc.info)
+		if topic != tc.topic {
+			t.Errorf("%s: Topic = %q, want %q", tc.info.Path, topic, tc.topic)
+		}
+		if got := rules.Folder(tc.info, topic, utils.DefaultProfile); got != tc.want {
+			t.Errorf("%s: Folder = %q, want %q", tc.info.Path, got, tc.want)
+		}
+	}
+}
+
+func TestLoadOrganizeRulesOverridesSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "organize.yaml")
+	if err := os.WriteFile(path, []byte(`layouts:
+  photos: "Pictures/{date|mtime:2006}/{Model}"
+topics:
+  hobbies: [guitar, chess]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadOrganizeRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.HasTopic("finance") || !rules.HasTopic("hobbies") {
+		t.Fatalf("topics = %v, want only the file's", rules.TopicNames())
+	}
+	photo := extractors.ExtractedFileInfo{
+		Path: "IMG_0002.heic", Extension: "heic", DetectedType: "image",
+		Metadata: map[string]string{"DateTimeOriginal": "2021:03:01 09:00:00", "Model": "Pixel 7"},
+	}
+	if got := rules.Folder(photo, "", utils.DefaultProfile); got != "Pictures/2021/pixel-7" {
+		t.Fatalf("photo folder = %q", got)
+	}
+	doc := extractors.ExtractedFileInfo{Path: "tabs.txt", Extension: "txt", DetectedType: "text",
+		TextSamples: []extractors.TextSample{{Source: "heading", Text: "Guitar tabs", Score: 0.9}}}
+	if got := rules.Folder(doc, rules.Topic(doc), utils.DefaultProfile); got != "documents/hobbies" {
+		t.Fatalf("document folder = %q", got)
+	}
+
+	for name, bad := range map[string]string{
+		"two categories": "categories:\n  a: [pdf]\n  b: [pdf]\n",
+		"empty topic":    "topics:\n  empty: []\n",
+		"escape":         "layout: \"../{category}\"\n",
+		"unknown layout": "layouts:\n  pictures: \"{category}\"\n",
+	} {
+		writeErr := os.WriteFile(path, []byte(bad), 0644)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+		if _, err := LoadOrganizeRules(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: err = %v, want an error naming the file", name, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+// RuleMethodPrefix starts the method of names that came from a rule, as in
+// rule:bank-statement.
+const RuleMethodPrefix = "rule:"
+
+// NameRules are deterministic overrides checked before any heuristic or AI
+// call. The first rule whose conditions all hold names the file.
+//
+//	rules:
+//	  - name: bank-statement
+//	    types: [email]
+//	    metadata:
+//	      from: '@bank\.com'
+//	    template: "bank-statement-{date}"
+//	  - name: invoice-export
+//	    metadata:
+//	      headers: '\binvoice_id\b'
+//	      first_row: '^(?P<invoice>[^,]+)'
+//	    template: "invoice-{invoice}"
+type NameRules struct {
+	Rules []NameRule `yaml:"rules"`
+}
+
+// NameRule names files that match every condition it sets. Types match the
+// extension, detected subtype or detected type; metadata values and text
+// are regular expressions, and text matches when any evidence sample does.
+// Named groups in the expressions become placeholders for the template.
+type NameRule struct {
+	Name     string            `yaml:"name"`
+	Types    []string          `yaml:"types"`
+	Metadata map[string]string `yaml:"metadata"`
+	Text     string            `yaml:"text"`
+	Template string            `yaml:"template"`
+
+	metadata map[string]*regexp.Regexp
+	text     *regexp.Regexp
+	template *NameTemplate
+}
+
+// LoadNameRules reads rules from the YAML file at path. An empty path
+// returns nil, which matches nothing.
+func LoadNameRules(path string) (*NameRules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules NameRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse name rules %s: %w", path, err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("name rules %s: %w", path, err)
+	}
+	return &rules, nil
+}
+
+func (r *NameRules) compile() error {
+	seen := map[string]struct{}{}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i+1)
+		}
+		if _, dup := seen[rule.Name]; dup {
+			return fmt.Errorf("rule %q is defined twice", rule.Name)
+		}
+		seen[rule.Name] = struct{}{}
+		if len(rule.Types) == 0 && len(rule.Metadata) == 0 && rule.Text == "" {
+			return fmt.Errorf("rule %q has no conditions", rule.Name)
+		}
+
+		var err error
+		if rule.template, err = ParseNameTemplate(rule.Template); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		rule.metadata = map[string]*regexp.Regexp{}
+		for key, pattern := range rule.Metadata {
+			if rule.metadata[key], err = regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("rule %q metadata %s: %w", rule.Name, key, err)
+			}
+		}
+		if rule.Text != "" {
+			if rule.text, err = regexp.Compile(rule.Text); err != nil {
+				return fmt.Errorf("rule %q text: %w", rule.Name, err)
+			}
+		}
+		for i, t := range rule.Types {
+			rule.Types[i] = strings.ToLower(strings.TrimPrefix(t, "."))
+		}
+	}
+	return nil
+}
+
+// Match names info with the first rule that matches it and renders to a
+// name. The suggestion's evidence lists the conditions that held. A nil
+// NameRules matches nothing.
+func (r *NameRules) Match(info extractors.ExtractedFileInfo, profile utils.NamingProfile) (FilenameSuggestion, bool) {
+	if r == nil {
+		return FilenameSuggestion{}, false
+	}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		captures, evidence, ok := rule.match(info)
+		if !ok {
+			continue
+		}
+		// The template reads captures like metadata.
+		info.Metadata = maps.Clone(info.Metadata)
+		if info.Metadata == nil {
+			info.Metadata = map[string]string{}
+		}
+		maps.Copy(info.Metadata, captures)
+		name := rule.template.Render(info, "", profile)
+		if name == "" {
+			continue
+		}
+		return FilenameSuggestion{
+			Filename:   name,
+			Confidence: 1,
+			Method:     RuleMethodPrefix + rule.Name,
+			Evidence:   evidence,
+			Reason:     "matched rule " + rule.Name,
+		}, true
+	}
+	return FilenameSuggestion{}, false
+}
+
+// match reports whether every condition of the rule holds for info, with
+// the named groups the expressions captured and the conditions checked.
+func (rule *NameRule) match(info extractors.ExtractedFileInfo) (map[string]string, []string, bool) {
+	captures := map[string]string{}
+	var evidence []string
+	if len(rule.Types) > 0 {
+		types := []string{info.Extension, info.SuggestedExtension, info.Metadata["detected_subtype"], info.DetectedType}
+		if !slices.ContainsFunc(types, func(t string) bool { return t != "" && slices.Contains(rule.Types, strings.ToLower(t)) }) {
+			return nil, nil, false
+		}
+		evidence = append(evidence, "type")
+	}
+	for _, key := range slices.Sorted(maps.Keys(rule.metadata)) {
+		if !capture(rule.metadata[key], metadataValue(info.Metadata, key), captures) {
+			return nil, nil, false
+		}
+		evidence = append(evidence, "metadata:"+key)
+	}
+	if rule.text != nil {
+		matched := false
+		for _, sample := range RankEvidence(info) {
+			if capture(rule.text, sample.Text, captures) {
+				evidence = append(evidence, "text:"+sample.Source)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, nil, false
+		}
+	}
+	return captures, evidence, true
+}
+
+// capture matches pattern against s and records its non-empty named
+// groups in captures.
+func capture(pattern *regexp.Regexp, s string, captures map[string]string) bool {
+	if s == "" {
+		return false
+	}
+	m := pattern.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	for i, name := range pattern.SubexpNames() {
+		if name != "" && strings.TrimSpace(m[i]) != "" {
+			captures[name] = strings.TrimSpace(m[i])
+		}
+	}
+	return true
+}
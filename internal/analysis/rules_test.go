@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+func TestNameRulesMatchInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(`rules:
+  - name: bank-statement
+    types: [email]
+    metadata:
+      from: '@bank\.com'
+    template: "bank-statement-{date}"
+  - name: ticket
+    text: '(?i)ticket #(?P<ticket>\d+)'
+    template: "ticket-{ticket}-{title|slug}"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadNameRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := extractors.ExtractedFileInfo{
+		Path: "0001.eml", Extension: "eml", DetectedType: "email",
+		Metadata: map[string]string{
+			"from":    "Statements <no-reply@bank.com>",
+			"date":    "Mon, 02 Oct 2023 08:00:00 +0000",
+			"subject": "Your ticket #77 statement",
+		},
+		TextSamples: []extractors.TextSample{{Source: "email-subject", Text: "Your ticket #77 statement", Score: 0.9}},
+	}
+	got, ok := rules.Match(statement, utils.DefaultProfile)
+	if !ok || got.Filename != "bank-statement-2023-10-02" || got.Method != "rule:bank-statement" || got.Confidence != 1 {
+		t.Fatalf("Match = %+v, %v", got, ok)
+	}
+	if !slices.Equal(got.Evidence, []string{"type", "metadata:from"}) {
+		t.Fatalf("evidence = %v", got.Evidence)
+	}
+
+	// The sender no longer matches, so the second rule gets its turn.
+	statement.Metadata["from"] = "friend@example.com"
+	got, ok = rules.Match(statement, utils.DefaultProfile)
+	if !ok || got.Filename != "ticket-77-your-ticket-77-statement" || got.Method != "rule:ticket" {
+		t.Fatalf("Match = %+v, %v", got, ok)
+	}
+	if !slices.Equal(got.Evidence, []string{"text:email-subject"}) {
+		t.Fatalf("evidence = %v", got.Evidence)
+	}
+
+	plain := extractors.ExtractedFileInfo{Path: "a.txt", Extension: "txt", DetectedType: "text"}
+	if _, ok := rules.Match(plain, utils.DefaultProfile); ok {
+		t.Fatal("a file no rule describes matched")
+	}
+	if _, ok := (*NameRules)(nil).Match(plain, utils.DefaultProfile); ok {
+		t.Fatal("nil rules matched")
+	}
+}
+
+func TestLoadNameRulesRejectsBadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	for name, bad := range map[string]string{
+		"no name":       "rules:\n  - types: [pdf]\n    template: \"{date}\"\n",
+		"duplicate":     "rules:\n  - {name: a, types: [pdf], template: \"{date}\"}\n  - {name: a, types: [csv], template: \"{date}\"}\n",
+		"no conditions": "rules:\n  - {name: a, template: \"{date}\"}\n",
+		"bad template":  "rules:\n  - {name: a, types: [pdf], template: \"plain\"}\n",
+		"bad regexp":    "rules:\n  - {name: a, text: \"(\", template: \"{date}\"}\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadNameRules(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: err = %v, want an error naming the file", name, err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package analysis
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+// defaultDateLayout formats {date} and {mtime} when the placeholder gives no
+// layout of its own.
+const defaultDateLayout = "2006-01-02"
+
+// templateAliases lets one placeholder name cover the keys different
+// extractors use for the same thing: office creators, ffprobe artists and
+// email senders are all authors, and email subjects are titles.
+var templateAliases = map[string][]string{
+	"author": {"author", "creator", "artist", "from"},
+	"title":  {"title", "subject"},
+}
+
+// unsafeNameChars cannot appear in a filename on at least one common
+// filesystem.
+var unsafeNameChars = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+
+var templateTypePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+=`)
+
+// NameTemplate builds names from literal text and placeholders such as
+// {slug}, {date:2006-01-02}, {author} or {date|mtime}. Each placeholder
+// lists alternatives separated by |, tried in order until one resolves;
+// a quoted alternative such as "unknown" is used as written.
+type NameTemplate struct {
+	source string
+	parts  []templatePart
+}
+
+// templatePart is a literal when alternatives is empty.
+type templatePart struct {
+	literal      string
+	alternatives []templateKey
+}
+
+type templateKey struct {
+	name    string
+	layout  string
+	literal bool
+}
+
+// ParseNameTemplate parses s, rejecting unbalanced braces, empty
+// placeholders and templates without any placeholder.
+func ParseNameTemplate(s string) (*NameTemplate, error) {
+	t := &NameTemplate{source: s}
+	rest := s
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("name template %q: unmatched }", s)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:open]})
+		}
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] == '{' {
+			return nil, fmt.Errorf("name template %q: unclosed {", s)
+		}
+		part, err := parsePlaceholder(rest[open+1 : open+1+end])
+		if err != nil {
+			return nil, fmt.Errorf("name template %q: %w", s, err)
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+1+end+1:]
+	}
+	for _, part := range t.parts {
+		if len(part.alternatives) > 0 {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("name template %q has no placeholders", s)
+}
+
+// parsePlaceholder parses the inside of {...}. A layout written only on the
+// last alternative, as in {date|mtime:2006}, applies to all of them.
+func parsePlaceholder(s string) (templatePart, error) {
+	var part templatePart
+	for _, alt := range strings.Split(s, "|") {
+		alt = strings.TrimSpace(alt)
+		if len(alt) >= 2 && alt[0] == '"' && alt[len(alt)-1] == '"' {
+			part.alternatives = append(part.alternatives, templateKey{name: alt[1 : len(alt)-1], literal: true})
+			continue
+		}
+		name, layout, _ := strings.Cut(alt, ":")
+		if name == "" {
+			return templatePart{}, fmt.Errorf("empty placeholder {%s}", s)
+		}
+		part.alternatives = append(part.alternatives, templateKey{name: name, layout: layout})
+	}
+	shared := part.alternatives[len(part.alternatives)-1].layout
+	for i := range part.alternatives {
+		if key := &part.alternatives[i]; !key.literal && key.layout == "" {
+			key.layout = shared
+		}
+	}
+	return part, nil
+}
+
+// String returns the template as it was written.
+func (t *NameTemplate) String() string {
+	return t.source
+}
+
+// Render fills the template for info. slug is the descriptive name, already
+// in the profile's style, and other values are put in that style. A
+// placeholder that resolves to nothing is dropped together with the
+// literal before it, or after it when it comes first, so
+// {date}_{author}_{title} without an author gives date_title. Render
+// returns slug when no placeholder resolves.
+func (t *NameTemplate) Render(info extractors.ExtractedFileInfo, slug string, profile utils.NamingProfile) string {
+	values := make([]string, len(t.parts))
+	for i, part := range t.parts {
+		if len(part.alternatives) == 0 {
+			values[i] = part.literal
+			continue
+		}
+		values[i] = resolvePlaceholder(part, info, slug, profile)
+	}
+
+	// kept holds the indexes of the parts that make it into the name.
+	var kept []int
+	resolved, dropNext := 0, false
+	for i, part := range t.parts {
+		drop := dropNext
+		dropNext = false
+		switch {
+		case len(part.alternatives) == 0:
+			if !drop {
+				kept = append(kept, i)
+			}
+		case values[i] != "":
+			kept = append(kept, i)
+			resolved++
+		case len(kept) > 0 && kept[len(kept)-1] == i-1 && len(t.parts[i-1].alternatives) == 0:
+			kept = kept[:len(kept)-1]
+		default:
+			dropNext = true
+		}
+	}
+	if resolved == 0 {
+		return slug
+	}
+
+	var b strings.Builder
+	for _, i := range kept {
+		b.WriteString(values[i])
+	}
+	name := unsafeNameChars.ReplaceAllString(b.String(), "-")
+	name = strings.Trim(utils.TruncateRunes(name, profile.Limit()), "-_. ")
+	if name == "" {
+		return slug
+	}
+	return name
+}
+
+func resolvePlaceholder(part templatePart, info extractors.ExtractedFileInfo, slug string, profile utils.NamingProfile) string {
+	for _, key := range part.alternatives {
+		if key.literal {
+			if value := profile.Format(key.name, time.Time{}); value != "" {
+				return value
+			}
+			continue
+		}
+		if value := resolveKey(key, info, slug, profile); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func resolveKey(key templateKey, info extractors.ExtractedFileInfo, slug string, profile utils.NamingProfile) string {
+	var date time.Time
+	switch strings.ToLower(key.name) {
+	case "slug":
+		return slug
+	case "type":
+		return profile.Format(info.DetectedType, time.Time{})
+	case "ext":
+		return profile.Format(info.SuggestedExtension, time.Time{})
+	case "original":
+		base := filepath.Base(info.Path)
+		return profile.Format(strings.TrimSuffix(base, filepath.Ext(base)), time.Time{})
+	case "date":
+		date, _ = ContentDate(info)
+	case "mtime":
+		if stat, err := os.Stat(info.Path); err == nil {
+			date = stat.ModTime()
+		}
+	default:
+		value := metadataValue(info.Metadata, key.name)
+		if value == "" {
+			return ""
+		}
+		// A layout formats values that are dates; others, such as a topic
+		// before a date fallback, are used as they are.
+		parsed, ok := parseDate(value)
+		if key.layout == "" || !ok {
+			return profile.Format(personName(value), time.Time{})
+		}
+		date = parsed
+	}
+	if date.IsZero() {
+		return ""
+	}
+	layout := key.layout
+	if layout == "" {
+		layout = defaultDateLayout
+	}
+	return date.Format(layout)
+}
+
+// metadataValue looks name up in metadata, first as written, then through
+// templateAliases, then ignoring case, since exiftool writes Artist where
+// ffprobe writes artist.
+func metadataValue(metadata map[string]string, name string) string {
+	keys := append([]string{name}, templateAliases[strings.ToLower(name)]...)
+	for _, key := range keys {
+		if value := strings.TrimSpace(metadata[key]); value != "" {
+			return value
+		}
+	}
+	sorted := make([]string, 0, len(metadata))
+	for key := range metadata {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, want := range keys {
+		for _, key := range sorted {
+			if value := strings.TrimSpace(metadata[key]); value != "" && strings.EqualFold(key, want) {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// personName reduces an email address such as "Jane Doe <jane@example.com>"
+// to the name, or to the part before the @ when there is none.
+func personName(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return value
+	}
+	if addr.Name != "" {
+		return addr.Name
+	}
+	local, _, _ := strings.Cut(addr.Address, "@")
+	return local
+}
+
+// NameTemplates holds a default template under "" and per-type templates
+// keyed by extension, detected subtype or detected type.
+type NameTemplates map[string]*NameTemplate
+
+// ParseNameTemplates parses --name-template values. A value such as
+// "image={date|mtime}_{slug}" applies only to that type; one without a
+// type prefix is the default. Later values replace earlier ones.
+func ParseNameTemplates(specs []string) (NameTemplates, error) {
+	templates := NameTemplates{}
+	for _, spec := range specs {
+		fileType := ""
+		if prefix := templateTypePattern.FindString(spec); prefix != "" {
+			fileType = strings.ToLower(strings.TrimSuffix(prefix, "="))
+			spec = spec[len(prefix):]
+		}
+		t, err := ParseNameTemplate(spec)
+		if err != nil {
+			return nil, err
+		}
+		templates[strings.TrimPrefix(fileType, ".")] = t
+	}
+	return templates, nil
+}
+
+// For returns the template for info, most specific match first, or nil
+// when there is none.
+func (t NameTemplates) For(info extractors.ExtractedFileInfo) *NameTemplate {
+	for _, key := range []string{info.Extension, info.Metadata["detected_subtype"], info.DetectedType} {
+		if key == "" {
+			continue
+		}
+		if tmpl, ok := t[strings.ToLower(key)]; ok {
+			return tmpl
+		}
+	}
+	return t[""]
+}
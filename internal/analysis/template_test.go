@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/extractors"
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+func TestNameTemplateRendersMetadata(t *testing.T) {
+	info := extractors.ExtractedFileInfo{
+		Path:         "inbox/0042.eml",
+		DetectedType: "email",
+		Metadata: map[string]string{
+			"from":    "Jane Doe <jane@example.com>",
+			"date":    "Thu, 14 Sep 2023 09:12:00 -0400",
+			"subject": "Vendor contract renewal",
+		},
+	}
+	for _, tc := range []struct {
+		template string
+		want     string
+	}{
+		{"{date:2006-01-02}_{author}_{title}", "2023-09-14_jane-doe_vendor-contract-renewal"},
+		{"{date:2006}/{slug}", "2023-contract-renewal"},
+		{"{album}_{slug}", "contract-renewal"},
+		{"{artist|\"Unknown Artist\"} - {slug}", "unknown-artist - contract-renewal"},
+		{"{date}_{album}_{slug}", "2023-09-14_contract-renewal"},
+		{"{original}-{ext|type}", "0042-email"},
+		{"{album}{artist}", "contract-renewal"},
+	} {
+		tmpl, err := ParseNameTemplate(tc.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Render(info, "contract-renewal", utils.DefaultProfile); got != tc.want {
+			t.Errorf("%s: Render = %q, want %q", tc.template, got, tc.want)
+		}
+	}
+}
+
+func TestNameTemplateFallsBackToModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.jpg")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := ParseNameTemplate("{DateTimeOriginal|mtime:2006-01}_{Artist}_{slug}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := extractors.ExtractedFileInfo{Path: path, Metadata: map[string]string{"artist": "Ansel Adams"}}
+	profile := utils.NamingProfile{Style: utils.StyleSnake}
+	if got := tmpl.Render(info, "half_dome", profile); got != "2021-06_ansel_adams_half_dome" {
+		t.Fatalf("Render = %q", got)
+	}
+	info.Metadata["DateTimeOriginal"] = "2019:07:04 18:30:00"
+	if got := tmpl.Render(info, "half_dome", profile); got != "2019-07_ansel_adams_half_dome" {
+		t.Fatalf("Render = %q, want the EXIF date", got)
+	}
+}
+
+func TestParseNameTemplatesPicksTheMostSpecificType(t *testing.T) {
+	templates, err := ParseNameTemplates([]string{"{slug}-{type}", "image={mtime}_{slug}", ".mp3={artist}-{title}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		info extractors.ExtractedFileInfo
+		want string
+	}{
+		{extractors.ExtractedFileInfo{Extension: "mp3", DetectedType: "media"}, "{artist}-{title}"},
+		{extractors.ExtractedFileInfo{Extension: "png", DetectedType: "image"}, "{mtime}_{slug}"},
+		{extractors.ExtractedFileInfo{Extension: "txt", DetectedType: "text"}, "{slug}-{type}"},
+	} {
+		if got := templates.For(tc.info); got == nil || got.String() != tc.want {
+			t.Errorf("For(%s) = %v, want %s", tc.info.Extension, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"{slug", "slug}", "{}", "{date|}", "plain-name"} {
+		if _, err := ParseNameTemplates([]string{bad}); err == nil {
+			t.Errorf("ParseNameTemplates(%q) accepted a bad template", bad)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Backend names accepted by --backend and AI_BACKEND.
+const (
+	BackendOpenAI           = "openai"
+	BackendOllama           = "ollama"
+	BackendOpenAICompatible = "openai-compatible"
+	BackendAnthropic        = "anthropic"
+	BackendServer           = "server"
+)
+
+// Backends lists every backend name in the order help text shows them.
+var Backends = []string{BackendOpenAI, BackendOllama, BackendOpenAICompatible, BackendAnthropic, BackendServer}
+
+// BackendConfig holds the settings for one AI backend. Zero values fall back
+// to the backend's defaults, and Temperature is a pointer because 0 is a
+// meaningful setting.
+type BackendConfig struct {
+	BaseURL     string   `yaml:"base_url,omitempty"`
+	APIKeyEnv   string   `yaml:"api_key_env,omitempty"`
+	Model       string   `yaml:"model,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty"`
+}
+
+// APIKey reads the key from APIKeyEnv, falling back to fallback when no
+// variable is configured.
+func (b BackendConfig) APIKey(fallback string) string {
+	if b.APIKeyEnv == "" {
+		return fallback
+	}
+	return os.Getenv(b.APIKeyEnv)
+}
+
+type backendsFile struct {
+	Backends map[string]BackendConfig `yaml:"backends"`
+}
+
+// LoadBackends reads per-backend blocks from a YAML file:
+//
+//	backends:
+//	  openai-compatible:
+//	    base_url: http://localhost:1234/v1
+//	    model: qwen2.5-7b-instruct
+//	    temperature: 0.2
+func LoadBackends(path string) (map[string]BackendConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file backendsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name := range file.Backends {
+		if !ValidBackend(name) {
+			return nil, fmt.Errorf("%s: unknown backend %q", path, name)
+		}
+	}
+	return file.Backends, nil
+}
+
+func ValidBackend(name string) bool {
+	for _, backend := range Backends {
+		if name == backend {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadBackends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	if err := os.WriteFile(path, []byte(`
+backends:
+  openai-compatible:
+    base_url: http://localhost:1234/v1
+    api_key_env: LMSTUDIO_KEY
+    model: qwen2.5-7b-instruct
+    temperature: 0
+    max_tokens: 64
+  ollama:
+    model: llama3.1:8b
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backends, err := LoadBackends(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compat := backends[BackendOpenAICompatible]
+	if compat.BaseURL != "http://localhost:1234/v1" || compat.MaxTokens != 64 {
+		t.Fatalf("openai-compatible = %+v", compat)
+	}
+	if compat.Temperature == nil || *compat.Temperature != 0 {
+		t.Fatalf("temperature = %v, want an explicit 0", compat.Temperature)
+	}
+	t.Setenv("LMSTUDIO_KEY", "lm-key")
+	if got := compat.APIKey("fallback"); got != "lm-key" {
+		t.Fatalf("APIKey = %q, want the value of LMSTUDIO_KEY", got)
+	}
+	if got := backends[BackendOllama].APIKey("fallback"); got != "fallback" {
+		t.Fatalf("APIKey = %q, want fallback without api_key_env", got)
+	}
+}
+
+func TestLoadBackendsRejectsUnknownBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	if err := os.WriteFile(path, []byte("backends:\n  bard:\n    model: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBackends(path); err == nil {
+		t.Fatal("expected an error for an unknown backend")
+	}
+}
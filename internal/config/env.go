@@ -1,23 +1,105 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
-	OpenAIKey string
-	ServerURL string
-	OllaHost  string
-	TikaURL   string
+	// Backend is one of Backends; empty picks Ollama for --local, then
+	// OpenAI when OPENAI_API_KEY is set, then the relay server.
+	Backend string
+	// BackendConfigs holds per-backend overrides keyed by backend name.
+	BackendConfigs map[string]BackendConfig
+
+	// VisionModel names a vision-capable model on the selected backend for
+	// images and scanned pages; empty disables the vision fallback.
+	VisionModel string
+
+	// PromptDir holds prompt templates and few-shot examples that replace
+	// the built-in ones; empty uses the built-in prompts.
+	PromptDir string
+
+	OpenAIKey    string
+	ServerURL    string
+	OllaHost     string
+	TikaURL      string
+	WhisperURL   string
+	WhisperModel string
+
+	// ServerBatchSize groups relay-server AI calls into /v1/batch requests
+	// of up to this many items; 0 or 1 sends one request per file.
+	ServerBatchSize int
+
+	// ServerAPIKey is sent to the relay server in the X-API-Key header.
+	ServerAPIKey string
+
+	// AIAttempts and AITimeout override the AI retry policy when set.
+	AIAttempts int
+	AITimeout  time.Duration
+
+	// CacheDir holds cached AI responses; empty means the user cache dir.
+	// NoCache skips the cache entirely.
+	CacheDir string
+	CacheTTL time.Duration
+	NoCache  bool
 }
 
 func FromEnv() Config {
 
 	openAIKey := os.Getenv("OPENAI_API_KEY")
+	batchSize, _ := strconv.Atoi(os.Getenv("AI_SERVER_BATCH_SIZE"))
+	attempts, _ := strconv.Atoi(os.Getenv("AI_ATTEMPTS"))
+	timeout, _ := time.ParseDuration(os.Getenv("AI_TIMEOUT"))
+	cacheTTL, _ := time.ParseDuration(os.Getenv("AI_CACHE_TTL"))
 	return Config{
-		OpenAIKey: openAIKey,
-		OllaHost:  os.Getenv("OLLAMA_HOST"),
-		ServerURL: os.Getenv("AI_SERVER_URL"),
-		TikaURL:   os.Getenv("TIKA_URL"),
+		Backend:      os.Getenv("AI_BACKEND"),
+		VisionModel:  os.Getenv("AI_VISION_MODEL"),
+		PromptDir:    os.Getenv("AI_PROMPT_DIR"),
+		OpenAIKey:    openAIKey,
+		OllaHost:     os.Getenv("OLLAMA_HOST"),
+		ServerURL:    os.Getenv("AI_SERVER_URL"),
+		TikaURL:      os.Getenv("TIKA_URL"),
+		WhisperURL:   os.Getenv("WHISPER_URL"),
+		WhisperModel: os.Getenv("WHISPER_MODEL"),
+
+		ServerBatchSize: batchSize,
+		ServerAPIKey:    os.Getenv("AI_SERVER_API_KEY"),
+
+		AIAttempts: attempts,
+		AITimeout:  timeout,
+
+		CacheDir: os.Getenv("AI_CACHE_DIR"),
+		CacheTTL: cacheTTL,
+	}
+}
+
+// LogValue keeps secrets out of structured logs; set keys show as "[redacted]".
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("backend", c.Backend),
+		slog.String("prompt_dir", c.PromptDir),
+		slog.String("openai_key", redact(c.OpenAIKey)),
+		slog.String("server_url", c.ServerURL),
+		slog.String("ollama_host", c.OllaHost),
+		slog.String("tika_url", c.TikaURL),
+		slog.String("whisper_url", c.WhisperURL),
+		slog.String("whisper_model", c.WhisperModel),
+		slog.Int("server_batch_size", c.ServerBatchSize),
+		slog.String("server_api_key", redact(c.ServerAPIKey)),
+		slog.Int("ai_attempts", c.AIAttempts),
+		slog.Duration("ai_timeout", c.AITimeout),
+		slog.String("cache_dir", c.CacheDir),
+		slog.Duration("cache_ttl", c.CacheTTL),
+		slog.Bool("no_cache", c.NoCache),
+	)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
 	}
+	return "[redacted]"
 }
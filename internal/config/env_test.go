@@ -0,0 +1,23 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogValueRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	logger.Info("configuration loaded", "config", Config{OpenAIKey: "sk-secret", ServerAPIKey: "relay-secret", TikaURL: "http://tika:9998"})
+
+	out := buf.String()
+	if strings.Contains(out, "sk-secret") || strings.Contains(out, "relay-secret") {
+		t.Fatalf("secret leaked into log: %s", out)
+	}
+	if !strings.Contains(out, `"openai_key":"[redacted]"`) || !strings.Contains(out, "http://tika:9998") {
+		t.Fatalf("unexpected log output: %s", out)
+	}
+}
@@ -48,6 +48,13 @@ func (csvExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
 	}
 	headerText := strings.Join(headers, " ")
 	info.Metadata["headers"] = headerText
+	// The first record often identifies the export, such as an invoice ID.
+	if record, err := reader.Read(); err == nil {
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		info.Metadata["first_row"] = strings.Join(record, ", ")
+	}
 	info.TextSamples = append([]TextSample{{
 		Source: "csv-headers",
 		Text:   headerText,
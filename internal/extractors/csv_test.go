@@ -1,6 +1,7 @@
 package extractors
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -17,3 +18,17 @@ func TestCSVExtractorUsesHeadersFromWrongExtension(t *testing.T) {
 		t.Fatalf("missing csv header sample: %+v", info.TextSamples)
 	}
 }
+
+func TestCSVExtractorRecordsFirstRow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(path, []byte("invoice_id,customer,total\nINV-1042, Acme Corp ,99.50\nINV-1043,Globex,12.00\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := csvExtractor{}.ExtractInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Metadata["first_row"]; got != "INV-1042, Acme Corp, 99.50" {
+		t.Fatalf("first_row = %q", got)
+	}
+}
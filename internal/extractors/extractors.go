@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/djblackett/bootdev-hackathon/internal/filetype"
+	"github.com/djblackett/bootdev-hackathon/internal/language"
 	"github.com/djblackett/bootdev-hackathon/internal/tika"
 )
 
@@ -63,67 +64,77 @@ func Walk(dir string, types map[string]struct{}, fn func(string, string) error)
 // supported file. Existing extractors can opt into richer metadata by
 // implementing InfoExtractor; otherwise the plain extracted content is wrapped.
 func WalkInfo(dir string, types map[string]struct{}, fn func(ExtractedFileInfo) error) error {
+	ctx := context.Background()
+	return WalkFiles(ctx, dir, types, func(path string) error {
+		info, err := ExtractFileInfo(ctx, path)
+		if err != nil {
+			return err
+		}
+		return fn(info)
+	})
+}
+
+// WalkFiles walks over dir and calls fn with each file that an extractor or
+// the Tika fallback can handle, without extracting it. Walking stops early
+// when ctx is cancelled.
+func WalkFiles(ctx context.Context, dir string, types map[string]struct{}, fn func(string) error) error {
 	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		detection := filetype.Detect(path)
 		if !allowedType(types, detection.Extension, detection.Type, detection.Subtype) {
 			return nil
 		}
-
-		for _, ex := range registered {
-			if !extractorCanHandle(ex, path, detection.Type, detection.Subtype) {
-				continue
-			}
-
-			if infoEx, ok := ex.(InfoExtractor); ok {
-				info, err := infoEx.ExtractInfo(path)
-				if err != nil {
-					return err
-				}
-				applyDetection(&info, detection)
-				if shouldTryTikaFallback(info) {
-					info = mergeTikaFallback(info)
-				}
-				return fn(info)
-			}
-
-			content, err := ex.Extract(path)
-			if err != nil {
-				return err
-			}
-			info := NewExtractedFileInfo(path, detection.Type, content)
-			applyDetection(&info, detection)
-			if shouldTryTikaFallback(info) {
-				info = mergeTikaFallback(info)
-			}
-			return fn(info)
-		}
-		if tikaClient != nil {
-			info := NewExtractedFileInfo(path, detection.Type, "")
-			applyDetection(&info, detection)
-			return fn(mergeTikaFallback(info))
+		if !canExtract(path, detection) {
+			return nil
 		}
-		return nil
+		return fn(path)
 	})
 }
 
 func ExtractInfoForPath(path string) (ExtractedFileInfo, error) {
+	return ExtractFileInfo(context.Background(), path)
+}
+
+// ExtractFileInfo detects the type of path and runs the first matching
+// extractor, falling back to Tika when configured. ctx bounds Tika requests
+// and the external tools that extractors run.
+// The language of the extracted text, when it can be told, is recorded as
+// Metadata["language"].
+func ExtractFileInfo(ctx context.Context, path string) (ExtractedFileInfo, error) {
+	info, err := extractFileInfo(ctx, path)
+	if err != nil {
+		return ExtractedFileInfo{}, err
+	}
+	applyLanguage(&info)
+	return info, nil
+}
+
+func extractFileInfo(ctx context.Context, path string) (ExtractedFileInfo, error) {
 	detection := filetype.Detect(path)
 	for _, ex := range registered {
 		if !extractorCanHandle(ex, path, detection.Type, detection.Subtype) {
 			continue
 		}
 		if infoEx, ok := ex.(InfoExtractor); ok {
-			info, err := infoEx.ExtractInfo(path)
+			var info ExtractedFileInfo
+			var err error
+			if ctxEx, ok := ex.(ContextInfoExtractor); ok {
+				info, err = ctxEx.ExtractInfoContext(ctx, path)
+			} else {
+				info, err = infoEx.ExtractInfo(path)
+			}
 			if err != nil {
 				return ExtractedFileInfo{}, err
 			}
 			applyDetection(&info, detection)
 			if shouldTryTikaFallback(info) {
-				info = mergeTikaFallback(info)
+				info = mergeTikaFallback(ctx, info)
 			}
 			return info, nil
 		}
@@ -134,18 +145,30 @@ func ExtractInfoForPath(path string) (ExtractedFileInfo, error) {
 		info := NewExtractedFileInfo(path, detection.Type, content)
 		applyDetection(&info, detection)
 		if shouldTryTikaFallback(info) {
-			info = mergeTikaFallback(info)
+			info = mergeTikaFallback(ctx, info)
 		}
 		return info, nil
 	}
 	if tikaClient != nil {
 		info := NewExtractedFileInfo(path, detection.Type, "")
 		applyDetection(&info, detection)
-		return mergeTikaFallback(info), nil
+		return mergeTikaFallback(ctx, info), nil
 	}
 	return ExtractedFileInfo{}, fmt.Errorf("no extractor for %s", path)
 }
 
+func canExtract(path string, detection filetype.Detection) bool {
+	if tikaClient != nil {
+		return true
+	}
+	for _, ex := range registered {
+		if extractorCanHandle(ex, path, detection.Type, detection.Subtype) {
+			return true
+		}
+	}
+	return false
+}
+
 func allowedType(types map[string]struct{}, values ...string) bool {
 	for _, value := range values {
 		if value == "" {
@@ -184,16 +207,42 @@ func applyDetection(info *ExtractedFileInfo, detection filetype.Detection) {
 	}
 }
 
+// languageSampleBytes is about as much text as language.Detect reads.
+const languageSampleBytes = 16 << 10
+
+// applyLanguage detects the language of info's text samples, unless an
+// extractor already recorded one.
+func applyLanguage(info *ExtractedFileInfo) {
+	if info.Metadata["language"] != "" {
+		return
+	}
+	var text strings.Builder
+	for _, sample := range info.TextSamples {
+		// Detect reads only the start; skip copying long bodies whole.
+		if text.Len() > languageSampleBytes {
+			break
+		}
+		text.WriteString(sample.Text)
+		text.WriteByte('\n')
+	}
+	if code := language.Detect(text.String()); code != "" {
+		if info.Metadata == nil {
+			info.Metadata = map[string]string{}
+		}
+		info.Metadata["language"] = code
+	}
+}
+
 func shouldTryTikaFallback(info ExtractedFileInfo) bool {
 	return tikaClient != nil && strings.TrimSpace(info.RawContent) == "" && len(info.TextSamples) == 0
 }
 
-func mergeTikaFallback(info ExtractedFileInfo) ExtractedFileInfo {
+func mergeTikaFallback(ctx context.Context, info ExtractedFileInfo) ExtractedFileInfo {
 	if tikaClient == nil {
 		return info
 	}
 
-	extracted, err := tikaClient.ExtractFile(context.Background(), info.Path)
+	extracted, err := tikaClient.ExtractFile(ctx, info.Path)
 	if err != nil {
 		info.Warnings = append(info.Warnings, "tika extraction failed: "+err.Error())
 		return info
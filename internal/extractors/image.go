@@ -2,6 +2,7 @@ package extractors
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -36,7 +37,11 @@ func (imageExtractor) Extract(path string) (string, error) {
 	return info.RawContent, nil
 }
 
-func (imageExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
+func (i imageExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
+	return i.ExtractInfoContext(context.Background(), path)
+}
+
+func (imageExtractor) ExtractInfoContext(ctx context.Context, path string) (ExtractedFileInfo, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return ExtractedFileInfo{}, err
@@ -83,6 +88,9 @@ func (imageExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
 		}
 	}
 
+	text, warning := ocrImageText(ctx, path)
+	applyOCR(&info, text, warning)
+
 	return info, nil
 }
 
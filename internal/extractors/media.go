@@ -1,11 +1,13 @@
 package extractors
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -30,7 +32,11 @@ func (mediaExtractor) Extract(path string) (string, error) {
 	return info.RawContent, nil
 }
 
-func (mediaExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
+func (m mediaExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
+	return m.ExtractInfoContext(context.Background(), path)
+}
+
+func (mediaExtractor) ExtractInfoContext(ctx context.Context, path string) (ExtractedFileInfo, error) {
 	info := NewExtractedFileInfo(path, "media", "")
 	info.SuggestedExtension = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
 
@@ -52,6 +58,17 @@ func (mediaExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
 		})
 	}
 
+	if title == "" && transcriber != nil {
+		transcript, warning := mediaTranscript(ctx, path)
+		if warning != "" {
+			info.Warnings = append(info.Warnings, warning)
+		} else if samples := transcriptSamples(transcript); len(samples) > 0 {
+			info.RawContent = transcript
+			info.Metadata["transcript_seconds"] = strconv.Itoa(transcribeSeconds)
+			info.TextSamples = append(info.TextSamples, samples...)
+		}
+	}
+
 	dateText := firstNonEmpty(meta["creation_time"], meta["date"])
 	if dateText != "" {
 		info.TextSamples = append(info.TextSamples, TextSample{
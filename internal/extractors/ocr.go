@@ -1,7 +1,157 @@
 package extractors
 
-func GetTextFromImagePDF() string {
-	// This function is a placeholder for future implementation.
-	// It should return text extracted from an image PDF.
-	return "Text extracted from image PDF"
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ocrMaxPDFPages limits how many rasterized pages are sent to tesseract for a
+// scanned PDF. The first pages carry the title in almost every document.
+const ocrMaxPDFPages = 2
+
+// ocrImageText runs tesseract over an image and returns the recognized text.
+// A non-empty warning means OCR was skipped and the text should be ignored.
+func ocrImageText(ctx context.Context, path string) (string, string) {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return "", "tesseract not available; OCR skipped"
+	}
+
+	out, err := exec.CommandContext(ctx, "tesseract", path, "stdout").Output()
+	if err != nil {
+		return "", "tesseract failed; OCR skipped"
+	}
+	return string(out), ""
+}
+
+// ocrPDFText rasterizes the first pages of a PDF with pdftoppm and runs
+// tesseract over each page image.
+func ocrPDFText(ctx context.Context, path string) (string, string) {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return "", "tesseract not available; OCR skipped"
+	}
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		return "", "pdftoppm not available; PDF OCR skipped"
+	}
+
+	dir, err := os.MkdirTemp("", "ocr-pdf-")
+	if err != nil {
+		return "", "could not create OCR temp dir; PDF OCR skipped"
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "page")
+	last := strconv.Itoa(ocrMaxPDFPages)
+	if err := exec.CommandContext(ctx, "pdftoppm", "-r", "150", "-png", "-f", "1", "-l", last, path, prefix).Run(); err != nil {
+		return "", "pdftoppm failed; PDF OCR skipped"
+	}
+
+	pages, _ := filepath.Glob(prefix + "*.png")
+	if len(pages) == 0 {
+		return "", "pdftoppm produced no pages; PDF OCR skipped"
+	}
+	sort.Strings(pages)
+
+	var parts []string
+	for _, page := range pages {
+		text, warning := ocrImageText(ctx, page)
+		if warning != "" {
+			return "", warning
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n"), ""
+}
+
+// applyOCR adds OCR evidence to info. Recognized text that does not contain
+// enough readable words is dropped so photos do not produce noise samples.
+func applyOCR(info *ExtractedFileInfo, text, warning string) {
+	if warning != "" {
+		info.Warnings = append(info.Warnings, warning)
+		return
+	}
+	samples := ocrSamples(text)
+	if len(samples) == 0 {
+		return
+	}
+	info.Metadata["ocr"] = "tesseract"
+	info.TextSamples = append(samples, info.TextSamples...)
+	text = strings.TrimSpace(text)
+	if strings.TrimSpace(info.RawContent) == "" {
+		info.RawContent = text
+	} else {
+		info.RawContent = info.RawContent + "\n\n" + text
+	}
+}
+
+func ocrSamples(text string) []TextSample {
+	lines := []string{}
+	for _, line := range nonEmptyTextLines(text) {
+		line = strings.Join(strings.Fields(line), " ")
+		if readableOCRLine(line) {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	samples := []TextSample{}
+	body := lines
+	if i, heading := ocrHeading(lines); heading != "" {
+		samples = append(samples, TextSample{
+			Source: "ocr-heading",
+			Text:   heading,
+			Score:  0.8,
+		})
+		body = lines[i+1:]
+	}
+	if first := firstSubstantiveTextLine(body); first != "" {
+		samples = append(samples, TextSample{
+			Source: "ocr-first-text",
+			Text:   first,
+			Score:  0.66,
+		})
+	}
+	return samples
+}
+
+// ocrHeading returns the first short line near the top of the page, which is
+// usually the document title on scanned letters, forms and reports.
+func ocrHeading(lines []string) (int, string) {
+	for i, line := range lines {
+		if i >= 5 {
+			break
+		}
+		words := wordTokenPattern.FindAllString(line, -1)
+		if len(words) >= 2 && len(words) <= 10 && len(line) <= 80 {
+			return i, strings.Trim(line, " .:-")
+		}
+	}
+	return 0, ""
+}
+
+func readableOCRLine(line string) bool {
+	words := wordTokenPattern.FindAllString(line, -1)
+	if len(words) == 0 || looksRandomMediaName(line) {
+		return false
+	}
+	letters := 0
+	symbols := 0
+	for _, r := range line {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			letters++
+		case r == ' ' || (r >= '0' && r <= '9'):
+		default:
+			symbols++
+		}
+	}
+	return letters >= 3 && symbols*3 < letters
 }
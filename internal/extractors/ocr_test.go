@@ -0,0 +1,135 @@
+package extractors
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestOCRImageTextUnavailable(t *testing.T) {
+	t.Setenv("PATH", "")
+
+	_, warning := ocrImageText(context.Background(), "anything.png")
+
+	if warning != "tesseract not available; OCR skipped" {
+		t.Fatalf("warning = %q", warning)
+	}
+}
+
+func TestOCRPDFTextRequiresPdftoppm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake tesseract is POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "tesseract", "#!/bin/sh\nprintf 'text'\n")
+	t.Setenv("PATH", dir)
+
+	_, warning := ocrPDFText(context.Background(), "scan.pdf")
+
+	if warning != "pdftoppm not available; PDF OCR skipped" {
+		t.Fatalf("warning = %q", warning)
+	}
+}
+
+func TestOCRPDFTextJoinsRasterizedPages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake tools are POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "pdftoppm", "#!/bin/sh\nfor last; do :; done\n: > \"$last-1.png\"\n: > \"$last-2.png\"\n")
+	writeFakeTool(t, dir, "tesseract", "#!/bin/sh\ncase \"$1\" in\n*-1.png) printf 'Lease Agreement\\n' ;;\n*) printf 'Signatures\\n' ;;\nesac\n")
+	t.Setenv("PATH", dir)
+
+	got, warning := ocrPDFText(context.Background(), "scan.pdf")
+
+	if warning != "" {
+		t.Fatalf("warning = %q", warning)
+	}
+	if got != "Lease Agreement\n\nSignatures" {
+		t.Fatalf("text = %q", got)
+	}
+}
+
+func TestOCRImageTextStopsWhenCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake tesseract is POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "tesseract", "#!/bin/sh\nprintf 'text'\n")
+	t.Setenv("PATH", dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, warning := ocrImageText(ctx, "anything.png")
+
+	if warning != "tesseract failed; OCR skipped" {
+		t.Fatalf("warning = %q", warning)
+	}
+}
+
+func TestOCRSamplesUseHeadingAndFirstText(t *testing.T) {
+	text := "~~ |\nResidential Lease Agreement\nThis lease is made between the landlord and the tenant for the property at 12 Elm Street.\n"
+
+	samples := ocrSamples(text)
+
+	info := ExtractedFileInfo{TextSamples: samples}
+	if !hasSample(info, "ocr-heading", "Residential Lease Agreement") {
+		t.Fatalf("missing heading sample: %+v", samples)
+	}
+	if !hasSample(info, "ocr-first-text", "This lease is made between the landlord and the tenant for the property at 12 Elm Street") {
+		t.Fatalf("missing first text sample: %+v", samples)
+	}
+}
+
+func TestOCRSamplesDropNoise(t *testing.T) {
+	if samples := ocrSamples("~~ |\n,;: ^\nxqzprtnmlkwv\n"); len(samples) != 0 {
+		t.Fatalf("samples = %+v, want none", samples)
+	}
+}
+
+func TestImageExtractorAddsOCRSamples(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake tesseract is POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "tesseract", "#!/bin/sh\nprintf 'Quarterly Sales Dashboard\\nRevenue grew in every region compared to last quarter\\n'\n")
+	t.Setenv("PATH", dir)
+
+	path := filepath.Join(t.TempDir(), "screenshot-0001.png")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(out, image.NewRGBA(image.Rect(0, 0, 2, 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := imageExtractor{}.ExtractInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasSample(info, "ocr-heading", "Quarterly Sales Dashboard") {
+		t.Fatalf("missing OCR heading sample: %+v", info.TextSamples)
+	}
+	if info.Metadata["ocr"] != "tesseract" {
+		t.Fatalf("ocr metadata = %q", info.Metadata["ocr"])
+	}
+}
+
+func writeFakeTool(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -147,7 +147,7 @@ func officeCoreMetadata(files []*zip.File) map[string]string {
 			value := strings.TrimSpace(string(t))
 			if value != "" {
 				switch current {
-				case "title", "subject", "creator", "description", "keywords":
+				case "title", "subject", "creator", "description", "keywords", "created", "modified":
 					meta[current] = value
 				}
 			}
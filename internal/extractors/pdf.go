@@ -2,6 +2,7 @@ package extractors
 
 import (
 	"bytes"
+	"context"
 	"strings"
 
 	"github.com/ledongthuc/pdf"
@@ -33,7 +34,11 @@ func (pdfExtractor) Extract(path string) (string, error) {
 	return content, nil
 }
 
-func (pdfExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
+func (p pdfExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
+	return p.ExtractInfoContext(context.Background(), path)
+}
+
+func (pdfExtractor) ExtractInfoContext(ctx context.Context, path string) (ExtractedFileInfo, error) {
 	content, err := pdfExtractor{}.Extract(path)
 	if err != nil {
 		return ExtractedFileInfo{}, err
@@ -54,6 +59,13 @@ func (pdfExtractor) ExtractInfo(path string) (ExtractedFileInfo, error) {
 		break
 	}
 
+	if strings.TrimSpace(content) == "" {
+		// No text layer: the pages are pictures, which vision models can read.
+		info.Metadata["scanned"] = "true"
+		text, warning := ocrPDFText(ctx, path)
+		applyOCR(&info, text, warning)
+	}
+
 	return info, nil
 }
 
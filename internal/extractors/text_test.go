@@ -37,3 +37,29 @@ func TestTextExtractorRejectsRandomShortTextNote(t *testing.T) {
 		t.Fatalf("random text should not create short text note: %+v", info.TextSamples)
 	}
 }
+
+func TestExtractFileInfoRecordsLanguage(t *testing.T) {
+	dir := t.TempDir()
+	german := filepath.Join(dir, "rechnung.txt")
+	if err := os.WriteFile(german, []byte("Die Rechnung für den Monat März ist im Anhang, und der Betrag wird mit der nächsten Zahlung fällig.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ExtractInfoForPath(german)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Metadata["language"]; got != "de" {
+		t.Fatalf("language = %q, want de", got)
+	}
+
+	short := filepath.Join(dir, "id.txt")
+	if err := os.WriteFile(short, []byte("INV-1042\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = ExtractInfoForPath(short); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := info.Metadata["language"]; ok {
+		t.Fatalf("language = %q for text too short to tell", got)
+	}
+}
@@ -0,0 +1,111 @@
+package extractors
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ThumbnailMaxSide is the longest edge of thumbnails sent to vision models:
+// enough to read a heading or recognize a subject, small enough to keep
+// requests cheap.
+const ThumbnailMaxSide = 768
+
+// WantsThumbnail reports whether info is a picture a vision model could
+// name: an image, or a PDF without a text layer.
+func WantsThumbnail(info ExtractedFileInfo) bool {
+	return info.DetectedType == "image" || info.Metadata["scanned"] == "true"
+}
+
+// Thumbnail returns a JPEG of info's image, or of the first page of a scanned
+// PDF, scaled so its longest side is at most maxSide pixels. Transparent
+// areas are flattened onto white.
+func Thumbnail(info ExtractedFileInfo, maxSide int) ([]byte, error) {
+	var (
+		img image.Image
+		err error
+	)
+	if info.DetectedType == "pdf" {
+		img, err = renderPDFPage(info.Path, maxSide)
+	} else {
+		img, err = decodeImageFile(info.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscale(img, maxSide), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// renderPDFPage rasterizes page 1 with pdftoppm, already scaled to maxSide.
+func renderPDFPage(path string, maxSide int) (image.Image, error) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		return nil, fmt.Errorf("pdftoppm not available")
+	}
+	dir, err := os.MkdirTemp("", "thumb-pdf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "page")
+	if err := exec.Command("pdftoppm", "-jpeg", "-scale-to", strconv.Itoa(maxSide), "-f", "1", "-l", "1", "-singlefile", path, prefix).Run(); err != nil {
+		return nil, fmt.Errorf("pdftoppm failed: %w", err)
+	}
+	return decodeImageFile(prefix + ".jpg")
+}
+
+// downscale averages each block of source pixels into one output pixel,
+// which keeps small text more legible than nearest-neighbour sampling.
+func downscale(src image.Image, maxSide int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := min(1, float64(maxSide)/float64(max(w, h)))
+	dw, dh := max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+
+	dst := image.NewRGBA64(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// Colors are premultiplied, so adding the missing alpha to each
+			// channel composites the pixel over white.
+			white := 0xffff - a/n
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r/n + white),
+				G: uint16(g/n + white),
+				B: uint16(bl/n + white),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
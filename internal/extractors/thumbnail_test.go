@@ -0,0 +1,66 @@
+package extractors
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbnailDownscalesAndFlattensTransparency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "screenshot.png")
+	img := image.NewNRGBA(image.Rect(0, 0, 2000, 1000))
+	for y := 0; y < 1000; y++ {
+		for x := 0; x < 1000; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Thumbnail(NewExtractedFileInfo(path, "image", ""), 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := thumb.Bounds().Size(); got != image.Pt(400, 200) {
+		t.Fatalf("thumbnail size = %v, want 400x200", got)
+	}
+	// The right half was fully transparent and should come out white.
+	if r, g, b, _ := thumb.At(350, 100).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Fatalf("transparent area = %d,%d,%d, want white", r>>8, g>>8, b>>8)
+	}
+	if r, g, _, _ := thumb.At(50, 100).RGBA(); r>>8 < 180 || g>>8 > 40 {
+		t.Fatalf("opaque area = %d,%d, want red", r>>8, g>>8)
+	}
+}
+
+func TestWantsThumbnail(t *testing.T) {
+	scanned := NewExtractedFileInfo("scan.pdf", "pdf", "")
+	scanned.Metadata["scanned"] = "true"
+	for _, tc := range []struct {
+		info ExtractedFileInfo
+		want bool
+	}{
+		{NewExtractedFileInfo("photo.jpg", "image", ""), true},
+		{scanned, true},
+		{NewExtractedFileInfo("report.pdf", "pdf", "Quarterly report"), false},
+		{NewExtractedFileInfo("notes.txt", "txt", "notes"), false},
+	} {
+		if got := WantsThumbnail(tc.info); got != tc.want {
+			t.Fatalf("WantsThumbnail(%s) = %v, want %v", tc.info.Path, got, tc.want)
+		}
+	}
+}
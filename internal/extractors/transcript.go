@@ -0,0 +1,92 @@
+package extractors
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/djblackett/bootdev-hackathon/internal/ai"
+)
+
+const defaultTranscribeSeconds = 60
+
+var transcriber ai.Transcriber
+var transcribeSeconds = defaultTranscribeSeconds
+
+// ConfigureTranscriber enables speech transcription for media files. A nil
+// transcriber disables it. Only the first seconds of audio are transcribed.
+func ConfigureTranscriber(t ai.Transcriber, seconds int) {
+	transcriber = t
+	if seconds <= 0 {
+		seconds = defaultTranscribeSeconds
+	}
+	transcribeSeconds = seconds
+}
+
+func mediaTranscript(ctx context.Context, path string) (string, string) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return "", "ffmpeg not available; transcription skipped"
+	}
+
+	dir, err := os.MkdirTemp("", "transcript-")
+	if err != nil {
+		return "", "could not create transcription temp dir; transcription skipped"
+	}
+	defer os.RemoveAll(dir)
+
+	// whisper.cpp only accepts 16 kHz mono WAV, and a short clip keeps API
+	// uploads small.
+	clip := filepath.Join(dir, "clip.wav")
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "quiet", "-y", "-i", path, "-t", strconv.Itoa(transcribeSeconds), "-vn", "-ar", "16000", "-ac", "1", clip)
+	if err := cmd.Run(); err != nil {
+		return "", "ffmpeg failed; transcription skipped"
+	}
+
+	text, err := transcriber.Transcribe(ctx, clip)
+	if err != nil {
+		return "", "transcription failed: " + err.Error()
+	}
+	return strings.TrimSpace(text), ""
+}
+
+func transcriptSamples(text string) []TextSample {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return nil
+	}
+	for _, sentence := range transcriptSentences(text) {
+		if isLowSignalTextLine(sentence) {
+			continue
+		}
+		if len(wordTokenPattern.FindAllString(sentence, -1)) < 3 {
+			continue
+		}
+		return []TextSample{{
+			Source: "media-transcript",
+			Text:   sentence,
+			Score:  0.78,
+		}}
+	}
+	return nil
+}
+
+func transcriptSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		switch r {
+		case '.', '!', '?':
+			if sentence := strings.TrimSpace(text[start:i]); sentence != "" {
+				sentences = append(sentences, sentence)
+			}
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
@@ -0,0 +1,112 @@
+package extractors
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type fakeTranscriber struct {
+	text string
+	err  error
+}
+
+func (f fakeTranscriber) Transcribe(context.Context, string) (string, error) { return f.text, f.err }
+
+func TestTranscriptSamplesSkipGreetings(t *testing.T) {
+	samples := transcriptSamples("Hi everyone. Okay. Let's review the quarterly budget plan for the Denver office. Next item.")
+
+	info := ExtractedFileInfo{TextSamples: samples}
+	if !hasSample(info, "media-transcript", "Let's review the quarterly budget plan for the Denver office") {
+		t.Fatalf("samples = %+v", samples)
+	}
+}
+
+func TestMediaTranscriptWarnsWithoutFFmpeg(t *testing.T) {
+	t.Setenv("PATH", "")
+	ConfigureTranscriber(fakeTranscriber{text: "unused"}, 30)
+	defer ConfigureTranscriber(nil, 0)
+
+	_, warning := mediaTranscript(context.Background(), "memo.m4a")
+
+	if warning != "ffmpeg not available; transcription skipped" {
+		t.Fatalf("warning = %q", warning)
+	}
+}
+
+func TestMediaTranscriptStopsWhenCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake ffmpeg is POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "ffmpeg", "#!/bin/sh\nfor last; do :; done\n: > \"$last\"\n")
+	t.Setenv("PATH", dir)
+	ConfigureTranscriber(fakeTranscriber{text: "unused"}, 30)
+	defer ConfigureTranscriber(nil, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, warning := mediaTranscript(ctx, "memo.m4a")
+
+	if warning != "ffmpeg failed; transcription skipped" {
+		t.Fatalf("warning = %q", warning)
+	}
+}
+
+func TestMediaExtractorAddsTranscriptWhenTagsMissing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake ffmpeg is POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "ffmpeg", "#!/bin/sh\nfor last; do :; done\n: > \"$last\"\n")
+	t.Setenv("PATH", dir)
+	ConfigureTranscriber(fakeTranscriber{text: "Welcome to the onboarding call for new support engineers."}, 30)
+	defer ConfigureTranscriber(nil, 0)
+
+	path := filepath.Join(t.TempDir(), "2026-01-22_00-59-57.m4a")
+	if err := os.WriteFile(path, []byte{0x00, 0x01}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := mediaExtractor{}.ExtractInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasSample(info, "media-transcript", "Welcome to the onboarding call for new support engineers") {
+		t.Fatalf("missing transcript sample: %+v", info.TextSamples)
+	}
+	if info.Metadata["transcript_seconds"] != "30" {
+		t.Fatalf("transcript_seconds = %q", info.Metadata["transcript_seconds"])
+	}
+}
+
+func TestMediaExtractorWarnsWhenTranscriptionFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake ffmpeg is POSIX-only")
+	}
+
+	dir := t.TempDir()
+	writeFakeTool(t, dir, "ffmpeg", "#!/bin/sh\nfor last; do :; done\n: > \"$last\"\n")
+	t.Setenv("PATH", dir)
+	ConfigureTranscriber(fakeTranscriber{err: errors.New("whisper.cpp not available")}, 30)
+	defer ConfigureTranscriber(nil, 0)
+
+	path := filepath.Join(t.TempDir(), "memo.m4a")
+	if err := os.WriteFile(path, []byte{0x00, 0x01}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := mediaExtractor{}.ExtractInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsWarning(info.Warnings, "transcription failed: whisper.cpp not available") {
+		t.Fatalf("warnings = %+v", info.Warnings)
+	}
+}
@@ -1,6 +1,7 @@
 package extractors
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 )
@@ -27,6 +28,13 @@ type InfoExtractor interface {
 	ExtractInfo(path string) (ExtractedFileInfo, error)
 }
 
+// ContextInfoExtractor is an InfoExtractor that runs external tools, which
+// stop when ctx is cancelled.
+type ContextInfoExtractor interface {
+	InfoExtractor
+	ExtractInfoContext(ctx context.Context, path string) (ExtractedFileInfo, error)
+}
+
 type TypeExtractor interface {
 	CanHandleType(detectedType string) bool
 }
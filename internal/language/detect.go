@@ -0,0 +1,147 @@
+// Package language guesses the natural language of extracted text and holds
+// the stop words naming skips in each language it knows.
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sampleRunes bounds how much text Detect reads.
+const sampleRunes = 4000
+
+// minStopWordHits is how many stop words text in a Latin-script language
+// must contain before Detect names the language.
+const minStopWordHits = 3
+
+// names are the English names of the languages Detect returns, used in AI
+// prompts.
+var names = map[string]string{
+	"ar": "Arabic", "de": "German", "el": "Greek", "en": "English", "es": "Spanish",
+	"fr": "French", "he": "Hebrew", "hi": "Hindi", "it": "Italian", "ja": "Japanese",
+	"ko": "Korean", "nl": "Dutch", "pt": "Portuguese", "ru": "Russian", "th": "Thai",
+	"uk": "Ukrainian", "zh": "Chinese",
+}
+
+// scripts name the language of text written mostly in a script only one
+// of the known languages uses. Han, Cyrillic and Latin need a closer look.
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// Detect returns the ISO 639-1 code of the language text is written in, or
+// "" when there is too little text to tell. Languages with a script of
+// their own are told by script; Japanese by its kana, which Chinese lacks;
+// Ukrainian by the letters Russian lacks; and Latin-script languages by
+// which language's stop words appear most.
+func Detect(text string) string {
+	counts := map[string]int{}
+	var kana, han, cyrillic, ukrainian, latin, letters int
+	n := 0
+	for _, r := range text {
+		if n++; n > sampleRunes {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case r < unicode.MaxASCII || unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("ґєіїҐЄІЇ", r) {
+				ukrainian++
+			}
+		default:
+			for _, s := range scripts {
+				if unicode.Is(s.table, r) {
+					counts[s.code]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	best, bestCount := "", 0
+	for code, count := range counts {
+		if count > bestCount || count == bestCount && code < best {
+			best, bestCount = code, count
+		}
+	}
+	switch {
+	case kana+han > bestCount && kana+han > cyrillic && kana+han > latin:
+		if kana > 0 {
+			return "ja"
+		}
+		return "zh"
+	case cyrillic > bestCount && cyrillic > latin:
+		if ukrainian > 0 {
+			return "uk"
+		}
+		return "ru"
+	case bestCount > latin:
+		return best
+	}
+	return detectByStopWords(text)
+}
+
+// detectByStopWords picks the language whose stop words text uses most.
+func detectByStopWords(text string) string {
+	hits := map[string]int{}
+	n := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		if n++; n > sampleRunes/4 {
+			break
+		}
+		for code, words := range stopWords {
+			if _, ok := words[word]; ok {
+				hits[code]++
+			}
+		}
+	}
+	best, bestHits := "", 0
+	for code, count := range hits {
+		if count > bestHits || count == bestHits && code < best {
+			best, bestHits = code, count
+		}
+	}
+	if bestHits < minStopWordHits {
+		return ""
+	}
+	return best
+}
+
+// Name returns the English name of the language with ISO 639-1 code, or
+// code itself when it is not one Detect returns.
+func Name(code string) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return code
+}
+
+// IsStopWord reports whether word, in lower case, is a stop word in the
+// language with ISO 639-1 code.
+func IsStopWord(code, word string) bool {
+	_, ok := stopWords[code][word]
+	return ok
+}
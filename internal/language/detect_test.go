@@ -0,0 +1,37 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{"The quarterly budget review is attached, and the totals for the north region are in the second tab.", "en"},
+		{"Die Rechnung für den Monat März ist im Anhang, und der Betrag wird mit der nächsten Zahlung fällig.", "de"},
+		{"La facture du mois de mars est jointe et le montant sera payé avec le prochain virement.", "fr"},
+		{"La factura del mes de marzo está adjunta y el importe se pagará con la próxima transferencia.", "es"},
+		{"De factuur voor de maand maart is bijgevoegd en het bedrag wordt met de volgende betaling voldaan.", "nl"},
+		{"四半期の予算報告書です。北部地域の合計は二番目のタブにあります。", "ja"},
+		{"季度预算报告，北部地区的总数在第二个标签页。", "zh"},
+		{"Квартальный отчёт о бюджете приложен, итоги по северному региону во второй вкладке.", "ru"},
+		{"Квартальний звіт про бюджет додано, підсумки по північному регіону в другій вкладці.", "uk"},
+		{"분기별 예산 검토 보고서입니다.", "ko"},
+		{"Τριμηνιαία αναθεώρηση προϋπολογισμού.", "el"},
+		{"invoice 1042", ""},
+		{"", ""},
+	} {
+		if got := Detect(tc.text); got != tc.want {
+			t.Errorf("Detect(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestStopWordsAndNames(t *testing.T) {
+	if !IsStopWord("de", "und") || !IsStopWord("fr", "les") || IsStopWord("en", "und") || IsStopWord("xx", "the") {
+		t.Fatal("IsStopWord used the wrong lists")
+	}
+	if Name("de") != "German" || Name("xx") != "xx" {
+		t.Fatalf("Name = %q, %q", Name("de"), Name("xx"))
+	}
+}
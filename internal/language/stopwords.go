@@ -0,0 +1,46 @@
+package language
+
+import "strings"
+
+// stopWords are the most common function words of each language: articles,
+// pronouns, prepositions, conjunctions and auxiliary verbs. They tell
+// Latin-script languages apart and carry no meaning in a filename.
+var stopWords = map[string]map[string]struct{}{
+	"en": wordSet(`a about after all also an and any are as at be because been but by can could
+		did do does for from had has have he her him his how i if in into is it its me my no not
+		of on one or our out she so than that the their them then there these they this those to
+		up us was we were what when where which while who will with would you your`),
+	"de": wordSet(`aber als am an auch auf aus bei bin bis bist da damit dann das dass dem den
+		denn der des die dies diese dieser dir doch du durch ein eine einem einen einer eines er
+		es für hat hatte ich ihr im in ist ja kann kein mit muss nach nicht noch nur ob oder
+		sein sich sie sind so über um und uns unter vom von vor war was wenn werden wie wir
+		wird wo zu zum zur`),
+	"fr": wordSet(`à au aux avec ce ces cette dans de des du elle en est et être il ils je la
+		le les leur lui ma mais me même mes moi mon ne nous on ou où par pas pour qu que qui
+		sa sans se ses son sont sur ta te tes toi ton tu un une vos votre vous`),
+	"es": wordSet(`a al como con de del el ella ellos en entre era es esta este esto estos fue
+		ha hay la las le les lo los más me mi no nos o para pero por que se sin sobre su sus
+		también te tu un una uno unos y ya yo`),
+	"it": wordSet(`a ai al alla alle anche che chi ci come con da dal dalla degli dei del della
+		delle di e è gli ha i il in io la le lo ma mi ne nel nella non o per più quello questa
+		questo se si sono su sua suo tra un una uno`),
+	"pt": wordSet(`a ao aos as com como da das de do dos e é ela ele em entre era essa esse está
+		eu foi há isso já mais mas me meu na não nas no nos o os ou para pela pelo por que se
+		sem seu sua são também um uma você`),
+	"nl": wordSet(`aan al als bij dan dat de deze die dit door een en er had heb het hij hoe
+		ik in is je kan maar me met mij na naar niet nog nu of om onder ons ook op over te
+		tot u uit van voor was wat we wel werd wij zal ze zei zich zijn zo zou`),
+	"ru": wordSet(`а без бы в вам вас во вот все всё вы да для до его ее её если есть же за и из
+		или им их к как ко когда кто ли мне мы на над не нет ни но о об он она они от по под
+		при с со так также то только ты у уже что это я`),
+	"uk": wordSet(`а але без би в вам вас від во все ви вони да для до його її з за і із їх к
+		коли ми мені на над не ні о він вона по під при та так також те ти то у це чи що як я`),
+}
+
+func wordSet(words string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, word := range strings.Fields(words) {
+		set[word] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/djblackett/bootdev-hackathon/internal/utils"
+)
+
+// Journal is an append-only JSONL file with one Entry per finished file. It
+// survives a crash mid-run, unlike the report written by Write.
+type Journal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f}, nil
+}
+
+func (j *Journal) Append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(data); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// ReadJournal returns the journaled entries, keeping the last record for each
+// source path. A truncated final line from a crash is ignored.
+func ReadJournal(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	latest := map[string]Entry{}
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				if readErr == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("journal %s line %d: %w", path, line, err)
+			}
+			latest[entry.SourcePath] = entry
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	entries := make([]Entry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SourcePath < entries[j].SourcePath
+	})
+	return entries, nil
+}
+
+// Fingerprint records the source file identity on entry so a resumed run can
+// tell whether the file changed since it was journaled.
+func Fingerprint(entry *Entry, path string, withHash bool) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	entry.SourceSize = stat.Size()
+	entry.SourceModTime = stat.ModTime().UTC().Format(time.RFC3339Nano)
+	if withHash {
+		sum, err := utils.FileSHA256(path)
+		if err != nil {
+			return err
+		}
+		entry.SourceSHA256 = sum
+	}
+	return nil
+}
+
+// Unchanged reports whether path still matches the journaled entry: same size
+// and mtime, or the same content hash when the mtime was not preserved.
+func Unchanged(entry Entry, path string) bool {
+	stat, err := os.Stat(path)
+	if err != nil || stat.Size() != entry.SourceSize {
+		return false
+	}
+	if stat.ModTime().UTC().Format(time.RFC3339Nano) == entry.SourceModTime {
+		return true
+	}
+	if entry.SourceSHA256 == "" {
+		return false
+	}
+	sum, err := utils.FileSHA256(path)
+	return err == nil && sum == entry.SourceSHA256
+}
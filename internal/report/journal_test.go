@@ -0,0 +1,103 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalAppendAndReadKeepsLatestEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []Entry{
+		{SourcePath: "input/b.txt", DestinationPath: "output/b.txt", Method: "metadata"},
+		{SourcePath: "input/a.txt", DestinationPath: "output/a.txt", Method: "metadata"},
+		{SourcePath: "input/b.txt", DestinationPath: "output/b-2.txt", Method: "ai-fallback"},
+	} {
+		if err := journal.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("entries = %+v, want 2", got)
+	}
+	if got[0].SourcePath != "input/a.txt" || got[1].DestinationPath != "output/b-2.txt" {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+}
+
+func TestReadJournalIgnoresTruncatedFinalLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	data := `{"source_path":"input/a.txt","destination_path":"output/a.txt"}` + "\n" + `{"source_path":"input/b.tx`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].SourcePath != "input/a.txt" {
+		t.Fatalf("entries = %+v", got)
+	}
+}
+
+func TestReadJournalRejectsCorruptMiddleLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	data := "not-json\n" + `{"source_path":"input/a.txt"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadJournal(path); err == nil {
+		t.Fatal("expected error for corrupt journal line")
+	}
+}
+
+func TestUnchangedMatchesStatOrHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var statOnly, hashed Entry
+	if err := Fingerprint(&statOnly, path, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fingerprint(&hashed, path, true); err != nil {
+		t.Fatal(err)
+	}
+	if !Unchanged(statOnly, path) {
+		t.Fatal("untouched file should match")
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if Unchanged(statOnly, path) {
+		t.Fatal("mtime change without hash should not match")
+	}
+	if !Unchanged(hashed, path) {
+		t.Fatal("same content hash should match after mtime change")
+	}
+
+	if err := os.WriteFile(path, []byte("jello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if Unchanged(hashed, path) {
+		t.Fatal("changed content should not match")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -20,32 +21,99 @@ type Entry struct {
 	SkipReason      string   `json:"skip_reason,omitempty"`
 	ReviewStatus    string   `json:"review_status,omitempty"`
 	ReviewNote      string   `json:"review_note,omitempty"`
+	SourceSize      int64    `json:"source_size,omitempty"`
+	SourceModTime   string   `json:"source_mod_time,omitempty"`
+	SourceSHA256    string   `json:"source_sha256,omitempty"`
+	DuplicateOf     string   `json:"duplicate_of,omitempty"`
+	VersionGroup    string   `json:"version_group,omitempty"`
+	Version         int      `json:"version,omitempty"`
+	// Candidates are the names the AI proposed, best first; the first one is
+	// the suggested name unless a reviewer chose another.
+	Candidates []Candidate `json:"candidates,omitempty"`
+	// PromptTokens and CompletionTokens are what the AI calls for this entry
+	// used, and EstimatedCost is their price in US dollars. Cache hits are free.
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	EstimatedCost    float64 `json:"estimated_cost,omitempty"`
+	// PromptVersion identifies the prompt templates behind an AI-named
+	// entry; it is empty when the relay server rendered the prompt.
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// Topic is the --organize topic the entry was filed under, if any.
+	Topic string `json:"topic,omitempty"`
+}
+
+// Candidate is one AI-proposed name with the model's self-rated confidence.
+type Candidate struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+	Rationale  string  `json:"rationale,omitempty"`
 }
 
 type Report struct {
-	Summary Summary `json:"summary"`
-	Entries []Entry `json:"entries"`
+	Summary  Summary   `json:"summary"`
+	Plan     *Plan     `json:"plan,omitempty"`
+	Entries  []Entry   `json:"entries"`
+	Clusters []Cluster `json:"clusters,omitempty"`
+}
+
+// Plan estimates what a run would send to the AI, from a --plan run that
+// made no AI calls. Token counts are approximate and completion tokens are
+// the per-call response limit, so the cost errs high.
+type Plan struct {
+	Model               string  `json:"model"`
+	Strategy            string  `json:"strategy"`
+	ConfidenceThreshold float64 `json:"confidence_threshold"`
+	// BelowThreshold counts files whose local confidence is under the
+	// threshold; in auto mode each of them is one AI call.
+	BelowThreshold            int     `json:"below_threshold"`
+	AICalls                   int     `json:"ai_calls"`
+	VisionCalls               int     `json:"vision_calls,omitempty"`
+	EvidenceChars             int     `json:"evidence_chars"`
+	EstimatedPromptTokens     int     `json:"estimated_prompt_tokens"`
+	EstimatedCompletionTokens int     `json:"estimated_completion_tokens"`
+	EstimatedCost             float64 `json:"estimated_cost"`
+}
+
+// Cluster lists the near-duplicate versions of one document, oldest first.
+type Cluster struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
 }
 
 type Summary struct {
-	TotalFiles         int `json:"total_files"`
-	PlannedCount       int `json:"planned_count"`
-	CopiedCount        int `json:"copied_count"`
-	SkippedCount       int `json:"skipped_count"`
-	LowConfidenceCount int `json:"low_confidence_count"`
-	AIFallbackCount    int `json:"ai_fallback_count"`
-	WarningsCount      int `json:"warnings_count"`
-	PendingReviewCount int `json:"pending_review_count"`
-	AcceptedCount      int `json:"accepted_count"`
-	RejectedCount      int `json:"rejected_count"`
+	TotalFiles         int  `json:"total_files"`
+	PlannedCount       int  `json:"planned_count"`
+	CopiedCount        int  `json:"copied_count"`
+	SkippedCount       int  `json:"skipped_count"`
+	LowConfidenceCount int  `json:"low_confidence_count"`
+	AIFallbackCount    int  `json:"ai_fallback_count"`
+	WarningsCount      int  `json:"warnings_count"`
+	PendingReviewCount int  `json:"pending_review_count"`
+	AcceptedCount      int  `json:"accepted_count"`
+	RejectedCount      int  `json:"rejected_count"`
+	DuplicateCount     int  `json:"duplicate_count"`
+	Interrupted        bool `json:"interrupted,omitempty"`
+
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	EstimatedCost    float64 `json:"estimated_cost,omitempty"`
+	// AIBudgetSkippedCount is how many files kept their metadata name because
+	// --max-ai-budget had been reached.
+	AIBudgetSkippedCount int `json:"ai_budget_skipped_count,omitempty"`
 }
 
 func Write(path string, entries []Entry) error {
+	return WriteReport(path, Report{Summary: BuildSummary(entries), Entries: entries, Clusters: BuildClusters(entries)})
+}
+
+// WriteReport writes report as-is, for callers that annotate the summary,
+// such as an interrupted run.
+func WriteReport(path string, report Report) error {
 	if path == "" {
 		return nil
 	}
 
-	data, err := json.MarshalIndent(Report{Summary: BuildSummary(entries), Entries: entries}, "", "  ")
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -66,14 +134,20 @@ func BuildSummary(entries []Entry) Summary {
 		if entry.Confidence < 0.4 {
 			summary.LowConfidenceCount++
 		}
-		if entry.Method == "ai-fallback" || entry.Method == "ai-only" {
+		if entry.Method == "ai-fallback" || entry.Method == "ai-only" || entry.Method == "ai-vision" {
 			summary.AIFallbackCount++
 		}
 		if len(entry.Warnings) > 0 {
 			summary.WarningsCount++
 		}
+		if entry.DuplicateOf != "" {
+			summary.DuplicateCount++
+		}
+		summary.PromptTokens += entry.PromptTokens
+		summary.CompletionTokens += entry.CompletionTokens
+		summary.EstimatedCost += entry.EstimatedCost
 		status := strings.ToLower(strings.TrimSpace(entry.ReviewStatus))
-		if status == "" && entry.Skipped {
+		if status == "" && entry.Skipped && entry.DuplicateOf == "" {
 			status = "pending"
 		}
 		switch status {
@@ -88,6 +162,33 @@ func BuildSummary(entries []Entry) Summary {
 	return summary
 }
 
+// BuildClusters collects entries that share a version group, ordered by name
+// and then by version.
+func BuildClusters(entries []Entry) []Cluster {
+	byName := map[string][]Entry{}
+	for _, entry := range entries {
+		if entry.VersionGroup != "" {
+			byName[entry.VersionGroup] = append(byName[entry.VersionGroup], entry)
+		}
+	}
+
+	clusters := make([]Cluster, 0, len(byName))
+	for name, members := range byName {
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Version < members[j].Version
+		})
+		cluster := Cluster{Name: name}
+		for _, member := range members {
+			cluster.Members = append(cluster.Members, member.SourcePath)
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters
+}
+
 func Read(path string) (Report, error) {
 	var report Report
 	data, err := os.ReadFile(path)
@@ -113,33 +214,66 @@ func WriteReviewMarkdown(path string, report Report) error {
 	fmt.Fprintf(&b, "- Pending review: %d\n", report.Summary.PendingReviewCount)
 	fmt.Fprintf(&b, "- Accepted: %d\n", report.Summary.AcceptedCount)
 	fmt.Fprintf(&b, "- Rejected: %d\n\n", report.Summary.RejectedCount)
-	b.WriteString("To accept a skipped entry, edit the JSON report and set `review_status` to `accepted`. Then run `--apply-report report.json --include-skipped`.\n\n")
-	b.WriteString("| Status | Confidence | Source | Destination | Reason | Notes |\n")
-	b.WriteString("|---|---:|---|---|---|---|\n")
+	b.WriteString("To accept a skipped entry, edit the JSON report and set `review_status` to `accepted`. Then run `--apply-report report.json --include-skipped`.\n")
+	b.WriteString("To use one of the alternatives instead, run `--set-review-status report.json --review-choose source=N` with its number.\n\n")
+	b.WriteString("| Status | Confidence | Source | Destination | Reason | Notes | Alternatives |\n")
+	b.WriteString("|---|---:|---|---|---|---|---|\n")
 
 	for _, entry := range report.Entries {
 		if !entry.Skipped && entry.ReviewStatus == "" {
 			continue
 		}
 		status := entry.ReviewStatus
-		if status == "" && entry.Skipped {
+		switch {
+		case status != "":
+		case entry.DuplicateOf != "":
+			status = "duplicate"
+		case entry.Skipped:
 			status = "pending"
 		}
 		fmt.Fprintf(
 			&b,
-			"| %s | %.2f | `%s` | `%s` | %s | %s |\n",
+			"| %s | %.2f | `%s` | `%s` | %s | %s | %s |\n",
 			escapeMarkdownTable(status),
 			entry.Confidence,
 			escapeMarkdownCode(entry.SourcePath),
 			escapeMarkdownCode(entry.DestinationPath),
 			escapeMarkdownTable(entry.SkipReason),
 			escapeMarkdownTable(entry.ReviewNote),
+			formatCandidates(entry.Candidates),
 		)
 	}
 
+	if len(report.Clusters) > 0 {
+		b.WriteString("\n## Version clusters\n\n")
+		for _, cluster := range report.Clusters {
+			fmt.Fprintf(&b, "- `%s`:", escapeMarkdownCode(cluster.Name))
+			for i, member := range cluster.Members {
+				fmt.Fprintf(&b, " v%d `%s`", i+1, escapeMarkdownCode(member))
+			}
+			b.WriteString("\n")
+		}
+	}
+
 	return os.WriteFile(path, []byte(b.String()), 0644)
 }
 
+// formatCandidates lists candidates as "1. `name` (0.80) rationale<br>...",
+// numbered the way --review-choose expects.
+func formatCandidates(candidates []Candidate) string {
+	if len(candidates) < 2 {
+		return ""
+	}
+	parts := make([]string, len(candidates))
+	for i, c := range candidates {
+		parts[i] = fmt.Sprintf("%d. `%s` (%.2f)", i+1, escapeMarkdownCode(c.Name), c.Confidence)
+		if c.Rationale != "" {
+			parts[i] += " " + escapeMarkdownTable(c.Rationale)
+		}
+	}
+	return strings.Join(parts, "<br>")
+}
+
 func NormalizeReviewStatus(status string) string {
 	switch strings.ToLower(strings.TrimSpace(status)) {
 	case "", "pending":
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FindDuplicates groups byte-identical files. Files are bucketed by size
+// first so only same-size candidates are hashed. The result maps the
+// canonical path of each group (the lexically first one) to its duplicates.
+// Files that cannot be read are left out of every group and returned in
+// unreadable with their errors; the only error returned is ctx's.
+func FindDuplicates(ctx context.Context, paths []string) (groups map[string][]string, unreadable map[string]error, err error) {
+	unreadable = map[string]error{}
+	bySize := map[int64][]string{}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			unreadable[path] = err
+			continue
+		}
+		bySize[stat.Size()] = append(bySize[stat.Size()], path)
+	}
+
+	groups = map[string][]string{}
+	for _, candidates := range bySize {
+		if len(candidates) < 2 {
+			continue
+		}
+		byHash := map[string][]string{}
+		for _, path := range candidates {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			sum, err := FileSHA256(path)
+			if err != nil {
+				unreadable[path] = err
+				continue
+			}
+			byHash[sum] = append(byHash[sum], path)
+		}
+		for _, same := range byHash {
+			if len(same) < 2 {
+				continue
+			}
+			sort.Strings(same)
+			groups[same[0]] = same[1:]
+		}
+	}
+	return groups, unreadable, nil
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 of the file contents.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LinkOrCopy hard-links src to dest, falling back to a copy on filesystems
+// that do not support links.
+func LinkOrCopy(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	return CopyFileToPath(src, dest)
+}
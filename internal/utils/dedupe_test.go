@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDuplicatesGroupsIdenticalContent(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.txt": "same bytes",
+		"a.txt": "same bytes",
+		"c.txt": "same bytes",
+		"d.txt": "diff bytes",
+		"e.txt": "unique and longer",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	groups, unreadable, err := FindDuplicates(context.Background(), paths)
+	if err != nil || len(unreadable) != 0 {
+		t.Fatal(err, unreadable)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("groups = %+v, want 1", groups)
+	}
+	dups := groups[filepath.Join(dir, "a.txt")]
+	if len(dups) != 2 || dups[0] != filepath.Join(dir, "b.txt") || dups[1] != filepath.Join(dir, "c.txt") {
+		t.Fatalf("duplicates of a.txt = %v", dups)
+	}
+}
+
+func TestFindDuplicatesSkipsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("same bytes"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "gone.txt")
+
+	groups, unreadable, err := FindDuplicates(context.Background(), []string{a, missing, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups[a]) != 1 || groups[a][0] != b {
+		t.Fatalf("groups = %v, want a.txt and b.txt grouped", groups)
+	}
+	if _, ok := unreadable[missing]; !ok || len(unreadable) != 1 {
+		t.Fatalf("unreadable = %v, want only gone.txt", unreadable)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := FindDuplicates(ctx, []string{a, b}); err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
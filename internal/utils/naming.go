@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Naming styles accepted by NamingProfile.Style.
+const (
+	StyleKebab      = "kebab"
+	StyleSnake      = "snake"
+	StyleTitle      = "title"
+	StyleCamel      = "camel"
+	StyleDatePrefix = "date-prefix"
+)
+
+// Styles lists the naming styles in the order help text shows them.
+var Styles = []string{StyleKebab, StyleSnake, StyleTitle, StyleCamel, StyleDatePrefix}
+
+// datePrefixLayout is the date the date-prefix style puts in front of names.
+const datePrefixLayout = "2006-01-02"
+
+// DefaultMaxNameLength is the longest name, in bytes, a profile produces
+// unless it sets its own limit.
+const DefaultMaxNameLength = 60
+
+// trailingBanned are dropped from the end of every name; they describe the
+// container rather than the content.
+var trailingBanned = []string{"document", "file", "note", "notes", "txt"}
+
+// NamingProfile is a naming convention: how words are cased and joined, how
+// long a name may be, which words never appear in it, and whether letters
+// outside ASCII are kept or transliterated.
+type NamingProfile struct {
+	Style       string
+	MaxLength   int
+	BannedWords []string
+	Unicode     bool
+}
+
+// DefaultProfile is lowercase words separated by dashes.
+var DefaultProfile = NamingProfile{Style: StyleKebab, MaxLength: DefaultMaxNameLength}
+
+// Validate rejects unknown styles, negative lengths, and date-prefix
+// lengths with no room for a word after the date.
+func (p NamingProfile) Validate() error {
+	if p.Style != "" && !slices.Contains(Styles, p.Style) {
+		return fmt.Errorf("unknown naming style %q: use %s", p.Style, strings.Join(Styles, ", "))
+	}
+	if p.MaxLength < 0 {
+		return fmt.Errorf("max name length must not be negative")
+	}
+	if minimum := len(datePrefixLayout) + 2; p.Style == StyleDatePrefix && p.MaxLength > 0 && p.MaxLength < minimum {
+		return fmt.Errorf("max name length must be at least %d for the %s style", minimum, StyleDatePrefix)
+	}
+	return nil
+}
+
+// Format turns s into a name in the profile's style. Words are split on
+// anything that is not a letter or digit, and on case changes in camelCase
+// input, so formatting an already formatted name changes nothing. The
+// date-prefix style puts date in front, replacing any date s starts with,
+// unless date is zero.
+func (p NamingProfile) Format(s string, date time.Time) string {
+	words := p.Words(s)
+	prefix := ""
+	if p.Style == StyleDatePrefix && !date.IsZero() {
+		prefix = date.Format(datePrefixLayout)
+		if leadingDate(words) {
+			words = words[3:]
+		}
+	}
+
+	sep := p.separator()
+	maxLength := p.Limit()
+	var b strings.Builder
+	if prefix != "" {
+		b.WriteString(prefix)
+	}
+	for i, word := range words {
+		word = p.caseWord(word, i)
+		if b.Len() > 0 {
+			word = sep + word
+		}
+		if b.Len()+len(word) > maxLength {
+			if b.Len() == len(prefix) {
+				// The first word alone is too long: cut it rather than
+				// return an empty name.
+				b.WriteString(TruncateRunes(word, maxLength-b.Len()))
+			}
+			break
+		}
+		b.WriteString(word)
+	}
+	return strings.Trim(b.String(), sep+"-_ ")
+}
+
+// Words returns the lowercase words of s with banned words removed. Unless
+// the profile keeps Unicode, letters are transliterated to ASCII first.
+func (p NamingProfile) Words(s string) []string {
+	if !p.Unicode {
+		s = Transliterate(s)
+	}
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	runes := []rune(strings.TrimSpace(s))
+	for i, r := range runes {
+		if !p.wordRune(r) {
+			flush()
+			continue
+		}
+		// quarterlyBudget splits before the B. Only the camel style
+		// splits this way, so names like McDonald stay one word elsewhere.
+		if p.Style == StyleCamel && i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) {
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	words = slices.DeleteFunc(words, func(w string) bool {
+		return slices.ContainsFunc(p.BannedWords, func(banned string) bool { return strings.EqualFold(banned, w) })
+	})
+	for len(words) > 1 && slices.Contains(trailingBanned, words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	return words
+}
+
+func (p NamingProfile) wordRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	}
+	return p.Unicode && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))
+}
+
+func (p NamingProfile) separator() string {
+	switch p.Style {
+	case StyleSnake:
+		return "_"
+	case StyleTitle:
+		return " "
+	case StyleCamel:
+		return ""
+	}
+	return "-"
+}
+
+func (p NamingProfile) caseWord(word string, i int) string {
+	switch {
+	case p.Style == StyleTitle, p.Style == StyleCamel && i > 0:
+		r, size := utf8.DecodeRuneInString(word)
+		return string(unicode.ToUpper(r)) + word[size:]
+	}
+	return word
+}
+
+// Limit is the longest name in bytes the profile produces.
+func (p NamingProfile) Limit() int {
+	if p.MaxLength > 0 {
+		return p.MaxLength
+	}
+	return DefaultMaxNameLength
+}
+
+// leadingDate reports whether words start with a year, month and day.
+func leadingDate(words []string) bool {
+	if len(words) < 3 || len(words[0]) != 4 || len(words[1]) != 2 || len(words[2]) != 2 {
+		return false
+	}
+	for _, w := range words[:3] {
+		if strings.Trim(w, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe is the profile as a rule for an AI prompt. Dates for the
+// date-prefix style are added afterwards, so the model is told to leave
+// them out.
+func (p NamingProfile) Describe() string {
+	var rule string
+	switch p.Style {
+	case StyleSnake:
+		rule = "lowercase words separated by underscores"
+	case StyleTitle:
+		rule = "Title Case words separated by spaces"
+	case StyleCamel:
+		rule = "camelCase words with no separators"
+	case StyleDatePrefix:
+		rule = "lowercase words separated by dashes, without a leading date (one is added for you)"
+	default:
+		rule = "lowercase words separated by dashes"
+	}
+	if p.MaxLength > 0 && p.MaxLength != DefaultMaxNameLength {
+		rule += fmt.Sprintf(", at most %d characters", p.MaxLength)
+	}
+	if p.Unicode {
+		rule += ", keeping letters outside ASCII"
+	}
+	if len(p.BannedWords) > 0 {
+		rule += "; never use the words " + strings.Join(p.BannedWords, ", ")
+	}
+	return rule
+}
+
+// TruncateRunes cuts s to at most n bytes without splitting a rune. It
+// returns "" when n is not positive.
+func TruncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamingProfileFormatsEachStyle(t *testing.T) {
+	date := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		style string
+		want  string
+	}{
+		{StyleKebab, "quarterly-budget-review-q4"},
+		{StyleSnake, "quarterly_budget_review_q4"},
+		{StyleTitle, "Quarterly Budget Review Q4"},
+		{StyleCamel, "quarterlyBudgetReviewQ4"},
+		{StyleDatePrefix, "2024-03-09-quarterly-budget-review-q4"},
+	} {
+		profile := NamingProfile{Style: tc.style}
+		got := profile.Format("  Quarterly Budget_Review: Q4 notes ", date)
+		if got != tc.want {
+			t.Errorf("%s: Format = %q, want %q", tc.style, got, tc.want)
+		}
+		if again := profile.Format(got, date); again != got {
+			t.Errorf("%s: formatting %q again gave %q", tc.style, got, again)
+		}
+	}
+}
+
+func TestDefaultProfileKeepsKebabDefaults(t *testing.T) {
+	for in, want := range map[string]string{
+		"Miles Davis: Kind of Blue!": "miles-davis-kind-of-blue",
+		"budget-review-document":     "budget-review",
+		"notes":                      "notes",
+		"--":                         "",
+	} {
+		if got := DefaultProfile.Format(in, time.Time{}); got != want {
+			t.Errorf("Format(%q) = %q, want %q", in, got, want)
+		}
+	}
+	long := DefaultProfile.Format(strings.Repeat("word ", 30), time.Time{})
+	if len(long) > DefaultMaxNameLength || strings.HasSuffix(long, "-") || strings.HasSuffix(long, "-wor") {
+		t.Fatalf("Format long input = %q, want whole words within %d bytes", long, DefaultMaxNameLength)
+	}
+}
+
+func TestNamingProfileLimitsBansAndUnicode(t *testing.T) {
+	profile := NamingProfile{Style: StyleKebab, MaxLength: 20, BannedWords: []string{"Draft", "copy"}}
+	if got := profile.Format("final draft of the tax return copy 2023", time.Time{}); got != "final-of-the-tax" {
+		t.Fatalf("Format = %q", got)
+	}
+	if got := profile.Format(strings.Repeat("x", 30), time.Time{}); got != strings.Repeat("x", 20) {
+		t.Fatalf("a single long word = %q, want it cut to the limit", got)
+	}
+
+	if got := (NamingProfile{}).Format("Café Über Straße", time.Time{}); got != "cafe-ueber-strasse" {
+		t.Fatalf("ASCII Format = %q", got)
+	}
+	if got := (NamingProfile{}).Format("Отчёт Москва 会議", time.Time{}); got != "otchyot-moskva" {
+		t.Fatalf("ASCII Format = %q, want Cyrillic transliterated and Han dropped", got)
+	}
+	unicode := NamingProfile{Style: StyleTitle, Unicode: true, MaxLength: 14}
+	if got := unicode.Format("café über straße", time.Time{}); got != "Café Über" {
+		t.Fatalf("Unicode Format = %q", got)
+	}
+	if got := TruncateRunes("straße", 5); got != "stra" {
+		t.Fatalf("TruncateRunes split a rune: %q", got)
+	}
+}
+
+func TestShortLimitsNeverPanic(t *testing.T) {
+	date := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		profile NamingProfile
+		want    string
+		valid   bool
+	}{
+		{NamingProfile{Style: StyleDatePrefix, MaxLength: 5}, "2024-03-09", false},
+		{NamingProfile{Style: StyleDatePrefix, MaxLength: 11}, "2024-03-09", false},
+		{NamingProfile{Style: StyleDatePrefix, MaxLength: 12}, "2024-03-09-q", true},
+		{NamingProfile{Style: StyleKebab, MaxLength: 1}, "q", true},
+		{NamingProfile{Style: StyleTitle, MaxLength: 3}, "Qua", true},
+	} {
+		if err := tc.profile.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%+v: Validate = %v, want valid %v", tc.profile, err, tc.valid)
+		}
+		if got := tc.profile.Format("quarterly budget", date); got != tc.want {
+			t.Errorf("%+v: Format = %q, want %q", tc.profile, got, tc.want)
+		}
+	}
+	for _, n := range []int{-5, 0} {
+		if got := TruncateRunes("straße", n); got != "" {
+			t.Errorf("TruncateRunes(%d) = %q, want empty", n, got)
+		}
+	}
+}
+
+func TestDatePrefixReplacesAnExistingDate(t *testing.T) {
+	profile := NamingProfile{Style: StyleDatePrefix}
+	date := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	if got := profile.Format("2023-09-14-vendor-contract", date); got != "2024-03-09-vendor-contract" {
+		t.Fatalf("Format = %q", got)
+	}
+	if got := profile.Format("vendor contract", time.Time{}); got != "vendor-contract" {
+		t.Fatalf("undated Format = %q", got)
+	}
+}
+
+func TestNamingProfileValidate(t *testing.T) {
+	if err := (NamingProfile{Style: "screaming"}).Validate(); err == nil {
+		t.Fatal("Validate accepted an unknown style")
+	}
+	if err := (NamingProfile{MaxLength: -1}).Validate(); err == nil {
+		t.Fatal("Validate accepted a negative length")
+	}
+	if err := DefaultProfile.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// transliterations spell accented Latin, Greek and Cyrillic letters in
+// ASCII. German umlauts take their German spelling, ä as ae. Scripts such
+// as Han have no spelling here; names need Unicode or the AI for them.
+var transliterations = buildTransliterations(map[string]string{
+	"àáâãåāăą": "a", "ÀÁÂÃÅĀĂĄ": "A", "çćĉċč": "c", "ÇĆĈĊČ": "C", "ďđ": "d", "ĎĐ": "D",
+	"èéêëēĕėęě": "e", "ÈÉÊËĒĔĖĘĚ": "E", "ĝğġģ": "g", "ĜĞĠĢ": "G", "ĥħ": "h", "ĤĦ": "H",
+	"ìíîïĩīĭįı": "i", "ÌÍÎÏĨĪĬĮİ": "I", "ĵ": "j", "Ĵ": "J", "ķ": "k", "Ķ": "K",
+	"ĺļľŀł": "l", "ĹĻĽĿŁ": "L", "ñńņňŉ": "n", "ÑŃŅŇ": "N", "òóôõøōŏő": "o", "ÒÓÔÕØŌŎŐ": "O",
+	"ŕŗř": "r", "ŔŖŘ": "R", "śŝşšș": "s", "ŚŜŞŠȘ": "S", "ţťŧț": "t", "ŢŤŦȚ": "T",
+	"ùúûũūŭůűų": "u", "ÙÚÛŨŪŬŮŰŲ": "U", "ŵ": "w", "Ŵ": "W", "ýÿŷ": "y", "ÝŸŶ": "Y",
+	"źżž": "z", "ŹŻŽ": "Z",
+	"ä": "ae", "Ä": "Ae", "ö": "oe", "Ö": "Oe", "ü": "ue", "Ü": "Ue", "ß": "ss",
+	"æ": "ae", "Æ": "Ae", "œ": "oe", "Œ": "Oe", "ð": "d", "Ð": "D", "þ": "th", "Þ": "Th",
+
+	"αά": "a", "ΑΆ": "A", "β": "v", "Β": "V", "γ": "g", "Γ": "G", "δ": "d", "Δ": "D",
+	"εέ": "e", "ΕΈ": "E", "ζ": "z", "Ζ": "Z", "ηή": "i", "ΗΉ": "I", "θ": "th", "Θ": "Th",
+	"ιίϊΐ": "i", "ΙΊΪ": "I", "κ": "k", "Κ": "K", "λ": "l", "Λ": "L", "μ": "m", "Μ": "M",
+	"ν": "n", "Ν": "N", "ξ": "x", "Ξ": "X", "οό": "o", "ΟΌ": "O", "π": "p", "Π": "P",
+	"ρ": "r", "Ρ": "R", "σς": "s", "Σ": "S", "τ": "t", "Τ": "T", "υύϋΰ": "y", "ΥΎΫ": "Y",
+	"φ": "f", "Φ": "F", "χ": "ch", "Χ": "Ch", "ψ": "ps", "Ψ": "Ps", "ωώ": "o", "ΩΏ": "O",
+
+	"а": "a", "А": "A", "б": "b", "Б": "B", "в": "v", "В": "V", "гґ": "g", "ГҐ": "G",
+	"д": "d", "Д": "D", "е": "e", "Е": "E", "ё": "yo", "Ё": "Yo", "є": "ye", "Є": "Ye",
+	"ж": "zh", "Ж": "Zh", "з": "z", "З": "Z", "иі": "i", "ИІ": "I", "ї": "yi", "Ї": "Yi",
+	"й": "y", "Й": "Y", "к": "k", "К": "K", "л": "l", "Л": "L", "м": "m", "М": "M",
+	"н": "n", "Н": "N", "о": "o", "О": "O", "п": "p", "П": "P", "р": "r", "Р": "R",
+	"с": "s", "С": "S", "т": "t", "Т": "T", "у": "u", "У": "U", "ф": "f", "Ф": "F",
+	"х": "kh", "Х": "Kh", "ц": "ts", "Ц": "Ts", "ч": "ch", "Ч": "Ch", "ш": "sh", "Ш": "Sh",
+	"щ": "shch", "Щ": "Shch", "ъь": "", "ЪЬ": "", "ы": "y", "Ы": "Y", "э": "e", "Э": "E",
+	"ю": "yu", "Ю": "Yu", "я": "ya", "Я": "Ya",
+})
+
+func buildTransliterations(groups map[string]string) map[rune]string {
+	table := map[rune]string{}
+	for letters, ascii := range groups {
+		for _, r := range letters {
+			table[r] = ascii
+		}
+	}
+	return table
+}
+
+// Transliterate spells the accented Latin, Greek and Cyrillic letters of s
+// in ASCII, as in Müller to Mueller or Москва to Moskva. Other characters
+// are kept.
+func Transliterate(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if ascii, ok := transliterations[r]; ok {
+			b.WriteString(ascii)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}